@@ -0,0 +1,287 @@
+package controller
+
+import (
+	"archive/zip"
+	"diary_api/helper"
+	"diary_api/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of entries loaded at once while an export is streamed.
+const exportBatchSize = 100
+
+// recentLoginWindow is how recent a login must be to count as re-authentication without a password.
+const recentLoginWindow = 5 * time.Minute
+
+/*
+ExportAccount function:
+
+1. Executes helper.CurrentUserId function and model.FindUserById function.
+
+2. Starts a ZIP response and creates manifest.json in it.
+
+3. Writes the user and every entry, including soft-deleted ones, into manifest.json batch by batch.
+
+The ZIP is streamed, so that all entries are never loaded into memory at once.
+If an error happens after the headers are sent, the connection is dropped(helper.AbortStream).
+*/
+func ExportAccount(context *gin.Context) {
+	// Executes helper.CurrentUserId function and model.FindUserById function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := model.FindUserById(userId)
+	if err != nil {
+		// If model.FindUserById function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := model.FindProfileByUserId(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Starts a ZIP response and creates manifest.json in it.
+	fileName := fmt.Sprintf("diary-export-%s.zip", time.Now().Format("20060102"))
+	context.Header("Content-Type", "application/zip")
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	context.Status(http.StatusOK)
+
+	// The headers have already been sent, so the connection is dropped on errors from here on, and the error is logged.
+	// The ZIP is only closed on success, so that a truncated export is never a valid ZIP.
+	zipWriter := zip.NewWriter(context.Writer)
+	manifest, err := zipWriter.Create("manifest.json")
+	if err != nil {
+		helper.AbortStream(context, err)
+		return
+	}
+
+	// Writes the user and every entry into manifest.json batch by batch.
+	if err := writeExportManifest(manifest, user, profile); err != nil {
+		helper.AbortStream(context, err)
+		return
+	}
+	if err := zipWriter.Close(); err != nil {
+		helper.AbortStream(context, err)
+	}
+}
+
+/*
+writeExportManifest function:
+
+1. Writes the header of the manifest with the user, their profile and their wrapped encryption key.
+The key is null if the user has not set one up. Without it, their encrypted entries could not be decrypted from the export.
+
+2. Executes model.FindEntriesInBatches function and writes each entry.
+
+3. Closes the JSON object.
+*/
+func writeExportManifest(manifest io.Writer, user model.User, profile model.Profile) error {
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	var encryptionKey *model.EncryptionKey
+	if key, err := model.FindEncryptionKeyByUserId(user.ID); err == nil {
+		encryptionKey = &key
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	encryptionKeyJSON, err := json.Marshal(encryptionKey)
+	if err != nil {
+		return err
+	}
+	exportedAt, _ := json.Marshal(time.Now())
+	// Writes the header of the manifest with the user, their profile and their wrapped encryption key.
+	if _, err := fmt.Fprintf(manifest, `{"version":1,"exported_at":%s,"user":%s,"profile":%s,"encryption_key":%s,"entries":[`,
+		exportedAt, userJSON, profileJSON, encryptionKeyJSON); err != nil {
+		return err
+	}
+
+	// Executes model.FindEntriesInBatches function and writes each entry.
+	first := true
+	err = model.FindEntriesInBatches(user.ID, exportBatchSize, func(entries []model.Entry) error {
+		for _, entry := range entries {
+			entryJSON, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := manifest.Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := manifest.Write(entryJSON); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Closes the JSON object.
+	_, err = manifest.Write([]byte("]}"))
+	return err
+}
+
+/*
+DeleteAccount function:
+
+1. Executes the validation.
+
+2. Requires re-authentication: the password, or a login within the last few minutes (e.g. with an identity provider).
+
+3. Executes model.ScheduleUserDeletion function, which disables the user and revokes every token immediately.
+
+4. If model.ScheduleUserDeletion function is successfully executed, StatusAccepted(202) is returned with the time of the hard deletion.
+*/
+func DeleteAccount(context *gin.Context) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	// Executes the validation.
+	// The body is optional for users who re-authenticate by logging in again.
+	if context.Request.ContentLength != 0 {
+		if err := context.ShouldBindJSON(&input); err != nil {
+			// If the validation fails, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := model.FindUserById(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Requires re-authentication.
+	if !reauthenticated(context, user, input.Password) {
+		// If the user has not re-authenticated, StatusUnauthorized(401) is returned.
+		context.JSON(http.StatusUnauthorized, gin.H{"error": "Re-authentication is required to delete the account"})
+		return
+	}
+
+	// Executes model.ScheduleUserDeletion function.
+	deleteAt := time.Now().Add(accountDeletionGracePeriod())
+	if err := model.ScheduleUserDeletion(userId, deleteAt); err != nil {
+		// If model.ScheduleUserDeletion function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	helper.ClearSessionCookies(context)
+	// If model.ScheduleUserDeletion function is successfully executed, StatusAccepted(202) is returned.
+	context.JSON(http.StatusAccepted, gin.H{"deletion_scheduled_at": deleteAt})
+}
+
+/*
+RestoreAccount function:
+
+1. Executes the validation.
+
+2. Executes model.FindUserByUsername function and (*model.User).ValidatePassword function.
+
+3. Executes model.CancelUserDeletion function, which enables the user again.
+
+4. If model.CancelUserDeletion function is successfully executed, StatusNoContent(204) is returned, and the user can log in again.
+
+The user cannot log in during the grace period, so they prove who they are with their password here.
+Users created by an identity provider do not know their password, so an admin restores them(POST /admin/users/:id/enable).
+*/
+func RestoreAccount(context *gin.Context) {
+	var input model.AuthenticationInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindUserByUsername function and (*model.User).ValidatePassword function.
+	user, err := model.FindUserByUsername(input.Username)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := user.ValidatePassword(input.Password); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.CancelUserDeletion function.
+	err = model.CancelUserDeletion(user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the deletion of the account is not scheduled, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": "The deletion of the account is not scheduled."})
+		return
+	}
+	if err != nil {
+		// If model.CancelUserDeletion function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.CancelUserDeletion function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+reauthenticated function:
+
+1. Returns true if the password is correct.
+
+2. Otherwise, returns true if the session of the request was created within recentLoginWindow.
+Users created by an identity provider do not know their password, so they re-authenticate by logging in again.
+*/
+func reauthenticated(context *gin.Context, user model.User, password string) bool {
+	// Returns true if the password is correct.
+	if password != "" {
+		return user.ValidatePassword(password) == nil
+	}
+	// Otherwise, returns true if the session of the request was created within recentLoginWindow.
+	session, err := model.FindSessionById(helper.CurrentSessionId(context))
+	if err != nil {
+		return false
+	}
+	return time.Since(session.CreatedAt) < recentLoginWindow
+}
+
+/*
+accountDeletionGracePeriod function:
+
+1. Returns the ACCOUNT_DELETION_GRACE_PERIOD environment variable(e.g. "720h").
+
+2. Defaults to 30 days.
+*/
+func accountDeletionGracePeriod() time.Duration {
+	gracePeriod, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"))
+	if err != nil || gracePeriod < 0 {
+		return 30 * 24 * time.Hour
+	}
+	return gracePeriod
+}
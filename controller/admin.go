@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+AdminGetUsers function:
+
+1. Sets the search query(q) and the page from the query parameters.
+
+2. Executes model.SearchUserSummaries function.
+
+3. If model.SearchUserSummaries function is successfully executed, StatusOK(200) is returned.
+
+Each user has the number of their entries, but never their content.
+*/
+func AdminGetUsers(context *gin.Context) {
+	// Sets the search query(q) and the page from the query parameters.
+	page, perPage := parsePagination(context)
+
+	// Executes model.SearchUserSummaries function.
+	users, total, err := model.SearchUserSummaries(context.Query("q"), perPage, (page-1)*perPage)
+	if err != nil {
+		// If model.SearchUserSummaries function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.SearchUserSummaries function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": users, "total": total, "page": page, "per_page": perPage})
+}
+
+/*
+AdminGetUser function:
+
+1. Sets the userId to a value extracted from the URL parameter.
+
+2. Executes model.FindUserSummaryById function.
+
+3. If model.FindUserSummaryById function is successfully executed, StatusOK(200) is returned.
+*/
+func AdminGetUser(context *gin.Context) {
+	// Sets the userId to a value extracted from the URL parameter.
+	userId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+
+	// Executes model.FindUserSummaryById function.
+	user, err := model.FindUserSummaryById(userId)
+	if !respondToAdminError(context, userId, err) {
+		return
+	}
+	// If model.FindUserSummaryById function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+/*
+AdminDisableUser function:
+
+1. Executes model.DisableUser function, which also revokes every session of the user.
+
+2. Responds with the updated user.
+*/
+func AdminDisableUser(context *gin.Context) {
+	adminUpdateUser(context, model.DisableUser)
+}
+
+/*
+AdminEnableUser function:
+
+1. Executes model.EnableUser function.
+
+2. Responds with the updated user.
+*/
+func AdminEnableUser(context *gin.Context) {
+	adminUpdateUser(context, model.EnableUser)
+}
+
+/*
+AdminRequirePasswordReset function:
+
+1. Executes model.RequirePasswordReset function, which also revokes every session of the user.
+
+2. Responds with the updated user.
+*/
+func AdminRequirePasswordReset(context *gin.Context) {
+	adminUpdateUser(context, model.RequirePasswordReset)
+}
+
+/*
+AdminUpdateUserRole function:
+
+1. Executes the validation.
+
+2. Executes model.SetUserRole function, which also revokes every session of the user.
+
+3. Responds with the updated user.
+*/
+func AdminUpdateUserRole(context *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := model.ValidateRole(input.Role); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.SetUserRole function.
+	adminUpdateUser(context, func(userId uint) error {
+		return model.SetUserRole(userId, input.Role)
+	})
+}
+
+/*
+adminUpdateUser function:
+
+1. Sets the userId to a value extracted from the URL parameter.
+
+2. Executes the update function.
+
+3. Executes model.FindUserSummaryById function, and StatusOK(200) is returned with the updated user.
+*/
+func adminUpdateUser(context *gin.Context, update func(userId uint) error) {
+	// Sets the userId to a value extracted from the URL parameter.
+	userId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+
+	// Executes the update function.
+	if !respondToAdminError(context, userId, update(userId)) {
+		return
+	}
+
+	// Executes model.FindUserSummaryById function.
+	user, err := model.FindUserSummaryById(userId)
+	if !respondToAdminError(context, userId, err) {
+		return
+	}
+	// StatusOK(200) is returned with the updated user.
+	context.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+/*
+respondToAdminError function:
+
+1. If the user does not exist, StatusNotFound(404) is returned.
+
+2. If another error occurred, StatusBadRequest(400) is returned.
+
+3. Returns true if there was no error.
+*/
+func respondToAdminError(context *gin.Context, userId uint, err error) bool {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target userId does not exist. [userId: %d]", userId)
+		// If the user does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return false
+	}
+	if err != nil {
+		// If another error occurred, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
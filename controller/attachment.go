@@ -0,0 +1,336 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"diary_api/helper"
+	"diary_api/job"
+	"diary_api/model"
+	"diary_api/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead is the room left in the request body for the multipart boundaries and headers around the file.
+const multipartOverhead = 1 << 20
+
+// sniffLength is the number of bytes http.DetectContentType looks at.
+const sniffLength = 512
+
+/*
+UploadAttachment function:
+
+1. Executes helper.CurrentUserId function, and findEntry function.
+The server would see the files of encrypted entries in plaintext, so StatusConflict(409) is returned for them.
+
+2. Reads the file(file) from the multipart form. The request body is limited to model.AttachmentMaxSize function.
+If the file is too large, StatusRequestEntityTooLarge(413) is returned.
+
+3. Detects the type of the file from its content. If it cannot be attached, StatusUnsupportedMediaType(415) is returned.
+
+4. Executes helper.ReadImageAttachment function for images, which strips their metadata unless the user keeps it.
+
+5. Stores the file in the blob store(storage.Blobs) while computing its SHA-256, and executes (*model.Attachment).Save function.
+
+6. If (*model.Attachment).Save function is successfully executed, StatusCreated(201) is returned.
+The thumbnails of images are generated in the background(job.StartThumbnailWorker), and thumbnail_status is "pending" until then.
+*/
+func UploadAttachment(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function.
+	entry, ok := findEntry(context, userId, model.JournalRoleEditor)
+	if !ok {
+		return
+	}
+	// The server would see the file in plaintext, so files cannot be attached to encrypted entries.
+	if !rejectEncryptedEntry(context, entry, "attach files to") {
+		return
+	}
+
+	// Reads the file from the multipart form.
+	maxSize := model.AttachmentMaxSize()
+	tooLarge := fmt.Sprintf("The file must not be larger than %d bytes.", maxSize)
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxSize+multipartOverhead)
+	fileHeader, err := context.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			// If the request body is too large, StatusRequestEntityTooLarge(413) is returned.
+			context.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+			return
+		}
+		// If the form has no file, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		// If the file is too large, StatusRequestEntityTooLarge(413) is returned.
+		context.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	// Detects the type of the file from its content.
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	contentType, err := model.DetectAttachmentContentType(head[:n])
+	if err != nil {
+		// If the file cannot be attached, StatusUnsupportedMediaType(415) is returned.
+		context.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attachment := model.Attachment{
+		EntryID:         entry.ID,
+		UserID:          userId,
+		FileName:        model.NormalizeAttachmentFileName(fileHeader.Filename),
+		ContentType:     contentType,
+		Size:            fileHeader.Size,
+		ThumbnailStatus: model.ThumbnailStatusNone,
+	}
+	var body io.Reader = file
+
+	// Executes helper.ReadImageAttachment function for images.
+	if strings.HasPrefix(contentType, "image/") {
+		profile, err := model.FindProfileByUserId(userId)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		data, err := helper.ReadImageAttachment(file, &attachment, profile.KeepPhotoMetadata)
+		if err != nil {
+			// If the image is broken, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		body = bytes.NewReader(data)
+	}
+
+	// Stores the file in the blob store while computing its SHA-256.
+	storageKey, err := model.NewAttachmentStorageKey(userId)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hash := sha256.New()
+	if err := storage.Blobs.Put(context.Request.Context(), storageKey, io.TeeReader(body, hash), attachment.Size, contentType); err != nil {
+		// If the blob store fails, StatusInternalServerError(500) is returned.
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.Attachment).Save function.
+	attachment.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	attachment.StorageKey = storageKey
+	savedAttachment, err := attachment.Save()
+	if err != nil {
+		// If (*model.Attachment).Save function fails to execute, the blob is deleted and StatusBadRequest(400) is returned.
+		_ = storage.Blobs.Delete(context.Request.Context(), storageKey)
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if savedAttachment.ThumbnailStatus == model.ThumbnailStatusPending {
+		job.WakeThumbnailWorker()
+	}
+	// If (*model.Attachment).Save function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": savedAttachment})
+}
+
+/*
+GetAttachments function:
+
+1. Executes helper.CurrentUserId function, and findEntry function.
+
+2. Executes model.FindAttachmentsByEntryId function.
+
+3. If model.FindAttachmentsByEntryId function is successfully executed, StatusOK(200) is returned.
+*/
+func GetAttachments(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function.
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindAttachmentsByEntryId function.
+	attachments, err := model.FindAttachmentsByEntryId(entry.ID)
+	if err != nil {
+		// If model.FindAttachmentsByEntryId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindAttachmentsByEntryId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+/*
+DownloadAttachment function:
+
+1. Executes helper.CurrentUserId function, findEntry function and findAttachment function.
+
+2. Opens the blob of the size in the query parameter(size): thumb, medium or original(the default).
+If the thumbnails are still being generated, StatusServiceUnavailable(503) is returned with Retry-After.
+If the attachment has no thumbnail of the size, e.g. because it is not an image or it is already small, the original is served.
+
+3. Serves it with http.ServeContent function, which answers Range, If-Range and If-Modified-Since requests.
+Images are shown inline, and other files are downloaded.
+*/
+func DownloadAttachment(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function and findAttachment function.
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+	attachment, ok := findAttachment(context, entry.ID)
+	if !ok {
+		return
+	}
+
+	// Opens the blob of the size in the query parameter(size).
+	size := context.DefaultQuery("size", model.AttachmentSizeOriginal)
+	if size != model.AttachmentSizeOriginal && size != model.AttachmentSizeThumb && size != model.AttachmentSizeMedium {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "size must be thumb, medium or original"})
+		return
+	}
+	storageKey, contentType, etag := attachment.StorageKey, attachment.ContentType, attachment.SHA256
+	if size != model.AttachmentSizeOriginal {
+		if attachment.ThumbnailStatus == model.ThumbnailStatusPending {
+			// If the thumbnails are still being generated, StatusServiceUnavailable(503) is returned.
+			context.Header("Retry-After", "2")
+			context.JSON(http.StatusServiceUnavailable, gin.H{"error": "The thumbnails of the attachment are being generated."})
+			return
+		}
+		if attachment.HasVariant(size) {
+			storageKey, contentType, etag = attachment.VariantKey(size), attachment.ThumbnailContentType, attachment.SHA256+"-"+size
+		}
+	}
+	blob, err := storage.Blobs.Open(context.Request.Context(), storageKey)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		// If the blob has been lost, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("The content of the attachment is missing. [attachmentId: %d]", attachment.ID)})
+		return
+	}
+	if err != nil {
+		// If the blob store fails, StatusInternalServerError(500) is returned.
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer blob.Close()
+
+	// Serves it with http.ServeContent function.
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	context.Header("Content-Type", contentType)
+	context.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	// The content type was detected on upload, so browsers must not guess another one.
+	context.Header("X-Content-Type-Options", "nosniff")
+	context.Header("ETag", fmt.Sprintf(`"%s"`, etag))
+	http.ServeContent(context.Writer, context.Request, attachment.FileName, attachment.CreatedAt, blob)
+}
+
+/*
+DeleteAttachment function:
+
+1. Executes helper.CurrentUserId function, findEntry function and findAttachment function.
+
+2. Executes (*model.Attachment).Delete function, which also deletes the blob.
+
+3. If (*model.Attachment).Delete function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteAttachment(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function and findAttachment function.
+	entry, ok := findEntry(context, userId, model.JournalRoleEditor)
+	if !ok {
+		return
+	}
+	attachment, ok := findAttachment(context, entry.ID)
+	if !ok {
+		return
+	}
+
+	// Executes (*model.Attachment).Delete function.
+	if err := attachment.Delete(context.Request.Context()); err != nil {
+		// If (*model.Attachment).Delete function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Attachment).Delete function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+findAttachment function:
+
+1. Sets the attachmentId to a value extracted from the URL parameter(attachmentId).
+
+2. Executes model.FindAttachment function.
+
+3. If the attachment does not exist or belongs to another entry, StatusNotFound(404) is returned and ok is false.
+*/
+func findAttachment(context *gin.Context, entryId uint) (attachment model.Attachment, ok bool) {
+	attachmentId, ok := parseIdParam(context, "attachmentId")
+	if !ok {
+		return model.Attachment{}, false
+	}
+	attachment, err := model.FindAttachment(entryId, attachmentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the attachment does not exist or belongs to another entry, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("The target attachmentId does not exist. [attachmentId: %d]", attachmentId)})
+		return model.Attachment{}, false
+	}
+	if err != nil {
+		// If model.FindAttachment function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return model.Attachment{}, false
+	}
+	return attachment, true
+}
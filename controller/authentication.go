@@ -5,6 +5,7 @@ import (
 	"diary_api/model"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,7 +22,7 @@ Register function:
 4. If (*model.User).Save function is successfully executed, StatusCreated(201) is returned.
 */
 func Register(context *gin.Context) {
-	var input model.AuthenticationInput
+	var input model.RegistrationInput
 	// Sets the address of a variable(input).
 	ptrInput := &input
 
@@ -40,6 +41,9 @@ func Register(context *gin.Context) {
 		Username: input.Username,
 		Password: input.Password,
 	}
+	if input.Email != "" {
+		user.Email = &input.Email
+	}
 	fmt.Printf("user: %#v\n", user)
 
 	// Sets the address of a variable(user).
@@ -67,11 +71,15 @@ Login function:
 
 3. Executes (*model.User).ValidatePassword function.
 
-4. Executes helper.GenerateJWT function.
-
-5. If helper.GenerateJWT function is successfully executed, StatusOK(200) is returned.
+4. Executes respondWithSessionToken function.
+If the query parameter(mode) is "cookie", the JWT is set in an HttpOnly cookie instead of being returned.
 */
 func Login(context *gin.Context) {
+	cookieMode, ok := parseSessionMode(context)
+	if !ok {
+		return
+	}
+
 	var input model.AuthenticationInput
 	// Sets the address of a variable(input).
 	ptrInput := &input
@@ -105,9 +113,127 @@ func Login(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if user.PasswordResetRequired {
+		// If a password reset has been required, StatusForbidden(403) is returned until POST /auth/password is called.
+		context.JSON(http.StatusForbidden, gin.H{"error": "A password reset is required", "password_reset_required": true})
+		return
+	}
+
+	// Executes respondWithSessionToken function.
+	respondWithSessionToken(context, user, cookieMode)
+}
+
+/*
+ResetPassword function:
+
+1. Executes the validation.
+
+2. Executes model.FindUserByUsername function and (*model.User).ValidatePassword function with the current password.
+
+3. Executes (*model.User).UpdatePassword function with the new password.
+
+4. If (*model.User).UpdatePassword function is successfully executed, StatusNoContent(204) is returned.
+
+It is used by users whose password reset has been required by an admin.
+*/
+func ResetPassword(context *gin.Context) {
+	var input model.PasswordResetInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Executes helper.GenerateJWT function.
-	jwt, err := helper.GenerateJWT(user)
+	// Executes model.FindUserByUsername function and (*model.User).ValidatePassword function with the current password.
+	user, err := model.FindUserByUsername(input.Username)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := user.ValidatePassword(input.Password); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.IsDisabled() {
+		context.JSON(http.StatusForbidden, gin.H{"error": model.ErrUserDisabled.Error()})
+		return
+	}
+	if input.NewPassword == input.Password {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "The new password must be different from the current password"})
+		return
+	}
+
+	// Executes (*model.User).UpdatePassword function with the new password.
+	if err := user.UpdatePassword(input.NewPassword); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.User).UpdatePassword function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+parseSessionMode function:
+
+1. Returns true if the query parameter(mode) is "cookie".
+
+2. If the cookie mode is requested but not enabled, StatusBadRequest(400) is returned and ok is false.
+*/
+func parseSessionMode(context *gin.Context) (cookieMode bool, ok bool) {
+	switch context.Query("mode") {
+	case "", "token":
+		return false, true
+	case "cookie":
+		if !helper.CookieModeEnabled() {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "The cookie mode is not enabled"})
+			return false, false
+		}
+		return true, true
+	}
+	context.JSON(http.StatusBadRequest, gin.H{"error": "mode must be token or cookie"})
+	return false, false
+}
+
+/*
+respondWithSessionToken function:
+
+1. If the user has been disabled, StatusForbidden(403) is returned.
+
+2. Records a new session with the user agent and IP address of the request.
+
+3. Executes helper.GenerateJWT function with the session.
+
+4. In the cookie mode, sets the JWT and the CSRF token in cookies, and StatusOK(200) is returned with the CSRF token only.
+
+5. Otherwise, StatusOK(200) is returned with the JWT.
+
+The session lets the user see where they are logged in and revoke the token later.
+*/
+func respondWithSessionToken(context *gin.Context, user model.User, cookieMode bool) {
+	if user.IsDisabled() {
+		// If the user has been disabled, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": model.ErrUserDisabled.Error()})
+		return
+	}
+	now := time.Now()
+	// Records a new session with the user agent and IP address of the request.
+	session := model.Session{
+		UserID:     user.ID,
+		UserAgent:  context.Request.UserAgent(),
+		IPAddress:  context.ClientIP(),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(helper.TokenTTL()),
+	}
+	savedSession, err := session.Save()
+	if err != nil {
+		// If (*model.Session).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.GenerateJWT function with the session.
+	jwt, err := helper.GenerateJWT(user, *savedSession)
 	if err != nil {
 		// If helper.GenerateJWT function fails to execute, StatusBadRequest(400) is returned.
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -115,6 +241,13 @@ func Login(context *gin.Context) {
 	}
 	fmt.Printf("jwt: %#v\n", jwt)
 
-	// If helper.GenerateJWT function is successfully executed, StatusOK(200) is returned.
+	if cookieMode {
+		// In the cookie mode, sets the JWT and the CSRF token in cookies.
+		// The JWT is not returned, so that JavaScript never has access to it.
+		csrfToken := helper.SetSessionCookies(context, jwt, savedSession.ID)
+		context.JSON(http.StatusOK, gin.H{"csrf_token": csrfToken})
+		return
+	}
+	// Otherwise, StatusOK(200) is returned with the JWT.
 	context.JSON(http.StatusOK, gin.H{"jwt": jwt})
 }
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+GetCalendar function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Sets the year and the month from the query parameters(year, month).
+They default to the current month in the time zone of the user's profile.
+
+3. Executes model.FindCalendarMonth function.
+
+4. If model.FindCalendarMonth function is successfully executed, StatusOK(200) is returned with the count and the IDs of the entries of each day.
+*/
+func GetCalendar(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Sets the year and the month from the query parameters(year, month).
+	location, err := model.FindUserLocation(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	today := model.Today(location)
+	year, month := today.Year(), today.Month()
+	if value := context.Query("year"); value != "" {
+		if year, err = strconv.Atoi(value); err != nil || year < 1 || year > 9999 {
+			// If the query parameter is invalid, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": "year must be between 1 and 9999"})
+			return
+		}
+	}
+	if value := context.Query("month"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 || number > 12 {
+			// If the query parameter is invalid, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": "month must be between 1 and 12"})
+			return
+		}
+		month = time.Month(number)
+	}
+
+	// Executes model.FindCalendarMonth function.
+	days, err := model.FindCalendarMonth(userId, year, month)
+	if err != nil {
+		// If model.FindCalendarMonth function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindCalendarMonth function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"year": year, "month": int(month), "data": days})
+}
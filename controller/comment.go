@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetComments function:
+
+1. Executes helper.CurrentUserId function and findEntry function. Everyone who can read the entry can read its comments.
+
+2. Executes model.FindCommentThreads function.
+
+3. If model.FindCommentThreads function is successfully executed, StatusOK(200) is returned with the comments as threads.
+*/
+func GetComments(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindCommentThreads function.
+	comments, err := model.FindCommentThreads(entry.ID)
+	if err != nil {
+		// If model.FindCommentThreads function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindCommentThreads function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": comments})
+}
+
+/*
+AddComment function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and findEntry function. Everyone who can read the entry can comment on it, viewers too.
+Drafts cannot be commented on, StatusConflict(409) is returned.
+
+3. Executes (*model.Comment).Save function. If parent_id is set, the comment is a reply to that comment.
+
+4. If (*model.Comment).Save function is successfully executed, StatusCreated(201) is returned.
+*/
+func AddComment(context *gin.Context) {
+	var input model.CommentInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+	if entry.Status == model.EntryStatusDraft {
+		// If the entry is a draft, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": "Drafts cannot be commented on. Publish the draft first."})
+		return
+	}
+
+	// Executes (*model.Comment).Save function.
+	comment := model.Comment{EntryID: entry.ID, UserID: userId, ParentID: input.ParentID, Content: input.Content}
+	savedComment, err := comment.Save()
+	if errors.Is(err, model.ErrCommentParent) {
+		// If the parent comment does not exist on the entry, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		// If (*model.Comment).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Comment).Save function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": savedComment})
+}
+
+/*
+DeleteComment function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. Sets the commentId to a value extracted from the URL parameter, and executes model.FindComment function.
+
+3. Only the author of the comment, or the author of the entry(moderation), can delete the comment. Otherwise, StatusForbidden(403) is returned.
+
+4. Executes (*model.Comment).Delete function, which soft-deletes the comment and keeps its replies.
+
+5. If (*model.Comment).Delete function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteComment(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Sets the commentId to a value extracted from the URL parameter, and executes model.FindComment function.
+	commentId, ok := parseIdParam(context, "commentId")
+	if !ok {
+		return
+	}
+	comment, err := model.FindComment(entry.ID, commentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target commentId does not exist. [commentId: %d]", commentId)
+		// If the comment does not exist or belongs to another entry, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.FindComment function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if comment.UserID != userId && entry.UserID != userId {
+		// If the user is neither the author of the comment nor the author of the entry, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": "Only the author of the comment or of the entry can delete the comment."})
+		return
+	}
+
+	// Executes (*model.Comment).Delete function.
+	if err := comment.Delete(); err != nil {
+		// If (*model.Comment).Delete function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Comment).Delete function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+GetReactions function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. Executes model.FindReactionSummaries function.
+
+3. If model.FindReactionSummaries function is successfully executed, StatusOK(200) is returned with the reactions by emoji.
+*/
+func GetReactions(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindReactionSummaries function.
+	reactions, err := model.FindReactionSummaries(entry.ID, userId)
+	if err != nil {
+		// If model.FindReactionSummaries function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindReactionSummaries function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": reactions})
+}
+
+/*
+AddReaction function:
+
+1. Executes the validation, and model.NormalizeEmoji function.
+
+2. Executes helper.CurrentUserId function and findEntry function. Drafts cannot be reacted to, StatusConflict(409) is returned.
+
+3. Executes model.AddReaction function.
+
+4. If the reaction is added, StatusCreated(201) is returned. If the user had already reacted with the emoji, StatusOK(200) is returned.
+*/
+func AddReaction(context *gin.Context) {
+	var input model.ReactionInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	emoji, err := model.NormalizeEmoji(input.Emoji)
+	if err != nil {
+		// If the reaction is not an emoji, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+	if entry.Status == model.EntryStatusDraft {
+		// If the entry is a draft, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": "Drafts cannot be reacted to. Publish the draft first."})
+		return
+	}
+
+	// Executes model.AddReaction function.
+	added, err := model.AddReaction(entry.ID, userId, emoji)
+	if err != nil {
+		// If model.AddReaction function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	context.JSON(status, gin.H{"data": gin.H{"entry_id": entry.ID, "emoji": emoji}})
+}
+
+/*
+RemoveReaction function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. Executes model.RemoveReaction function with the emoji in the URL parameter. Users can only remove their own reactions.
+
+3. If model.RemoveReaction function is successfully executed, StatusNoContent(204) is returned.
+*/
+func RemoveReaction(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes model.RemoveReaction function.
+	emoji := context.Param("emoji")
+	err = model.RemoveReaction(entry.ID, userId, emoji)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("You have not reacted with the target emoji. [emoji: %s]", emoji)
+		// If the user has not reacted with the emoji, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.RemoveReaction function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.RemoveReaction function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+CreateDraft function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and model.FindProfileByUserId function.
+
+3. Sets the journal, the entry date and the time zone of the draft, as AddEntry function does.
+
+4. Executes (*model.Entry).Save function with the draft status.
+
+5. If (*model.Entry).Save function is successfully executed, StatusCreated(201) is returned with the ETag of the draft.
+*/
+func CreateDraft(context *gin.Context) {
+	var input model.DraftInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and model.FindProfileByUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := model.FindProfileByUserId(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The user must be an editor or the owner of the journal the draft is written in.
+	if input.JournalID != nil {
+		if _, ok := requireJournalRole(context, *input.JournalID, userId, model.JournalRoleEditor); !ok {
+			return
+		}
+	}
+
+	// Sets the entry date and the time zone of the draft.
+	draft := model.Entry{Content: input.Content, UserID: userId, Status: model.EntryStatusDraft, Format: entryFormat(input.Format), JournalID: input.JournalID, Encrypted: input.Encrypted}
+	if err := draft.ApplyEntryDate(input.EntryDate, input.TimeZone, profile.TimeZone); err != nil {
+		// If the entry date or the time zone is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.Entry).Save function with the draft status.
+	savedDraft, err := draft.Save()
+	if err != nil {
+		// If (*model.Entry).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Entry).Save function is successfully executed, StatusCreated(201) is returned.
+	context.Header("ETag", entryETag(*savedDraft))
+	context.JSON(http.StatusCreated, gin.H{"data": savedDraft})
+}
+
+/*
+GetDrafts function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindEntries function for the drafts only, personal or in any journal.
+
+3. If the query parameter(render) is "html", the content of every draft is also returned as sanitized HTML.
+Encrypted drafts cannot be rendered, so StatusConflict(409) is returned if any of the drafts is encrypted.
+
+4. If model.FindEntries function is successfully executed, StatusOK(200) is returned.
+*/
+func GetDrafts(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	renderHTML, ok := parseRenderQuery(context)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindEntries function for the drafts only.
+	drafts, err := model.FindEntries(model.EntryFilter{UserID: userId, Statuses: []string{model.EntryStatusDraft}, AnyJournal: true})
+	if err != nil {
+		// If model.FindEntries function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If the query parameter(render) is "html", the content of every draft is also returned as sanitized HTML.
+	if renderHTML {
+		if !rejectEncryptedEntries(context, drafts, "render") {
+			return
+		}
+		for i := range drafts {
+			renderEntryHTML(&drafts[i])
+		}
+	}
+	// If model.FindEntries function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": drafts})
+}
+
+/*
+AutosaveDraft function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and findEntry function, so that only the author of the draft gets past this point.
+
+3. Executes model.AutosaveDraft function.
+If-Match is optional. If it is sent and the draft has been saved elsewhere since, StatusPreconditionFailed(412) is returned with the current draft.
+
+4. If model.AutosaveDraft function is successfully executed, StatusOK(200) is returned with the new version only, to keep the response small.
+*/
+func AutosaveDraft(context *gin.Context) {
+	var input model.DraftAutosaveInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If the entry does not exist or is a draft of another user, StatusNotFound(404) is returned.
+	current, ok := findEntry(context, userId, model.JournalRoleEditor)
+	if !ok {
+		return
+	}
+	draftId := current.ID
+
+	// Executes model.AutosaveDraft function.
+	expectedVersion, ok := parseIfMatchVersion(context, current)
+	if !ok {
+		return
+	}
+	draft, err := model.AutosaveDraft(userId, draftId, *input.Content, expectedVersion)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target draftId does not exist. [draftId: %d]", draftId)
+		// If the draft does not exist or has been published, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if errors.Is(err, model.ErrEntryVersionConflict) {
+		// If the draft has been saved elsewhere since, StatusPreconditionFailed(412) is returned.
+		respondToCurrentEntryVersion(context, userId, draftId)
+		return
+	}
+	if err != nil {
+		// If model.AutosaveDraft function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.AutosaveDraft function is successfully executed, StatusOK(200) is returned.
+	context.Header("ETag", entryETag(draft))
+	context.JSON(http.StatusOK, gin.H{"id": draft.ID, "version": draft.Version, "updated_at": draft.UpdatedAt})
+}
+
+/*
+PublishDraft function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. If the entry is not a draft, StatusConflict(409) is returned. If its content is empty, StatusBadRequest(400) is returned.
+
+3. Sets the status of the entry to published, and executes (*model.Entry).Update function, which creates its first revision.
+
+4. If (*model.Entry).Update function is successfully executed, StatusOK(200) is returned.
+*/
+func PublishDraft(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	draft, ok := findEntry(context, userId, model.JournalRoleEditor)
+	if !ok {
+		return
+	}
+	if ifMatch := context.GetHeader("If-Match"); ifMatch != "" && !matchesEntryVersion(ifMatch, draft) {
+		respondToEntryVersionConflict(context, draft)
+		return
+	}
+
+	if draft.Status != model.EntryStatusDraft {
+		// If the entry is not a draft, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": "The entry has already been published."})
+		return
+	}
+	if strings.TrimSpace(draft.Content) == "" {
+		// If the content is empty, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "An empty draft cannot be published."})
+		return
+	}
+
+	// Sets the status of the entry to published, and executes (*model.Entry).Update function.
+	draft.Status = model.EntryStatusPublished
+	entry, err := draft.Update(userId)
+	if errors.Is(err, model.ErrEntryVersionConflict) {
+		respondToCurrentEntryVersion(context, userId, draft.ID)
+		return
+	}
+	if err != nil {
+		// If (*model.Entry).Update function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Entry).Update function is successfully executed, StatusOK(200) is returned.
+	context.Header("ETag", entryETag(*entry))
+	context.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+/*
+parseIfMatchVersion function:
+
+1. If the request has no If-Match header, the version is nil.
+
+2. Otherwise, parses the version of the entry from the ETag in If-Match.
+
+3. If If-Match is not an ETag of the entry, StatusPreconditionFailed(412) is returned with the entry and ok is false.
+The entry must have been loaded by findEntry function, which checks that the user can see it.
+*/
+func parseIfMatchVersion(context *gin.Context, entry model.Entry) (version *int, ok bool) {
+	// A weak ETag(W/"...") never matches, because If-Match uses the strong comparison.
+	ifMatch := strings.TrimSpace(context.GetHeader("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, true
+	}
+	prefix := fmt.Sprintf(`"%d-`, entry.ID)
+	if strings.HasPrefix(ifMatch, prefix) && strings.HasSuffix(ifMatch, `"`) {
+		// The ETag of GET /api/entry/:id has a hash after the version, which is ignored.
+		version, _, _ := strings.Cut(ifMatch[len(prefix):len(ifMatch)-1], "-")
+		if number, err := strconv.Atoi(version); err == nil {
+			return &number, true
+		}
+	}
+	respondToEntryVersionConflict(context, entry)
+	return nil, false
+}
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetEncryptionKey function:
+
+1. Executes helper.CurrentUserId function and model.FindEncryptionKeyByUserId function.
+
+2. If model.FindEncryptionKeyByUserId function is successfully executed, StatusOK(200) is returned with the wrapped key and the parameters to derive the key that unwraps it.
+The server cannot unwrap it: only a client that knows the passphrase can.
+*/
+func GetEncryptionKey(context *gin.Context) {
+	// Executes helper.CurrentUserId function and model.FindEncryptionKeyByUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := model.FindEncryptionKeyByUserId(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the user has not set up a key, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": "The encryption key has not been set up."})
+		return
+	}
+	if err != nil {
+		// If model.FindEncryptionKeyByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindEncryptionKeyByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": key})
+}
+
+/*
+PutEncryptionKey function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and model.FindEncryptionKeyByUserId function.
+
+3. Executes (*model.EncryptionKey).Apply function, which checks the costs of the key derivation function and the lengths of the values.
+
+4. Executes (*model.EncryptionKey).Save function.
+The first key is created with StatusCreated(201). Later, the same data key wrapped with a new passphrase replaces it with StatusOK(200),
+and the encrypted entries stay readable, because they are encrypted with the data key, which does not change.
+*/
+func PutEncryptionKey(context *gin.Context) {
+	var input model.EncryptionKeyInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and model.FindEncryptionKeyByUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := model.FindEncryptionKeyByUserId(userId)
+	created := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil && !created {
+		// If model.FindEncryptionKeyByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key.UserID = userId
+
+	// Executes (*model.EncryptionKey).Apply function.
+	if err := key.Apply(input); err != nil {
+		// If the parameters are too weak or the values are malformed, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.EncryptionKey).Save function.
+	savedKey, err := key.Save()
+	if err != nil {
+		// If (*model.EncryptionKey).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if created {
+		// If the key is created, StatusCreated(201) is returned.
+		context.JSON(http.StatusCreated, gin.H{"data": savedKey})
+		return
+	}
+	// If the key is replaced, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": savedKey})
+}
+
+/*
+DeleteEncryptionKey function:
+
+1. Executes helper.CurrentUserId function and model.DeleteEncryptionKey function.
+
+2. If the user still has encrypted entries, StatusConflict(409) is returned, because they could never be decrypted again.
+
+3. If model.DeleteEncryptionKey function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteEncryptionKey(context *gin.Context) {
+	// Executes helper.CurrentUserId function and model.DeleteEncryptionKey function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = model.DeleteEncryptionKey(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the user has not set up a key, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": "The encryption key has not been set up."})
+		return
+	}
+	if errors.Is(err, model.ErrEncryptionKeyInUse) {
+		// If the user still has encrypted entries, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		// If model.DeleteEncryptionKey function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.DeleteEncryptionKey function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+rejectEncryptedEntry function:
+
+1. If the entry is encrypted, StatusConflict(409) is returned with the action(e.g. "render") the server cannot do without its content, and ok is false.
+*/
+func rejectEncryptedEntry(context *gin.Context, entry model.Entry, action string) (ok bool) {
+	if !entry.Encrypted {
+		return true
+	}
+	errorMessage := fmt.Sprintf("The entry is end-to-end encrypted, so the server cannot %s it. [entryId: %d]", action, entry.ID)
+	context.JSON(http.StatusConflict, gin.H{"error": errorMessage})
+	return false
+}
+
+/*
+rejectEncryptedEntries function:
+
+1. If any of the entries is encrypted, StatusConflict(409) is returned with the action(e.g. "render") and the ids of the encrypted entries, and ok is false.
+*/
+func rejectEncryptedEntries(context *gin.Context, entries []model.Entry, action string) (ok bool) {
+	var entryIds []string
+	for _, entry := range entries {
+		if entry.Encrypted {
+			entryIds = append(entryIds, strconv.FormatUint(uint64(entry.ID), 10))
+		}
+	}
+	if len(entryIds) == 0 {
+		return true
+	}
+	errorMessage := fmt.Sprintf("Some entries are end-to-end encrypted, so the server cannot %s them. [entryIds: %s]", action, strings.Join(entryIds, ", "))
+	context.JSON(http.StatusConflict, gin.H{"error": errorMessage})
+	return false
+}
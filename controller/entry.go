@@ -3,6 +3,7 @@ package controller
 import (
 	"diary_api/helper"
 	"diary_api/model"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,46 +16,74 @@ AddEntry function:
 
 1. Executes the validation.
 
-2. Executes helper.CurrentUser function.
+2. Executes helper.CurrentUserId function and model.FindProfileByUserId function.
 
-3. Sets userId to the value of the pointer variable(ptrInput).
+3. If the entry is written in a journal, executes requireJournalRole function. The user must be an editor or the owner of the journal.
 
-4. Executes (*model.Entry).Save function.
+4. Sets the entry date and the time zone of the entry.
+They default to today in the time zone of the user's profile, and the entry date can be in the past(backdating).
 
-5. If (*model.Entry).Save function is successfully executed, StatusCreated(201) is returned.
+5. Sets the tags of the entry, creating the tags the user does not have yet.
+
+6. Sets the mood and the custom fields of the entry. The fields must match the fields the user has defined.
+
+7. Executes (*model.Entry).Save function. If encrypted is true, the content must be an envelope encrypted with the key of the user.
+
+8. If (*model.Entry).Save function is successfully executed, StatusCreated(201) is returned.
 */
 func AddEntry(context *gin.Context) {
-	var input model.Entry
-	// Sets the address of a variable(input).
-	ptrInput := &input
+	var input model.EntryInput
 	// Executes the validation.
 	// FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
-	if err := context.ShouldBindJSON(ptrInput); err != nil {
+	if err := context.ShouldBindJSON(&input); err != nil {
 		// If the validation fails, StatusBadRequest(400) is returned.
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// If the validation passes, the variable is filled with the request data.
-	fmt.Printf("input: %#v\n", input)
-
-	// Executes helper.CurrentUser function.
-	// It returns the user struct.
-	user, err := helper.CurrentUser(context)
 
+	// Executes helper.CurrentUserId function and model.FindProfileByUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := model.FindProfileByUserId(userId)
 	if err != nil {
-		// If helper.CurrentUser function fails to execute, an error is returned..
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Sets userId to the value of the pointer variable(ptrInput).
-	(*ptrInput).UserID = user.ID
+	// The user must be an editor or the owner of the journal the entry is written in.
+	if input.JournalID != nil {
+		if _, ok := requireJournalRole(context, *input.JournalID, userId, model.JournalRoleEditor); !ok {
+			return
+		}
+	}
 
-	// Executes (*model.Entry).Save function.
-	// It returns the address of the pointer variable(ptrInput).
-	savedEntry, err := ptrInput.Save()
-	fmt.Printf("savedEntry: %#v\n", savedEntry)
+	// Sets the entry date and the time zone of the entry.
+	entry := model.Entry{Content: input.Content, UserID: userId, Status: model.EntryStatusPublished, Format: entryFormat(input.Format), JournalID: input.JournalID, Encrypted: input.Encrypted}
+	if err := entry.ApplyEntryDate(input.EntryDate, input.TimeZone, profile.TimeZone); err != nil {
+		// If the entry date or the time zone is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Sets the tags of the entry.
+	if err := entry.ApplyTags(input.Tags, input.ExtractHashtags); err != nil {
+		// If a tag is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Sets the mood and the custom fields of the entry.
+	entry.Mood = input.Mood
+	if err := entry.ApplyFields(input.Fields); err != nil {
+		// If a field does not match the fields the user has defined, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	// Executes (*model.Entry).Save function.
+	savedEntry, err := entry.Save()
 	if err != nil {
 		// If (*model.Entry).Save function fails to execute, StatusBadRequest(400) is returned.
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -67,68 +96,403 @@ func AddEntry(context *gin.Context) {
 /*
 GetAllEntries function:
 
-1. Executes helper.CurrentUser function.
+1. Executes helper.CurrentUserId function.
+
+2. Sets the filter from the query parameters.
+date is a local date in the format "2006-01-02", and from and to are an inclusive range of local dates.
+tag can be repeated. By default an entry needs every tag, and with tag_mode=any it needs any of them.
+mood is the mood of the entries, and field[key]=value matches the value of a custom field.
+Drafts are excluded unless include_drafts is true.
+journal_id lists the entries of a journal the user is a member of. Without it, only the personal entries of the user are listed.
 
-2. If helper.CurrentUser function is successfully executed, StatusOK(200) is returned.
+3. Executes model.FindEntries function.
+
+4. If the query parameter(render) is "html", the content of every entry is also returned as sanitized HTML.
+Encrypted entries cannot be rendered, so StatusConflict(409) is returned if any of the entries is encrypted.
+
+5. If the query parameter(group) is "date", the entries are grouped by their local date.
+
+6. If model.FindEntries function is successfully executed, StatusOK(200) is returned.
 */
 func GetAllEntries(context *gin.Context) {
-	// Executes helper.CurrentUser function.
-	// It returns the user struct.
-	user, err := helper.CurrentUser(context)
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	// Sets the filter from the query parameters(date, from, to, tag, tag_mode, mood, field[key], include_drafts, journal_id).
+	filter := model.EntryFilter{UserID: userId}
+	var ok bool
+	if filter.JournalID, ok = parseJournalQuery(context, userId); !ok {
+		return
+	}
+	if filter.Date, ok = parseDateQuery(context, "date"); !ok {
+		return
+	}
+	if filter.From, ok = parseDateQuery(context, "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseDateQuery(context, "to"); !ok {
+		return
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(filter.To.Time) {
+		// If the range is empty, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if filter.Tags, err = model.NormalizeTagNames(context.QueryArray("tag")); err != nil {
+		// If a tag is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.TagMode = context.DefaultQuery("tag_mode", model.TagModeAll)
+	if filter.TagMode != model.TagModeAll && filter.TagMode != model.TagModeAny {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "tag_mode must be all or any"})
+		return
+	}
+	if value := context.Query("mood"); value != "" {
+		mood, err := strconv.Atoi(value)
+		if err != nil || mood < 1 || mood > 5 {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "mood must be between 1 and 5"})
+			return
+		}
+		filter.Mood = &mood
+	}
+	if filter.Fields, err = parseFieldQuery(context, userId); err != nil {
+		// If a field is unknown or its value is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if context.Query("include_drafts") == "true" {
+		filter.Statuses = []string{model.EntryStatusPublished, model.EntryStatusDraft}
+	}
+	renderHTML, ok := parseRenderQuery(context)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindEntries function.
+	entries, err := model.FindEntries(filter)
 	if err != nil {
-		// If helper.CurrentUser function fails to execute, StatusBadRequest(400) is returned.
+		// If model.FindEntries function fails to execute, StatusBadRequest(400) is returned.
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// If helper.CurrentUser function is successfully executed, StatusOK(200) is returned.
-	context.JSON(http.StatusOK, gin.H{"data": user.Entries})
+	// If the query parameter(render) is "html", the content of every entry is also returned as sanitized HTML.
+	if renderHTML {
+		if !rejectEncryptedEntries(context, entries, "render") {
+			return
+		}
+		for i := range entries {
+			renderEntryHTML(&entries[i])
+		}
+	}
+
+	// If the query parameter(group) is "date", the entries are grouped by their local date.
+	if context.Query("group") == "date" {
+		context.JSON(http.StatusOK, gin.H{"data": model.GroupEntriesByDate(entries)})
+		return
+	}
+	// If model.FindEntries function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": entries})
 }
 
 /*
 GetEntry function:
 
-1. Sets the entryId to a value extracted from the URL parameter.
+1. Executes helper.CurrentUserId function.
+
+2. Executes findEntry function.
+
+3. If the query parameter(render) is "html", the content is also returned as sanitized HTML.
+Encrypted entries cannot be rendered, so StatusConflict(409) is returned.
 
-2. Executes helper.CurrentUser function.
+4. Sets the ETag of the response(entryRepresentationETag). If If-None-Match matches it, StatusNotModified(304) is returned.
+
+5. If findEntry function is successfully executed, StatusOK(200) is returned.
 */
 func GetEntry(context *gin.Context) {
-	// Sets the entryId to a value extracted from the URL parameter.
-	entryId, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
 	if err != nil {
-		// If the URL parameter is invalid, StatusBadRequest(400) is returned.
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes findEntry function.
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
 	}
-	fmt.Printf("entryId: %#v\n", strconv.FormatUint(entryId, 10))
 
-	// Executes helper.CurrentUser function.
-	// It returns the user struct.
-	user, err := helper.CurrentUser(context)
+	// If the query parameter(render) is "html", the content is also returned as sanitized HTML.
+	renderHTML, ok := parseRenderQuery(context)
+	if !ok {
+		return
+	}
+	if renderHTML {
+		if !rejectEncryptedEntry(context, entry, "render") {
+			return
+		}
+		renderEntryHTML(&entry)
+	}
+
+	// Sets the ETag of the response.
+	body := gin.H{"entry": entry}
+	etag, err := entryRepresentationETag(entry, body)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.Header("ETag", etag)
+	if ifNoneMatch := context.GetHeader("If-None-Match"); ifNoneMatch != "" && matchesETag(ifNoneMatch, etag) {
+		// If the client already has the current response, StatusNotModified(304) is returned without a body.
+		context.Status(http.StatusNotModified)
+		return
+	}
+	// StatusOK(200) is returned.
+	context.JSON(http.StatusOK, body)
+}
+
+/*
+UpdateEntry function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and findEntry function.
+If-Match must match the ETag of the entry. Otherwise, StatusPreconditionFailed(412) is returned with the current entry.
 
+3. Sets the fields that are sent in the input.
+If extract_hashtags is true, the #hashtags in the content are added to the tags. Encrypted entries have no hashtags the server can read,
+so StatusConflict(409) is returned. The content of an encrypted entry must be an envelope encrypted with the key of the user.
+
+4. Executes (*model.Entry).Update function.
+
+5. If (*model.Entry).Update function is successfully executed, StatusOK(200) is returned.
+*/
+func UpdateEntry(context *gin.Context) {
+	var input model.EntryUpdateInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
 	if err != nil {
-		// If helper.CurrentUser function fails to execute, StatusBadRequest(400) is returned.
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	entry, ok := findEntry(context, userId, model.JournalRoleEditor)
+	if !ok {
+		return
+	}
+	// Requires If-Match with the current ETag of the entry.
+	if !requireEntryETag(context, entry) {
+		return
+	}
 
-	var result model.Entry
-	// Extracs entries with matching entryId from the entry list of the target user.
-	for _, entry := range user.Entries {
-		if entry.ID == uint(entryId) {
-			result = entry
-			break
+	// Sets the fields that are sent in the input.
+	if input.Content != nil {
+		entry.Content = *input.Content
+	}
+	if input.Format != nil {
+		entry.Format = entryFormat(*input.Format)
+	}
+	if err := entry.ApplyEntryDate(input.EntryDate, input.TimeZone, model.DefaultTimeZone); err != nil {
+		// If the entry date or the time zone is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Tags != nil || input.ExtractHashtags {
+		// If the tags are not sent, the hashtags are added to the current tags.
+		names := []string{}
+		if input.Tags != nil {
+			names = *input.Tags
+		} else {
+			for _, tag := range entry.Tags {
+				names = append(names, tag.Name)
+			}
+		}
+		if input.ExtractHashtags && !rejectEncryptedEntry(context, entry, "extract hashtags from") {
+			return
+		}
+		if err := entry.ApplyTags(names, input.ExtractHashtags); err != nil {
+			// If a tag is invalid, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Mood != nil {
+		// A mood of 0 removes the mood.
+		entry.Mood = input.Mood
+		if *input.Mood == 0 {
+			entry.Mood = nil
+		}
+	}
+	if input.Fields != nil {
+		if err := entry.ApplyFields(*input.Fields); err != nil {
+			// If a field does not match the fields the user has defined, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
-	fmt.Printf("result: %#v\n", result)
+	// Executes (*model.Entry).Update function.
+	updatedEntry, err := entry.Update(userId)
+	if errors.Is(err, model.ErrEntryVersionConflict) {
+		// If the entry has been updated by another request meanwhile, StatusPreconditionFailed(412) is returned.
+		respondToCurrentEntryVersion(context, userId, entry.ID)
+		return
+	}
+	if err != nil {
+		// If (*model.Entry).Update function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Entry).Update function is successfully executed, StatusOK(200) is returned with the new ETag.
+	context.Header("ETag", entryETag(*updatedEntry))
+	context.JSON(http.StatusOK, gin.H{"data": updatedEntry})
+}
 
-	if uint(entryId) != result.ID {
-		errorMessage := fmt.Sprintf("The target entryId does not exist. [entryId: %s]", strconv.FormatUint(entryId, 10))
-		// If entryId and result.ID do not match, StatusNotFound(404) is returned.
-		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+/*
+respondToCurrentEntryVersion function:
+
+1. Executes model.FindEntryById function and model.FindEntryRole function to load the version that won.
+
+2. Executes respondToEntryVersionConflict function. If the user can no longer see the entry, the entry is left out of the response.
+*/
+func respondToCurrentEntryVersion(context *gin.Context, userId uint, entryId uint) {
+	entry, err := model.FindEntryById(entryId)
+	role := ""
+	if err == nil && entry.ID != 0 {
+		role, err = model.FindEntryRole(&entry, userId)
+	}
+	if err != nil || role == "" {
+		context.JSON(http.StatusPreconditionFailed, gin.H{"error": model.ErrEntryVersionConflict.Error()})
 		return
 	}
+	respondToEntryVersionConflict(context, entry)
+}
 
-	// StatusOK(200) is returned.
-	context.JSON(http.StatusOK, gin.H{"entry": result})
+/*
+entryFormat function:
+
+1. Returns the format of the content, model.EntryFormatPlain if it is not sent.
+*/
+func entryFormat(format string) string {
+	if format == "" {
+		return model.EntryFormatPlain
+	}
+	return format
+}
+
+/*
+renderEntryHTML function:
+
+1. Renders the content of the entry as sanitized HTML, as Markdown(CommonMark) or as plain text depending on its format.
+Encrypted entries are left without HTML, and clients render them after decrypting them.
+*/
+func renderEntryHTML(entry *model.Entry) {
+	if entry.Encrypted {
+		return
+	}
+	if entry.Format == model.EntryFormatCommonMark {
+		entry.HTML = helper.RenderMarkdown(entry.Content)
+		return
+	}
+	entry.HTML = helper.RenderPlainText(entry.Content)
+}
+
+/*
+findEntry function:
+
+1. Sets the entryId to a value extracted from the URL parameter.
+
+2. Executes model.FindEntryById function and model.FindEntryRole function.
+
+3. If the entry does not exist or the user cannot see it, StatusNotFound(404) is returned and ok is false.
+Entries of other users are not told apart from missing entries.
+
+4. If the user can see the entry but their role does not allow the action(required), StatusForbidden(403) is returned and ok is false.
+The author of a personal entry is its owner, and the role for an entry of a journal is the role of the user in the journal.
+*/
+func findEntry(context *gin.Context, userId uint, required string) (entry model.Entry, ok bool) {
+	// Sets the entryId to a value extracted from the URL parameter.
+	entryId, ok := parseIdParam(context, "id")
+	if !ok {
+		return model.Entry{}, false
+	}
+
+	// Executes model.FindEntryById function and model.FindEntryRole function.
+	entry, err := model.FindEntryById(entryId)
+	if err != nil {
+		// If model.FindEntryById function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return model.Entry{}, false
+	}
+	role := ""
+	if entry.ID != 0 {
+		if role, err = model.FindEntryRole(&entry, userId); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return model.Entry{}, false
+		}
+	}
+	if role == "" {
+		errorMessage := fmt.Sprintf("The target entryId does not exist. [entryId: %d]", entryId)
+		// If the entry does not exist or the user cannot see it, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return model.Entry{}, false
+	}
+	if !model.HasJournalRole(role, required) {
+		errorMessage := fmt.Sprintf("The %s role is required for this entry, but your role is %s. [entryId: %d]", required, role, entryId)
+		// If the role of the user does not allow the action, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": errorMessage})
+		return model.Entry{}, false
+	}
+	return entry, true
+}
+
+/*
+parseFieldQuery function:
+
+1. Sets the values of the custom fields from the query parameters(field[key]=value).
+
+2. Parses each value by the type of the field the user has defined.
+*/
+func parseFieldQuery(context *gin.Context, userId uint) (map[string]interface{}, error) {
+	query := context.QueryMap("field")
+	if len(query) == 0 {
+		return nil, nil
+	}
+	definitions, err := model.FindFieldDefinitionsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	for key, value := range query {
+		found := false
+		for _, definition := range definitions {
+			if definition.Key == key {
+				// Parses each value by the type of the field.
+				if fields[key], err = definition.ParseValue(value); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field: %q", key)
+		}
+	}
+	return fields, nil
 }
@@ -0,0 +1,355 @@
+package controller
+
+import (
+	"archive/zip"
+	"diary_api/helper"
+	"diary_api/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Formats of the entry export.
+const (
+	exportFormatMarkdown = "md"
+	exportFormatJSON     = "json"
+	exportFormatCSV      = "csv"
+	exportFormatPDF      = "pdf"
+)
+
+// exportContentTypes are the content types and the file extensions of the export formats.
+var exportContentTypes = map[string][2]string{
+	exportFormatMarkdown: {"application/zip", "zip"},
+	exportFormatJSON:     {"application/json", "json"},
+	exportFormatCSV:      {"text/csv; charset=utf-8", "csv"},
+	exportFormatPDF:      {"application/pdf", "pdf"},
+}
+
+/*
+ExportEntries function:
+
+1. Executes helper.CurrentUserId function, and sets the filter from the query parameters(from, to, journal_id).
+
+2. Sets the format from the query parameter(format): md, json, csv or pdf.
+
+3. Streams the published entries, the oldest first, in the format:
+md is a ZIP with a Markdown file with front matter for each entry, json is a JSON document, csv has a row for each entry,
+and pdf is a book with the dates as headings.
+If an error happens after the headers are sent, the connection is dropped(helper.AbortStream), so that the file is not truncated silently.
+
+The entries are loaded batch by batch by model.FindEntriesInBatchesByFilter function, so that all entries are never loaded into memory at once.
+*/
+func ExportEntries(context *gin.Context) {
+	// Executes helper.CurrentUserId function, and sets the filter from the query parameters(from, to, journal_id).
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := model.EntryFilter{UserID: userId}
+	var ok bool
+	if filter.JournalID, ok = parseJournalQuery(context, userId); !ok {
+		return
+	}
+	if filter.From, ok = parseDateQuery(context, "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseDateQuery(context, "to"); !ok {
+		return
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(filter.To.Time) {
+		// If the range is empty, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	// Sets the format from the query parameter(format).
+	format := context.DefaultQuery("format", exportFormatJSON)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		// If the format is not supported, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "format must be md, json, csv or pdf"})
+		return
+	}
+	title, err := exportTitle(userId, filter.JournalID)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Streams the entries in the format.
+	fileName := fmt.Sprintf("diary-entries-%s.%s", time.Now().Format("20060102"), contentType[1])
+	context.Header("Content-Type", contentType[0])
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	context.Status(http.StatusOK)
+	switch format {
+	case exportFormatMarkdown:
+		err = writeMarkdownExport(context.Writer, filter)
+	case exportFormatJSON:
+		err = writeJSONExport(context.Writer, filter)
+	case exportFormatCSV:
+		err = writeCSVExport(context.Writer, filter)
+	case exportFormatPDF:
+		err = writePDFExport(context.Writer, filter, title)
+	}
+	// The headers have already been sent, so the connection is dropped instead, and the error is logged.
+	if err != nil {
+		helper.AbortStream(context, err)
+	}
+}
+
+/*
+exportTitle function:
+
+1. Returns the title of the export: the name of the journal, or the username of the user for their personal entries.
+*/
+func exportTitle(userId uint, journalId *uint) (string, error) {
+	if journalId != nil {
+		journal, err := model.FindJournal(*journalId, userId)
+		if err != nil {
+			return "", err
+		}
+		return journal.Name, nil
+	}
+	user, err := model.FindUserById(userId)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Diary of %s", user.Username), nil
+}
+
+/*
+writeMarkdownExport function:
+
+1. Creates a Markdown file for each entry in the ZIP, named by its local date and its id, e.g. 2023-05-13-12.md.
+
+2. Writes the front matter(YAML) with the metadata of the entry, and the content after it.
+*/
+func writeMarkdownExport(writer io.Writer, filter model.EntryFilter) error {
+	zipWriter := zip.NewWriter(writer)
+	err := model.FindEntriesInBatchesByFilter(filter, exportBatchSize, func(entries []model.Entry) error {
+		for _, entry := range entries {
+			file, err := zipWriter.Create(fmt.Sprintf("%s-%d.md", entry.EntryDate.String(), entry.ID))
+			if err != nil {
+				return err
+			}
+			frontMatter, err := entryFrontMatter(entry)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(file, "%s\n%s\n", frontMatter, entry.Content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+/*
+entryFrontMatter function:
+
+1. Returns the front matter of the entry. The values are written as JSON, which is also valid YAML, so that they never need escaping.
+*/
+func entryFrontMatter(entry model.Entry) (string, error) {
+	tagNames := make([]string, len(entry.Tags))
+	for i, tag := range entry.Tags {
+		tagNames[i] = tag.Name
+	}
+	values := []struct {
+		key   string
+		value interface{}
+	}{
+		{"id", entry.ID},
+		{"date", entry.EntryDate},
+		{"time_zone", entry.TimeZone},
+		{"format", entry.Format},
+		{"mood", entry.Mood},
+		{"tags", tagNames},
+		{"fields", entry.Fields},
+		{"journal_id", entry.JournalID},
+		{"created_at", entry.CreatedAt},
+		{"updated_at", entry.UpdatedAt},
+		{"encrypted", entry.Encrypted},
+	}
+	var frontMatter strings.Builder
+	frontMatter.WriteString("---\n")
+	for _, value := range values {
+		encoded, err := json.Marshal(value.value)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&frontMatter, "%s: %s\n", value.key, encoded)
+	}
+	frontMatter.WriteString("---\n")
+	return frontMatter.String(), nil
+}
+
+/*
+writeJSONExport function:
+
+1. Writes the header of the document.
+
+2. Executes model.FindEntriesInBatchesByFilter function and writes each entry.
+
+3. Closes the JSON object.
+*/
+func writeJSONExport(writer io.Writer, filter model.EntryFilter) error {
+	exportedAt, _ := json.Marshal(time.Now())
+	// Writes the header of the document.
+	if _, err := fmt.Fprintf(writer, `{"version":1,"exported_at":%s,"entries":[`, exportedAt); err != nil {
+		return err
+	}
+
+	// Executes model.FindEntriesInBatchesByFilter function and writes each entry.
+	first := true
+	err := model.FindEntriesInBatchesByFilter(filter, exportBatchSize, func(entries []model.Entry) error {
+		for _, entry := range entries {
+			entryJSON, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := writer.Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := writer.Write(entryJSON); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Closes the JSON object.
+	_, err = writer.Write([]byte("]}"))
+	return err
+}
+
+/*
+writeCSVExport function:
+
+1. Writes the header row.
+
+2. Executes model.FindEntriesInBatchesByFilter function and writes a row for each entry. Tags are separated by ";", and fields are written as JSON.
+The text cells are escaped by csvCell function, so that they cannot run as formulas in spreadsheet apps.
+*/
+func writeCSVExport(writer io.Writer, filter model.EntryFilter) error {
+	csvWriter := csv.NewWriter(writer)
+	// Writes the header row.
+	header := []string{"id", "entry_date", "time_zone", "format", "mood", "tags", "fields", "journal_id", "word_count", "created_at", "updated_at", "encrypted", "content"}
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	// Executes model.FindEntriesInBatchesByFilter function and writes a row for each entry.
+	err := model.FindEntriesInBatchesByFilter(filter, exportBatchSize, func(entries []model.Entry) error {
+		for _, entry := range entries {
+			tagNames := make([]string, len(entry.Tags))
+			for i, tag := range entry.Tags {
+				tagNames[i] = tag.Name
+			}
+			fields := ""
+			if len(entry.Fields) > 0 {
+				encoded, err := json.Marshal(entry.Fields)
+				if err != nil {
+					return err
+				}
+				fields = string(encoded)
+			}
+			mood, journalId := "", ""
+			if entry.Mood != nil {
+				mood = strconv.Itoa(*entry.Mood)
+			}
+			if entry.JournalID != nil {
+				journalId = strconv.FormatUint(uint64(*entry.JournalID), 10)
+			}
+			row := []string{
+				strconv.FormatUint(uint64(entry.ID), 10), entry.EntryDate.String(), entry.TimeZone, entry.Format, mood,
+				csvCell(strings.Join(tagNames, ";")), csvCell(fields), journalId, strconv.Itoa(entry.WordCount),
+				entry.CreatedAt.Format(time.RFC3339), entry.UpdatedAt.Format(time.RFC3339), strconv.FormatBool(entry.Encrypted), csvCell(entry.Content),
+			}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+		// Sends each batch, instead of buffering the whole file.
+		csvWriter.Flush()
+		return csvWriter.Error()
+	})
+	if err != nil {
+		return err
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+/*
+csvCell function:
+
+1. Prefixes the text with a single quote if it starts with =, +, -, @, a tab or a carriage return,
+so that spreadsheet apps show it as text instead of running it as a formula(CSV injection).
+
+FYI: https://owasp.org/www-community/attacks/CSV_Injection
+*/
+func csvCell(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+/*
+writePDFExport function:
+
+1. Starts a book with the title on its first page.
+
+2. Executes model.FindEntriesInBatchesByFilter function, and writes a heading for each date and the content of the entries of that date.
+Encrypted entries are replaced with a note, because their ciphertext cannot be printed.
+
+3. Finishes the book.
+*/
+func writePDFExport(writer io.Writer, filter model.EntryFilter, title string) error {
+	// Starts a book with the title on its first page.
+	book := helper.NewPDFBook(writer, title)
+	book.Heading(title)
+
+	// Writes a heading for each date and the content of the entries of that date.
+	var lastDate *model.Date
+	err := model.FindEntriesInBatchesByFilter(filter, exportBatchSize, func(entries []model.Entry) error {
+		for _, entry := range entries {
+			if lastDate == nil || !lastDate.Equal(entry.EntryDate.Time) {
+				book.Heading(entry.EntryDate.Format("Monday, January 2, 2006"))
+				date := entry.EntryDate
+				lastDate = &date
+			}
+			if entry.Encrypted {
+				book.Paragraph(fmt.Sprintf("[Entry %d is end-to-end encrypted. Export it as JSON or Markdown to decrypt it in your app.]", entry.ID))
+				continue
+			}
+			book.Paragraph(entry.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Finishes the book.
+	return book.Close()
+}
@@ -0,0 +1,26 @@
+package controller
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Dear diary", "Dear diary"},
+		{"=HYPERLINK(\"https://evil.example.com\")", "'=HYPERLINK(\"https://evil.example.com\")"},
+		{"+1 day", "'+1 day"},
+		{"-2 kg", "'-2 kg"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"a=b", "a=b"},
+		{`{"sleep":8}`, `{"sleep":8}`},
+	}
+	for _, test := range tests {
+		if got := csvCell(test.text); got != test.want {
+			t.Errorf("csvCell(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
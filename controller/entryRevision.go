@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetEntryRevisions function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. Executes model.FindEntryRevisions function.
+
+3. If model.FindEntryRevisions function is successfully executed, StatusOK(200) is returned with the revisions, the newest first.
+*/
+func GetEntryRevisions(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindEntryRevisions function.
+	revisions, err := model.FindEntryRevisions(entry.ID)
+	if err != nil {
+		// If model.FindEntryRevisions function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindEntryRevisions function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+/*
+GetEntryRevision function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. Executes findEntryRevision function.
+
+3. If findEntryRevision function is successfully executed, StatusOK(200) is returned.
+*/
+func GetEntryRevision(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes findEntryRevision function.
+	revision, ok := findEntryRevision(context, entry.ID, context.Param("rev"))
+	if !ok {
+		return
+	}
+	// If findEntryRevision function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": revision})
+}
+
+/*
+GetEntryDiff function:
+
+1. Executes helper.CurrentUserId function and findEntry function.
+
+2. Executes findEntryRevision function on the query parameters(from, to).
+from defaults to the revision before to, and to defaults to the latest revision.
+
+3. Diffs the content of the revisions by the query parameter(format):
+"unified"(default) returns a unified diff of the lines, and "word" returns the changes of the words.
+Encrypted entries cannot be diffed, StatusConflict(409) is returned.
+
+4. StatusOK(200) is returned.
+*/
+func GetEntryDiff(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findEntry function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+	if !rejectEncryptedEntry(context, entry, "diff") {
+		return
+	}
+	format := context.DefaultQuery("format", "unified")
+	if format != "unified" && format != "word" {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "format must be unified or word"})
+		return
+	}
+
+	// Executes findEntryRevision function on the query parameters(from, to).
+	to := context.Query("to")
+	if to == "" {
+		revisions, err := model.FindEntryRevisions(entry.ID)
+		if err != nil || len(revisions) == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "The target entry has no revisions."})
+			return
+		}
+		to = strconv.Itoa(revisions[0].Revision)
+	}
+	after, ok := findEntryRevision(context, entry.ID, to)
+	if !ok {
+		return
+	}
+	from := context.Query("from")
+	if from == "" {
+		from = strconv.Itoa(after.Revision - 1)
+	}
+	before := model.EntryRevision{}
+	// Revision 0 is the empty entry before the first revision.
+	if from != "0" {
+		if before, ok = findEntryRevision(context, entry.ID, from); !ok {
+			return
+		}
+	}
+
+	// Diffs the content of the revisions by the query parameter(format).
+	response := gin.H{"from": before.Revision, "to": after.Revision, "format": format}
+	if format == "word" {
+		response["changes"] = helper.DiffWords(before.Content, after.Content)
+	} else {
+		response["diff"] = helper.UnifiedDiff(before.Content, after.Content,
+			fmt.Sprintf("revision %d", before.Revision), fmt.Sprintf("revision %d", after.Revision))
+	}
+	// StatusOK(200) is returned.
+	context.JSON(http.StatusOK, response)
+}
+
+/*
+RestoreEntryRevision function:
+
+1. Executes helper.CurrentUserId function, findEntry function and findEntryRevision function.
+If-Match must match the ETag of the entry, as for any other update.
+
+2. Executes (*model.EntryRevision).Restore function and (*model.Entry).Update function.
+Restoring is a change too, so it creates a new revision, and the revisions after the restored one are kept.
+
+3. If (*model.Entry).Update function is successfully executed, StatusOK(200) is returned.
+*/
+func RestoreEntryRevision(context *gin.Context) {
+	// Executes helper.CurrentUserId function, findEntry function and findEntryRevision function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, ok := findEntry(context, userId, model.JournalRoleEditor)
+	if !ok {
+		return
+	}
+	if !requireEntryETag(context, entry) {
+		return
+	}
+	revision, ok := findEntryRevision(context, entry.ID, context.Param("rev"))
+	if !ok {
+		return
+	}
+
+	// Executes (*model.EntryRevision).Restore function and (*model.Entry).Update function.
+	if err := revision.Restore(&entry); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updatedEntry, err := entry.Update(userId)
+	if errors.Is(err, model.ErrEntryVersionConflict) {
+		// If the entry has been updated by another request meanwhile, StatusPreconditionFailed(412) is returned.
+		respondToCurrentEntryVersion(context, userId, entry.ID)
+		return
+	}
+	if err != nil {
+		// If (*model.Entry).Update function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Entry).Update function is successfully executed, StatusOK(200) is returned with the new ETag.
+	context.Header("ETag", entryETag(*updatedEntry))
+	context.JSON(http.StatusOK, gin.H{"data": updatedEntry})
+}
+
+/*
+findEntryRevision function:
+
+1. Parses the number of the revision(value).
+
+2. Executes model.FindEntryRevision function.
+
+3. If the revision does not exist, StatusNotFound(404) is returned and ok is false.
+*/
+func findEntryRevision(context *gin.Context, entryId uint, value string) (revision model.EntryRevision, ok bool) {
+	// Parses the number of the revision(value).
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		// If the number is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid revision: %s", value)})
+		return model.EntryRevision{}, false
+	}
+
+	// Executes model.FindEntryRevision function.
+	revision, err = model.FindEntryRevision(entryId, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target revision does not exist. [revision: %d]", number)
+		// If the revision does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return model.EntryRevision{}, false
+	}
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return model.EntryRevision{}, false
+	}
+	return revision, true
+}
@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sharePasswordHeader is the request header that carries the password of a share link.
+const sharePasswordHeader = "X-Share-Password"
+
+// sharePasswordClientLimiter limits the wrong passwords of share links sent by each client(its IP address), across all links.
+var sharePasswordClientLimiter = &helper.AttemptLimiter{Max: 20, Window: 15 * time.Minute}
+
+/*
+CreateEntryShare function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function, and findEntry function. Drafts cannot be shared, StatusConflict(409) is returned.
+Encrypted entries cannot be shared either, because the viewers of the link do not have the key, StatusConflict(409) is returned.
+
+3. Executes helper.GenerateShareToken function, and sets the password of the link if it is sent.
+
+4. Executes (*model.EntryShare).Save function.
+
+5. If (*model.EntryShare).Save function is successfully executed, StatusCreated(201) is returned with the plain token and the path of the link.
+
+The plain token is only returned once. Only its hash is stored.
+*/
+func CreateEntryShare(context *gin.Context) {
+	var input model.EntryShareInput
+	// Executes the validation. The body is optional.
+	if err := context.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		// If the expiry has already passed, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function.
+	entry, ok := findEntry(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+	if entry.Status == model.EntryStatusDraft {
+		// If the entry is a draft, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": "Drafts cannot be shared. Publish the draft first."})
+		return
+	}
+	if !rejectEncryptedEntry(context, entry, "share") {
+		return
+	}
+
+	// Executes helper.GenerateShareToken function.
+	plainToken, prefix, tokenHash, err := helper.GenerateShareToken()
+	if err != nil {
+		// If helper.GenerateShareToken function fails to execute, StatusInternalServerError(500) is returned.
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	share := model.EntryShare{
+		EntryID:   entry.ID,
+		UserID:    userId,
+		Prefix:    prefix,
+		TokenHash: tokenHash,
+		ExpiresAt: input.ExpiresAt,
+		MaxViews:  input.MaxViews,
+	}
+	// Sets the password of the link if it is sent.
+	if input.Password != "" {
+		if err := share.SetPassword(input.Password); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Executes (*model.EntryShare).Save function.
+	savedShare, err := share.Save()
+	if err != nil {
+		// If (*model.EntryShare).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.EntryShare).Save function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": savedShare, "token": plainToken, "url": "/s/" + plainToken})
+}
+
+/*
+GetEntryShares function:
+
+1. Executes helper.CurrentUserId function, and findEntry function.
+
+2. Executes model.FindEntrySharesByEntryId function.
+
+3. If model.FindEntrySharesByEntryId function is successfully executed, StatusOK(200) is returned with the revoked shares too.
+*/
+func GetEntryShares(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function.
+	entry, ok := findEntry(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindEntrySharesByEntryId function.
+	shares, err := model.FindEntrySharesByEntryId(entry.ID)
+	if err != nil {
+		// If model.FindEntrySharesByEntryId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindEntrySharesByEntryId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": shares})
+}
+
+/*
+RevokeEntryShare function:
+
+1. Executes helper.CurrentUserId function, and findEntry function.
+
+2. Sets the shareId to a value extracted from the URL parameter.
+
+3. Executes model.RevokeEntryShare function.
+
+4. If model.RevokeEntryShare function is successfully executed, StatusNoContent(204) is returned. The link stops working immediately.
+*/
+func RevokeEntryShare(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes findEntry function.
+	entry, ok := findEntry(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+	// Sets the shareId to a value extracted from the URL parameter.
+	shareId, ok := parseIdParam(context, "shareId")
+	if !ok {
+		return
+	}
+
+	// Executes model.RevokeEntryShare function.
+	err = model.RevokeEntryShare(entry.ID, shareId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target shareId does not exist. [shareId: %d]", shareId)
+		// If the share does not exist or belongs to another entry, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.RevokeEntryShare function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.RevokeEntryShare function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+ViewSharedEntry function:
+
+1. Finds the share by the hash of the token in the URL(/s/:token). No authentication is needed.
+If the share does not exist, StatusNotFound(404) is returned. If it has been revoked, has expired or has no views left, StatusGone(410) is returned.
+
+2. If the link has a password, it must be sent in the X-Share-Password header, or as {"password": "..."} with POST.
+Otherwise StatusUnauthorized(401) is returned with password_required(checkSharePassword).
+After too many wrong passwords for the link or from the client, StatusTooManyRequests(429) is returned.
+
+3. Executes (*model.EntryShare).RecordView function, which counts the view.
+
+4. Returns the entry as model.SharedEntry, without the IDs of the entry and the user, its tags, its custom fields and its attachments.
+
+The response must not be cached or indexed, and the token must not leak to other sites through the Referer header.
+*/
+func ViewSharedEntry(context *gin.Context) {
+	context.Header("Cache-Control", "no-store")
+	context.Header("X-Robots-Tag", "noindex, nofollow")
+	context.Header("Referrer-Policy", "no-referrer")
+
+	// Finds the share by the hash of the token.
+	share, err := model.FindEntryShareByTokenHash(helper.HashShareToken(context.Param("token")))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the share does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": "The shared entry does not exist."})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !share.IsAvailable() {
+		// If the link has been revoked, has expired or has no views left, StatusGone(410) is returned.
+		context.JSON(http.StatusGone, gin.H{"error": model.ErrEntryShareUnavailable.Error()})
+		return
+	}
+
+	// Checks the password of the link.
+	if share.PasswordProtected && !checkSharePassword(context, &share) {
+		return
+	}
+
+	// Executes (*model.EntryShare).RecordView function.
+	if err := share.RecordView(); err != nil {
+		if errors.Is(err, model.ErrEntryShareUnavailable) {
+			// If the last view was taken meanwhile, StatusGone(410) is returned.
+			context.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := model.FindEntryById(share.EntryID)
+	if err != nil || entry.ID == 0 {
+		context.JSON(http.StatusNotFound, gin.H{"error": "The shared entry does not exist."})
+		return
+	}
+	renderEntryHTML(&entry)
+	// Returns the entry without the IDs of the entry and the user.
+	context.JSON(http.StatusOK, gin.H{"data": model.SharedEntry{
+		Content:            entry.Content,
+		Format:             entry.Format,
+		HTML:               entry.HTML,
+		EntryDate:          entry.EntryDate,
+		WordCount:          entry.WordCount,
+		ReadingTimeMinutes: entry.ReadingTimeMinutes,
+	}})
+}
+
+/*
+checkSharePassword function:
+
+1. Reads the password of the link from X-Share-Password, or from the JSON body of a POST.
+
+2. If the link or the client has sent too many wrong passwords, StatusTooManyRequests(429) is returned with Retry-After,
+before the password is compared.
+
+3. If the password is missing or wrong, StatusUnauthorized(401) is returned, and the wrong password is counted for the link and the client.
+The right password clears the count of the link.
+*/
+func checkSharePassword(context *gin.Context, share *model.EntryShare) bool {
+	password := context.GetHeader(sharePasswordHeader)
+	if password == "" && context.Request.Method == http.MethodPost {
+		var body struct {
+			Password string `json:"password"`
+		}
+		_ = context.ShouldBindJSON(&body)
+		password = body.Password
+	}
+	if password == "" {
+		// If the password is missing, StatusUnauthorized(401) is returned.
+		context.JSON(http.StatusUnauthorized, gin.H{"error": "The shared entry is protected by a password.", "password_required": true})
+		return false
+	}
+
+	// Checks the lock of the link and the limit of the client.
+	now := time.Now()
+	retryAfter := share.PasswordLockedFor(now)
+	if allowed, clientRetryAfter := sharePasswordClientLimiter.Allow(context.ClientIP(), now); !allowed && clientRetryAfter > retryAfter {
+		retryAfter = clientRetryAfter
+	}
+	if retryAfter > 0 {
+		// If there have been too many wrong passwords, StatusTooManyRequests(429) is returned.
+		context.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		context.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many wrong passwords. Try again later.", "password_required": true})
+		return false
+	}
+
+	if share.ValidatePassword(password) != nil {
+		sharePasswordClientLimiter.RecordFailure(context.ClientIP(), now)
+		if err := share.RecordFailedPassword(now); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		// If the password is wrong, StatusUnauthorized(401) is returned.
+		context.JSON(http.StatusUnauthorized, gin.H{"error": "The shared entry is protected by a password.", "password_required": true})
+		return false
+	}
+	if err := share.ResetFailedPasswords(); err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"diary_api/model"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+entryETag function:
+
+1. Returns the ETag of the version of the entry, derived from its ID and its version.
+It is returned by the updates, and is enough for If-Match, because only the updates of the entry itself can conflict.
+
+FYI: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/ETag
+*/
+func entryETag(entry model.Entry) string {
+	return fmt.Sprintf(`"%d-%d"`, entry.ID, entry.Version)
+}
+
+/*
+entryRepresentationETag function:
+
+1. Returns the ETag of the response body(body) of GET /api/entry/:id: the ID and the version of the entry, and a hash of the body.
+
+The body also has the tags, the attachments and their thumbnails, and the counts of the comments and the reactions,
+which change without changing the version of the entry, so If-None-Match needs the hash to tell them apart.
+*/
+func entryRepresentationETag(entry model.Entry, body interface{}) (string, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return fmt.Sprintf(`"%d-%d-%s"`, entry.ID, entry.Version, hex.EncodeToString(sum[:8])), nil
+}
+
+/*
+matchesETag function:
+
+1. Returns true if If-None-Match lists the ETag, or is "*".
+
+If-None-Match uses the weak comparison, so weak ETags(W/"...") match their strong form.
+
+FYI: https://www.rfc-editor.org/rfc/rfc9110#section-13.1.2
+*/
+func matchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+matchesEntryVersion function:
+
+1. Returns true if If-Match lists an ETag of the current version of the entry, with or without the hash of GET /api/entry/:id, or is "*".
+
+If-Match uses the strong comparison, so weak ETags(W/"...") never match.
+
+FYI: https://www.rfc-editor.org/rfc/rfc9110#section-13.1.1
+*/
+func matchesEntryVersion(header string, entry model.Entry) bool {
+	etag := entryETag(entry)
+	prefix := strings.TrimSuffix(etag, `"`) + "-"
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag || (strings.HasPrefix(candidate, prefix) && strings.HasSuffix(candidate, `"`)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+requireEntryETag function:
+
+1. If the request has no If-Match header, StatusPreconditionRequired(428) is returned and ok is false.
+
+2. If If-Match does not match the current ETag of the entry, StatusPreconditionFailed(412) is returned with the current entry and ok is false.
+
+Updates require If-Match, so that an edit made on another device is never overwritten silently.
+*/
+func requireEntryETag(context *gin.Context, entry model.Entry) (ok bool) {
+	ifMatch := context.GetHeader("If-Match")
+	if ifMatch == "" {
+		// If the request has no If-Match header, StatusPreconditionRequired(428) is returned.
+		context.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the ETag of the entry is required"})
+		return false
+	}
+	if !matchesEntryVersion(ifMatch, entry) {
+		respondToEntryVersionConflict(context, entry)
+		return false
+	}
+	return true
+}
+
+/*
+respondToEntryVersionConflict function:
+
+1. Sets the current ETag of the entry, and StatusPreconditionFailed(412) is returned with the current entry.
+*/
+func respondToEntryVersionConflict(context *gin.Context, entry model.Entry) {
+	context.Header("ETag", entryETag(entry))
+	context.JSON(http.StatusPreconditionFailed, gin.H{"error": model.ErrEntryVersionConflict.Error(), "entry": entry})
+}
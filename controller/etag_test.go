@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"diary_api/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"1-2"`, true},
+		// If-None-Match uses the weak comparison.
+		{`W/"1-2"`, true},
+		{`"1-1", "1-2"`, true},
+		{`*`, true},
+		{`"1-3"`, false},
+		{`"1-2-abcdef"`, false},
+		{``, false},
+	}
+	for _, test := range tests {
+		if got := matchesETag(test.header, `"1-2"`); got != test.want {
+			t.Errorf("matchesETag(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestMatchesEntryVersion(t *testing.T) {
+	entry := model.Entry{Model: gorm.Model{ID: 1}, Version: 2}
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"1-2"`, true},
+		{`"1-2-0123456789abcdef"`, true},
+		// If-Match uses the strong comparison, so weak ETags never match.
+		{`W/"1-2-0123456789abcdef"`, false},
+		{`W/"1-2"`, false},
+		{`W/"1-2", "1-2"`, true},
+		{`"9-9", "1-2"`, true},
+		{`*`, true},
+		{`"1-3"`, false},
+		{`"1-20"`, false},
+		{`"11-2"`, false},
+		{`"1-2-abc`, false},
+	}
+	for _, test := range tests {
+		if got := matchesEntryVersion(test.header, entry); got != test.want {
+			t.Errorf("matchesEntryVersion(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestEntryRepresentationETag(t *testing.T) {
+	entry := model.Entry{Model: gorm.Model{ID: 1}, Version: 2, Content: "hello"}
+	etag, err := entryRepresentationETag(entry, gin.H{"entry": entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := entryRepresentationETag(entry, gin.H{"entry": entry}); again != etag {
+		t.Errorf("the ETag of the same body changed: %s, %s", etag, again)
+	}
+	if !matchesEntryVersion(etag, entry) {
+		t.Errorf("If-Match %s does not match the version of the entry", etag)
+	}
+
+	// A new comment changes the body, but not the version.
+	commented := entry
+	commented.CommentCount = 1
+	if changed, _ := entryRepresentationETag(commented, gin.H{"entry": commented}); changed == etag {
+		t.Errorf("the ETag did not change with the body: %s", etag)
+	}
+}
+
+func TestParseIfMatchVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	entry := model.Entry{Model: gorm.Model{ID: 1}, Version: 2}
+	tests := []struct {
+		header      string
+		wantVersion *int
+		wantOK      bool
+	}{
+		{``, nil, true},
+		{`*`, nil, true},
+		{`"1-2"`, intPointer(2), true},
+		{`"1-5-0123456789abcdef"`, intPointer(5), true},
+		{`W/"1-3"`, nil, false},
+		{`"2-2"`, nil, false},
+		{`"1-x"`, nil, false},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodPut, "/api/drafts/1", nil)
+		context.Request.Header.Set("If-Match", test.header)
+
+		version, ok := parseIfMatchVersion(context, entry)
+		if ok != test.wantOK {
+			t.Errorf("parseIfMatchVersion(%q) ok = %v, want %v", test.header, ok, test.wantOK)
+			continue
+		}
+		if (version == nil) != (test.wantVersion == nil) || (version != nil && *version != *test.wantVersion) {
+			t.Errorf("parseIfMatchVersion(%q) = %v, want %v", test.header, version, test.wantVersion)
+		}
+		if !ok && recorder.Code != http.StatusPreconditionFailed {
+			t.Errorf("parseIfMatchVersion(%q) returned %d, want 412", test.header, recorder.Code)
+		}
+	}
+}
+
+func intPointer(value int) *int {
+	return &value
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetFieldDefinitions function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindFieldDefinitionsByUserId function.
+
+3. If model.FindFieldDefinitionsByUserId function is successfully executed, StatusOK(200) is returned.
+*/
+func GetFieldDefinitions(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindFieldDefinitionsByUserId function.
+	definitions, err := model.FindFieldDefinitionsByUserId(userId)
+	if err != nil {
+		// If model.FindFieldDefinitionsByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindFieldDefinitionsByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": definitions})
+}
+
+/*
+CreateFieldDefinition function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function.
+
+3. Executes (*model.FieldDefinition).Save function.
+
+4. If (*model.FieldDefinition).Save function is successfully executed, StatusCreated(201) is returned.
+*/
+func CreateFieldDefinition(context *gin.Context) {
+	var input model.FieldDefinitionInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.FieldDefinition).Save function.
+	definition := model.FieldDefinition{
+		UserID:  userId,
+		Key:     input.Key,
+		Label:   input.Label,
+		Type:    input.Type,
+		Options: input.Options,
+	}
+	savedDefinition, err := definition.Save()
+	if err != nil {
+		// If (*model.FieldDefinition).Save function fails to execute, StatusBadRequest(400) is returned.
+		// e.g. the user already has a field with the key.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.FieldDefinition).Save function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": savedDefinition})
+}
+
+/*
+UpdateFieldDefinition function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and model.FindFieldDefinitionById function.
+
+3. Sets the label and the options that are sent in the input.
+
+4. Executes (*model.FieldDefinition).Save function.
+
+5. If (*model.FieldDefinition).Save function is successfully executed, StatusOK(200) is returned.
+*/
+func UpdateFieldDefinition(context *gin.Context) {
+	definitionId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+	var input model.FieldDefinitionUpdateInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and model.FindFieldDefinitionById function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	definition, err := model.FindFieldDefinitionById(userId, definitionId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target fieldId does not exist. [fieldId: %d]", definitionId)
+		// If the field definition does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Sets the label and the options that are sent in the input.
+	if input.Label != nil {
+		definition.Label = *input.Label
+	}
+	if input.Options != nil {
+		definition.Options = *input.Options
+	}
+
+	// Executes (*model.FieldDefinition).Save function.
+	savedDefinition, err := definition.Save()
+	if err != nil {
+		// If (*model.FieldDefinition).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.FieldDefinition).Save function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": savedDefinition})
+}
+
+/*
+DeleteFieldDefinition function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.DeleteFieldDefinition function.
+
+3. If model.DeleteFieldDefinition function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteFieldDefinition(context *gin.Context) {
+	definitionId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.DeleteFieldDefinition function.
+	err = model.DeleteFieldDefinition(userId, definitionId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target fieldId does not exist. [fieldId: %d]", definitionId)
+		// If the field definition does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.DeleteFieldDefinition function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.DeleteFieldDefinition function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/job"
+	"diary_api/model"
+	"diary_api/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// importFormats are the formats POST /api/import accepts.
+var importFormats = map[string]bool{model.ImportFormatDayOne: true, model.ImportFormatMarkdown: true, model.ImportFormatJSON: true}
+
+/*
+ImportEntries function:
+
+1. Executes helper.CurrentUserId function, and sets the format from the form(format): dayone, markdown or json.
+If the form has journal_id, the user must be an editor or the owner of the journal.
+
+2. Reads the file from the multipart form, which must not be larger than model.ImportMaxSize function bytes.
+
+3. Stores the file in the blob store, and executes (*model.ImportJob).Save function.
+
+4. Wakes the import worker, and StatusAccepted(202) is returned with the job, whose progress is polled with GET /api/import/:id.
+*/
+func ImportEntries(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reads the file from the multipart form.
+	maxSize := model.ImportMaxSize()
+	tooLarge := fmt.Sprintf("The file must not be larger than %d bytes.", maxSize)
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxSize+multipartOverhead)
+	fileHeader, err := context.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			// If the request body is too large, StatusRequestEntityTooLarge(413) is returned.
+			context.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+			return
+		}
+		// If the form has no file, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		// If the file is too large, StatusRequestEntityTooLarge(413) is returned.
+		context.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		return
+	}
+
+	// Sets the format from the form(format).
+	format := context.PostForm("format")
+	if !importFormats[format] {
+		// If the format is not supported, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "format must be dayone, markdown or json"})
+		return
+	}
+	importJob := model.ImportJob{UserID: userId, Format: format, FileName: model.NormalizeAttachmentFileName(fileHeader.Filename)}
+	if value := context.PostForm("journal_id"); value != "" {
+		journalId, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			// If the journal_id is invalid, StatusBadRequest(400) is returned.
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		id := uint(journalId)
+		if _, ok := requireJournalRole(context, id, userId, model.JournalRoleEditor); !ok {
+			return
+		}
+		importJob.JournalID = &id
+	}
+
+	// Stores the file in the blob store.
+	file, err := fileHeader.Open()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	storageKey, err := model.NewImportStorageKey(userId)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := storage.Blobs.Put(context.Request.Context(), storageKey, file, fileHeader.Size, "application/octet-stream"); err != nil {
+		// If the blob store fails, StatusInternalServerError(500) is returned.
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.ImportJob).Save function.
+	importJob.StorageKey = storageKey
+	savedJob, err := importJob.Save()
+	if err != nil {
+		// If (*model.ImportJob).Save function fails to execute, the blob is deleted and StatusBadRequest(400) is returned.
+		_ = storage.Blobs.Delete(context.Request.Context(), storageKey)
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	job.WakeImportWorker()
+	// If (*model.ImportJob).Save function is successfully executed, StatusAccepted(202) is returned.
+	context.Header("Location", fmt.Sprintf("/api/import/%d", savedJob.ID))
+	context.JSON(http.StatusAccepted, gin.H{"data": savedJob})
+}
+
+/*
+GetImportJobs function:
+
+1. Executes helper.CurrentUserId function and model.FindImportJobsByUserId function.
+
+2. If model.FindImportJobsByUserId function is successfully executed, StatusOK(200) is returned with the imports of the user, the newest first.
+*/
+func GetImportJobs(context *gin.Context) {
+	// Executes helper.CurrentUserId function and model.FindImportJobsByUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	importJobs, err := model.FindImportJobsByUserId(userId)
+	if err != nil {
+		// If model.FindImportJobsByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindImportJobsByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": importJobs})
+}
+
+/*
+GetImportJob function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Sets the id to a value extracted from the URL parameter, and executes model.FindImportJob function.
+
+3. If model.FindImportJob function is successfully executed, StatusOK(200) is returned with the status, the progress and the errors of the import.
+*/
+func GetImportJob(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Sets the id to a value extracted from the URL parameter, and executes model.FindImportJob function.
+	id, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+	importJob, err := model.FindImportJob(userId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target importId does not exist. [importId: %d]", id)
+		// If the job does not exist or belongs to another user, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.FindImportJob function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindImportJob function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": importJob})
+}
@@ -0,0 +1,482 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+CreateJournal function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function.
+
+3. Executes (*model.Journal).Create function, which makes the user the owner of the journal.
+
+4. If (*model.Journal).Create function is successfully executed, StatusCreated(201) is returned.
+*/
+func CreateJournal(context *gin.Context) {
+	var input model.JournalInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.Journal).Create function.
+	journal := model.Journal{Name: input.Name}
+	if err := journal.Create(userId); err != nil {
+		// If (*model.Journal).Create function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Journal).Create function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": journal})
+}
+
+/*
+GetJournals function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindJournalsByUserId function.
+
+3. If model.FindJournalsByUserId function is successfully executed, StatusOK(200) is returned with the role of the user in each journal.
+*/
+func GetJournals(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindJournalsByUserId function.
+	journals, err := model.FindJournalsByUserId(userId)
+	if err != nil {
+		// If model.FindJournalsByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindJournalsByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": journals})
+}
+
+/*
+GetJournal function:
+
+1. Executes helper.CurrentUserId function and findJournal function.
+
+2. Executes model.FindJournalMembers function.
+
+3. If model.FindJournalMembers function is successfully executed, StatusOK(200) is returned with the members of the journal.
+*/
+func GetJournal(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findJournal function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	journal, ok := findJournal(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+
+	// Executes model.FindJournalMembers function.
+	members, err := model.FindJournalMembers(journal.ID)
+	if err != nil {
+		// If model.FindJournalMembers function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindJournalMembers function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": journal, "members": members})
+}
+
+/*
+UpdateJournal function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and findJournal function. Only the owner can rename the journal.
+
+3. Executes (*model.Journal).Rename function.
+
+4. If (*model.Journal).Rename function is successfully executed, StatusOK(200) is returned.
+*/
+func UpdateJournal(context *gin.Context) {
+	var input model.JournalInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findJournal function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	journal, ok := findJournal(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+
+	// Executes (*model.Journal).Rename function.
+	if err := journal.Rename(input.Name); err != nil {
+		// If (*model.Journal).Rename function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Journal).Rename function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": journal})
+}
+
+/*
+DeleteJournal function:
+
+1. Executes helper.CurrentUserId function and findJournal function. Only the owner can delete the journal.
+
+2. Executes model.DeleteJournal function. The entries of the journal go back to their authors as personal entries.
+
+3. If model.DeleteJournal function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteJournal(context *gin.Context) {
+	// Executes helper.CurrentUserId function and findJournal function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	journal, ok := findJournal(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+
+	// Executes model.DeleteJournal function.
+	if err := model.DeleteJournal(journal.ID); err != nil {
+		// If model.DeleteJournal function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.DeleteJournal function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+InviteJournalMember function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and findJournal function. Only the owner can invite members.
+
+3. Executes model.InviteJournalMember function.
+If the user does not exist, StatusNotFound(404) is returned. If the user is already a member or invited, StatusConflict(409) is returned.
+
+4. If model.InviteJournalMember function is successfully executed, StatusCreated(201) is returned.
+The user becomes a member once they accept the invitation(AcceptJournalInvitation).
+*/
+func InviteJournalMember(context *gin.Context) {
+	var input model.JournalMemberInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findJournal function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	journal, ok := findJournal(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+
+	// Executes model.InviteJournalMember function.
+	member, err := model.InviteJournalMember(journal.ID, userId, input.Username, input.Role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target username does not exist. [username: %s]", input.Username)
+		// If the user does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if errors.Is(err, model.ErrJournalMemberExists) {
+		// If the user is already a member or invited, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		// If model.InviteJournalMember function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.InviteJournalMember function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": member})
+}
+
+/*
+UpdateJournalMember function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function and findJournal function. Only the owner can change the roles.
+
+3. Sets the userId to a value extracted from the URL parameter, and executes model.UpdateJournalMemberRole function.
+
+4. If model.UpdateJournalMemberRole function is successfully executed, StatusOK(200) is returned.
+*/
+func UpdateJournalMember(context *gin.Context) {
+	var input model.JournalMemberRoleInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function and findJournal function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	journal, ok := findJournal(context, userId, model.JournalRoleOwner)
+	if !ok {
+		return
+	}
+
+	// Sets the userId to a value extracted from the URL parameter, and executes model.UpdateJournalMemberRole function.
+	memberId, ok := parseIdParam(context, "userId")
+	if !ok {
+		return
+	}
+	member, err := model.UpdateJournalMemberRole(journal.ID, memberId, input.Role)
+	if !handleJournalMemberError(context, err, memberId) {
+		return
+	}
+	// If model.UpdateJournalMemberRole function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": member})
+}
+
+/*
+RemoveJournalMember function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Sets the userId to a value extracted from the URL parameter.
+Members can remove themselves(leave the journal, or decline the invitation). Only the owner can remove other members.
+
+3. Executes model.RemoveJournalMember function. The owner cannot be removed, StatusConflict(409) is returned.
+
+4. If model.RemoveJournalMember function is successfully executed, StatusNoContent(204) is returned.
+*/
+func RemoveJournalMember(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Sets the userId to a value extracted from the URL parameter.
+	journalId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+	memberId, ok := parseIdParam(context, "userId")
+	if !ok {
+		return
+	}
+	if memberId != userId {
+		// Only the owner can remove other members.
+		if _, ok := findJournal(context, userId, model.JournalRoleOwner); !ok {
+			return
+		}
+	}
+
+	// Executes model.RemoveJournalMember function.
+	err = model.RemoveJournalMember(journalId, memberId)
+	if !handleJournalMemberError(context, err, memberId) {
+		return
+	}
+	// If model.RemoveJournalMember function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+GetJournalInvitations function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindJournalInvitations function.
+
+3. If model.FindJournalInvitations function is successfully executed, StatusOK(200) is returned with the invitations the user has not accepted yet.
+*/
+func GetJournalInvitations(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindJournalInvitations function.
+	invitations, err := model.FindJournalInvitations(userId)
+	if err != nil {
+		// If model.FindJournalInvitations function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindJournalInvitations function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+/*
+AcceptJournalInvitation function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Sets the journalId to a value extracted from the URL parameter.
+
+3. Executes model.AcceptJournalInvitation function. If the user has not been invited, StatusNotFound(404) is returned.
+
+4. If model.AcceptJournalInvitation function is successfully executed, StatusOK(200) is returned with the journal.
+*/
+func AcceptJournalInvitation(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Sets the journalId to a value extracted from the URL parameter.
+	journalId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+
+	// Executes model.AcceptJournalInvitation function.
+	err = model.AcceptJournalInvitation(journalId, userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("There is no invitation to the target journalId. [journalId: %d]", journalId)
+		// If the user has not been invited, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.AcceptJournalInvitation function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.AcceptJournalInvitation function is successfully executed, StatusOK(200) is returned.
+	journal, ok := findJournal(context, userId, model.JournalRoleViewer)
+	if !ok {
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"data": journal})
+}
+
+/*
+findJournal function:
+
+1. Sets the journalId to a value extracted from the URL parameter.
+
+2. Executes requireJournalRole function.
+*/
+func findJournal(context *gin.Context, userId uint, required string) (journal model.Journal, ok bool) {
+	// Sets the journalId to a value extracted from the URL parameter.
+	journalId, ok := parseIdParam(context, "id")
+	if !ok {
+		return model.Journal{}, false
+	}
+	return requireJournalRole(context, journalId, userId, required)
+}
+
+/*
+requireJournalRole function:
+
+1. Executes model.FindJournal function, which loads the journal with the role of the user.
+
+2. If the journal does not exist or the user is not a member, StatusNotFound(404) is returned and ok is false.
+
+3. If the role of the user does not allow the action(required), StatusForbidden(403) is returned and ok is false.
+*/
+func requireJournalRole(context *gin.Context, journalId uint, userId uint, required string) (journal model.Journal, ok bool) {
+	// Executes model.FindJournal function.
+	journal, err := model.FindJournal(journalId, userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target journalId does not exist. [journalId: %d]", journalId)
+		// If the journal does not exist or the user is not a member, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return model.Journal{}, false
+	}
+	if err != nil {
+		// If model.FindJournal function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return model.Journal{}, false
+	}
+	if !model.HasJournalRole(journal.Role, required) {
+		errorMessage := fmt.Sprintf("The %s role is required in this journal, but your role is %s. [journalId: %d]", required, journal.Role, journalId)
+		// If the role of the user does not allow the action, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": errorMessage})
+		return model.Journal{}, false
+	}
+	return journal, true
+}
+
+/*
+handleJournalMemberError function:
+
+1. If the member does not exist, StatusNotFound(404) is returned. If the member is the owner, StatusConflict(409) is returned.
+
+2. Returns whether there was no error.
+*/
+func handleJournalMemberError(context *gin.Context, err error, memberId uint) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		errorMessage := fmt.Sprintf("The target userId is not a member of the journal. [userId: %d]", memberId)
+		// If the member does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+	case errors.Is(err, model.ErrJournalOwner):
+		// If the member is the owner, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oidcLoginStateTTL is how long the user has to finish logging in at the identity provider.
+const oidcLoginStateTTL = 10 * time.Minute
+
+// errOIDCEmailNotVerified is returned when an identity has the email of a user whose email the app has not verified.
+var errOIDCEmailNotVerified = errors.New("an account with this email already exists, but its email has not been verified. Log in to that account and link the identity with POST /api/identities/:provider")
+
+// errOIDCBrowserMismatch is returned when the callback is opened in another browser than the one that started the flow.
+var errOIDCBrowserMismatch = errors.New("the login was not started in this browser")
+
+// usernameDisallowedCharacters matches the characters removed when a username is derived from an external identity.
+var usernameDisallowedCharacters = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+/*
+StartOIDCLogin function:
+
+1. Executes helper.FindOIDCProvider function.
+
+2. Executes beginOIDCFlow function, which stores the state, the nonce and the PKCE code verifier.
+
+3. Redirects the browser to the authorization endpoint of the identity provider.
+*/
+func StartOIDCLogin(context *gin.Context) {
+	// Executes helper.FindOIDCProvider function.
+	provider, err := helper.FindOIDCProvider(context.Param("provider"))
+	if err != nil {
+		// If the provider is not configured, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	// The mode(token or cookie) is kept in the login state until the callback.
+	cookieMode, ok := parseSessionMode(context)
+	if !ok {
+		return
+	}
+
+	// Executes beginOIDCFlow function.
+	authorizationURL, ok := beginOIDCFlow(context, provider, cookieMode, nil)
+	if !ok {
+		return
+	}
+	// Redirects the browser to the authorization endpoint of the identity provider.
+	context.Redirect(http.StatusFound, authorizationURL)
+}
+
+/*
+LinkOIDCIdentity function:
+
+1. Executes helper.CurrentUserId function and helper.FindOIDCProvider function.
+
+2. Executes beginOIDCFlow function with the user, so that the callback links the identity to them instead of logging in.
+
+3. StatusOK(200) is returned with the URL of the identity provider, which the client opens in a browser.
+
+This is how an identity is linked to a user whose email has not been verified, because the callback never links it by email alone.
+*/
+func LinkOIDCIdentity(context *gin.Context) {
+	// Executes helper.CurrentUserId function and helper.FindOIDCProvider function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	provider, err := helper.FindOIDCProvider(context.Param("provider"))
+	if err != nil {
+		// If the provider is not configured, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	cookieMode, ok := parseSessionMode(context)
+	if !ok {
+		return
+	}
+
+	// Executes beginOIDCFlow function with the user.
+	authorizationURL, ok := beginOIDCFlow(context, provider, cookieMode, &userId)
+	if !ok {
+		return
+	}
+	// StatusOK(200) is returned with the URL of the identity provider.
+	context.JSON(http.StatusOK, gin.H{"authorization_url": authorizationURL})
+}
+
+/*
+beginOIDCFlow function:
+
+1. Generates the state, the nonce and the PKCE code verifier, and stores them with the user to link(linkUserId) if any.
+
+2. Sets a random cookie that binds the flow to this browser, and stores its hash with the login state.
+
+3. Returns the URL of the authorization endpoint of the identity provider. If it fails, an error is returned to the client and ok is false.
+*/
+func beginOIDCFlow(context *gin.Context, provider helper.OIDCProvider, cookieMode bool, linkUserId *uint) (authorizationURL string, ok bool) {
+	// Generates the state, the nonce, the PKCE code verifier and the browser binding, and stores them.
+	values := make([]string, 4)
+	var err error
+	for i := range values {
+		if values[i], err = helper.GenerateOIDCRandomString(); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return "", false
+		}
+	}
+	loginState := model.OIDCLoginState{
+		State:        values[0],
+		Provider:     provider.Name,
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		CookieMode:   cookieMode,
+		LinkUserID:   linkUserId,
+		// Only the hash is stored, so that a leaked row cannot be used to finish the flow.
+		BrowserBindingHash: helper.HashPersonalAccessToken(values[3]),
+		ExpiresAt:          time.Now().Add(oidcLoginStateTTL),
+	}
+	if _, err := loginState.Save(); err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	// Login states that were never used are cleaned up here.
+	if err := model.DeleteExpiredOIDCLoginStates(); err != nil {
+		fmt.Println(err)
+	}
+
+	// Returns the URL of the authorization endpoint of the identity provider.
+	authorizationURL, err = provider.AuthorizationURL(loginState.State, loginState.Nonce, loginState.CodeVerifier)
+	if err != nil {
+		// If the identity provider cannot be reached, StatusBadGateway(502) is returned.
+		context.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return "", false
+	}
+	// Sets a random cookie that binds the flow to this browser.
+	helper.SetOIDCBindingCookie(context, provider.Name, values[3], int(oidcLoginStateTTL.Seconds()))
+	return authorizationURL, true
+}
+
+/*
+OIDCCallback function:
+
+1. Executes model.ConsumeOIDCLoginState function with the state from the query, and checks that the flow was started in this browser.
+
+2. Exchanges the authorization code for a verified ID token, and checks its nonce.
+
+3. Executes linkOIDCUser function if the flow was started by POST /api/identities/:provider, or findOrCreateOIDCUser function otherwise.
+
+4. Executes respondWithSessionToken function, so that the user gets the same JWT(or cookie) as /auth/login.
+*/
+func OIDCCallback(context *gin.Context) {
+	provider, err := helper.FindOIDCProvider(context.Param("provider"))
+	if err != nil {
+		// If the provider is not configured, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errorCode := context.Query("error"); errorCode != "" {
+		// If the identity provider returned an error, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", errorCode, context.Query("error_description"))})
+		return
+	}
+	code := context.Query("code")
+	state := context.Query("state")
+	if code == "" || state == "" {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+	// The browser binding cookie is only used once.
+	binding, _ := context.Cookie(helper.OIDCBindingCookieName)
+	helper.ClearOIDCBindingCookie(context, provider.Name)
+	if binding == "" {
+		// If the flow was not started in this browser, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": errOIDCBrowserMismatch.Error()})
+		return
+	}
+
+	// Executes model.ConsumeOIDCLoginState function with the state from the query.
+	loginState, err := model.ConsumeOIDCLoginState(state, provider.Name)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !helper.ValidateOIDCBinding(binding, loginState.BrowserBindingHash) {
+		// If the flow was started in another browser, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": errOIDCBrowserMismatch.Error()})
+		return
+	}
+
+	// Exchanges the authorization code for a verified ID token, and checks its nonce.
+	claims, err := provider.Exchange(code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes linkOIDCUser function or findOrCreateOIDCUser function.
+	var user model.User
+	if loginState.LinkUserID != nil {
+		user, err = linkOIDCUser(*loginState.LinkUserID, provider.Name, claims)
+	} else {
+		user, err = findOrCreateOIDCUser(provider.Name, claims)
+	}
+	if errors.Is(err, errOIDCEmailNotVerified) || errors.Is(err, model.ErrIdentityLinkedToAnotherUser) {
+		// If the identity cannot be linked to the account that has its email, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes respondWithSessionToken function.
+	respondWithSessionToken(context, user, loginState.CookieMode)
+}
+
+/*
+findOrCreateOIDCUser function:
+
+1. If the identity has logged in before, returns the linked user.
+
+2. If the identity provider has verified the email and a user has the same email, links the identity to that user,
+but only if the app has verified the email of the user too. Otherwise, errOIDCEmailNotVerified is returned,
+because anyone could have registered with that email to take over the account when its owner logs in.
+
+3. Otherwise, creates a new user(JIT provisioning) linked to the identity. Their email is verified by the identity provider.
+*/
+func findOrCreateOIDCUser(providerName string, claims helper.OIDCClaims) (model.User, error) {
+	// If the identity has logged in before, returns the linked user.
+	identity, err := model.FindUserIdentity(providerName, claims.Subject)
+	if err == nil {
+		user, err := model.FindUserById(identity.UserID)
+		if err != nil {
+			return model.User{}, err
+		}
+		if user.ID == 0 {
+			return model.User{}, errors.New("the linked user no longer exists")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.User{}, err
+	}
+
+	identity = model.UserIdentity{Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+
+	// If the identity provider has verified the email and a user has the same email, links the identity to that user.
+	if claims.EmailVerified && claims.Email != "" {
+		user, err := model.FindUserByEmail(claims.Email)
+		if err == nil && user.EmailVerifiedAt == nil {
+			return model.User{}, errOIDCEmailNotVerified
+		}
+		if err == nil {
+			identity.UserID = user.ID
+			if _, err := identity.Save(); err != nil {
+				return model.User{}, err
+			}
+			return user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, err
+		}
+	}
+
+	// Otherwise, creates a new user(JIT provisioning) linked to the identity.
+	username, err := model.AvailableUsername(oidcUsernameBase(claims))
+	if err != nil {
+		return model.User{}, err
+	}
+	// The user logs in with the identity provider, so the password is random and never shown.
+	password, err := helper.GenerateOIDCRandomString()
+	if err != nil {
+		return model.User{}, err
+	}
+	user := model.User{Username: username, Password: password}
+	if claims.EmailVerified && claims.Email != "" {
+		verifiedAt := time.Now()
+		user.Email = &claims.Email
+		user.EmailVerifiedAt = &verifiedAt
+	}
+	if err := model.CreateUserWithIdentity(&user, &identity); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+/*
+linkOIDCUser function:
+
+1. Executes model.LinkUserIdentity function to link the identity to the user who started the flow.
+
+2. If the identity provider has verified the email and it is the email of the user, sets email_verified_at on the user.
+*/
+func linkOIDCUser(userId uint, providerName string, claims helper.OIDCClaims) (model.User, error) {
+	// Executes model.LinkUserIdentity function.
+	identity := model.UserIdentity{UserID: userId, Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+	if err := model.LinkUserIdentity(&identity); err != nil {
+		return model.User{}, err
+	}
+
+	// Sets email_verified_at on the user if the identity provider has verified their email.
+	if claims.EmailVerified && claims.Email != "" {
+		if err := model.MarkEmailVerified(userId, claims.Email); err != nil {
+			return model.User{}, err
+		}
+	}
+	user, err := model.FindUserById(userId)
+	if err != nil {
+		return model.User{}, err
+	}
+	if user.ID == 0 {
+		return model.User{}, errors.New("the user no longer exists")
+	}
+	return user, nil
+}
+
+/*
+oidcUsernameBase function:
+
+1. Derives a username from the preferred username or the local part of the email.
+
+2. Falls back to "user" if neither has usable characters.
+*/
+func oidcUsernameBase(claims helper.OIDCClaims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" {
+		candidate = strings.Split(claims.Email, "@")[0]
+	}
+	candidate = usernameDisallowedCharacters.ReplaceAllString(strings.ToLower(candidate), "")
+	if candidate == "" {
+		return "user"
+	}
+	if len(candidate) > 64 {
+		candidate = candidate[:64]
+	}
+	return candidate
+}
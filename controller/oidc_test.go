@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOIDCCallbackRequiresTheBrowserBinding(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "corp")
+	t.Setenv("OIDC_CORP_ISSUER", "http://localhost:8080/default")
+	t.Setenv("OIDC_CORP_CLIENT_ID", "diary")
+	t.Setenv("OIDC_CORP_REDIRECT_URL", "http://localhost:8000/auth/oidc/corp/callback")
+
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{"missing cookie", ""},
+		{"empty cookie", helper.OIDCBindingCookieName + "="},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Params = gin.Params{{Key: "provider", Value: "corp"}}
+		context.Request = httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/callback?code=the-code&state=the-state", nil)
+		if test.cookie != "" {
+			context.Request.Header.Set("Cookie", test.cookie)
+		}
+
+		// The login state is not consumed, so no database is needed.
+		OIDCCallback(context)
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("%s: OIDCCallback returned %d, want 403", test.name, recorder.Code)
+		}
+		setCookie := recorder.Header().Get("Set-Cookie")
+		if !strings.HasPrefix(setCookie, helper.OIDCBindingCookieName+"=;") || !strings.Contains(setCookie, "Max-Age=0") {
+			t.Errorf("%s: OIDCCallback did not clear the binding cookie: %q", test.name, setCookie)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"diary_api/model"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default and maximum page sizes of the paginated endpoints.
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+/*
+parseIdParam function:
+
+1. Sets the id to a value extracted from the URL parameter.
+
+2. If the URL parameter is invalid, StatusBadRequest(400) is returned and ok is false.
+*/
+func parseIdParam(context *gin.Context, name string) (id uint, ok bool) {
+	// Sets the id to a value extracted from the URL parameter.
+	value, err := strconv.ParseUint(context.Param(name), 10, 64)
+	if err != nil {
+		// If the URL parameter is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return uint(value), true
+}
+
+/*
+parsePagination function:
+
+1. Sets the page and the page size from the query parameters(page, per_page).
+An invalid or missing page is 1, and an invalid or missing page size is defaultPerPage.
+
+2. Returns the page, starting from 1, and the page size, which is at most maxPerPage.
+*/
+func parsePagination(context *gin.Context) (page int, perPage int) {
+	page, err := strconv.Atoi(context.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(context.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+/*
+parseDateQuery function:
+
+1. Sets the date to a value extracted from the query parameter, a local date in the format "2006-01-02".
+
+2. If the query parameter is not sent, date is nil.
+
+3. If the query parameter is invalid, StatusBadRequest(400) is returned and ok is false.
+*/
+func parseDateQuery(context *gin.Context, name string) (date *model.Date, ok bool) {
+	value := context.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := model.ParseDate(value)
+	if err != nil {
+		// If the query parameter is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", name, err.Error())})
+		return nil, false
+	}
+	return &parsed, true
+}
+
+/*
+parseRenderQuery function:
+
+1. Returns whether the client asks for the HTML of the entries with the query parameter(render=html).
+
+2. If the query parameter is invalid, StatusBadRequest(400) is returned and ok is false.
+*/
+func parseRenderQuery(context *gin.Context) (html bool, ok bool) {
+	switch context.Query("render") {
+	case "":
+		return false, true
+	case "html":
+		return true, true
+	}
+	// If the query parameter is invalid, StatusBadRequest(400) is returned.
+	context.JSON(http.StatusBadRequest, gin.H{"error": "render must be html"})
+	return false, false
+}
+
+/*
+parseJournalQuery function:
+
+1. Sets the journalId to a value extracted from the query parameter(journal_id). If it is not sent, journalId is nil.
+
+2. Executes requireJournalRole function, so that only the members of the journal can read its entries.
+
+3. If the query parameter is invalid, StatusBadRequest(400) is returned and ok is false.
+*/
+func parseJournalQuery(context *gin.Context, userId uint) (journalId *uint, ok bool) {
+	value := context.Query("journal_id")
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		// If the journal_id is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	id := uint(parsed)
+	if _, ok := requireJournalRole(context, id, userId, model.JournalRoleViewer); !ok {
+		return nil, false
+	}
+	return &id, true
+}
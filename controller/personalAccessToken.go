@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+CreatePersonalAccessToken function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function.
+
+3. Executes helper.GeneratePersonalAccessToken function.
+
+4. Executes (*model.PersonalAccessToken).Save function.
+
+5. If (*model.PersonalAccessToken).Save function is successfully executed, StatusCreated(201) is returned with the plain token.
+
+The plain token is only returned once. Only its hash is stored.
+*/
+func CreatePersonalAccessToken(context *gin.Context) {
+	var input model.PersonalAccessTokenInput
+	// Executes the validation.
+	// FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := model.ValidatePersonalAccessTokenScopes(input.Scopes); err != nil {
+		// If the scopes are invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		// If the expiry has already passed, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	fmt.Printf("input: %#v\n", input)
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.GeneratePersonalAccessToken function.
+	plainToken, prefix, tokenHash, err := helper.GeneratePersonalAccessToken()
+	if err != nil {
+		// If helper.GeneratePersonalAccessToken function fails to execute, StatusInternalServerError(500) is returned.
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := model.PersonalAccessToken{
+		UserID:    userId,
+		Name:      input.Name,
+		Prefix:    prefix,
+		TokenHash: tokenHash,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	// Executes (*model.PersonalAccessToken).Save function.
+	savedToken, err := token.Save()
+	if err != nil {
+		// If (*model.PersonalAccessToken).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.PersonalAccessToken).Save function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": savedToken, "token": plainToken})
+}
+
+/*
+GetPersonalAccessTokens function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindPersonalAccessTokensByUserId function.
+
+3. If model.FindPersonalAccessTokensByUserId function is successfully executed, StatusOK(200) is returned.
+*/
+func GetPersonalAccessTokens(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindPersonalAccessTokensByUserId function.
+	tokens, err := model.FindPersonalAccessTokensByUserId(userId)
+	if err != nil {
+		// If model.FindPersonalAccessTokensByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindPersonalAccessTokensByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+/*
+DeletePersonalAccessToken function:
+
+1. Sets the tokenId to a value extracted from the URL parameter.
+
+2. Executes helper.CurrentUserId function.
+
+3. Executes model.DeletePersonalAccessToken function.
+
+4. If model.DeletePersonalAccessToken function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeletePersonalAccessToken(context *gin.Context) {
+	// Sets the tokenId to a value extracted from the URL parameter.
+	tokenId, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	if err != nil {
+		// If the URL parameter is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.DeletePersonalAccessToken function.
+	err = model.DeletePersonalAccessToken(userId, uint(tokenId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target tokenId does not exist. [tokenId: %d]", tokenId)
+		// If the token does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.DeletePersonalAccessToken function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.DeletePersonalAccessToken function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
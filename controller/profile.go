@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+GetMe function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindUserById function and model.FindProfileByUserId function.
+
+3. If they are successfully executed, StatusOK(200) is returned with the user and their profile.
+
+The entries of the user are never loaded.
+*/
+func GetMe(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindUserById function and model.FindProfileByUserId function.
+	user, err := model.FindUserById(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := model.FindProfileByUserId(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If they are successfully executed, StatusOK(200) is returned with the user and their profile.
+	context.JSON(http.StatusOK, meResponse(user, profile))
+}
+
+/*
+UpdateMe function:
+
+1. Executes the validation.
+
+2. Executes model.FindProfileByUserId function and (*model.Profile).Apply function.
+
+3. Executes (*model.Profile).Save function. If the time zone has changed, executes model.RescheduleReminders function.
+
+4. If (*model.Profile).Save function is successfully executed, StatusOK(200) is returned with the user and their profile.
+*/
+func UpdateMe(context *gin.Context) {
+	var input model.ProfileInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := model.FindUserById(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindProfileByUserId function and (*model.Profile).Apply function.
+	profile, err := model.FindProfileByUserId(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	timeZone := profile.TimeZone
+	if err := profile.Apply(input); err != nil {
+		// If the time zone or the locale is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.Profile).Save function.
+	savedProfile, err := profile.Save()
+	if err != nil {
+		// If (*model.Profile).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If the time zone has changed, executes model.RescheduleReminders function, so that the reminders fire at the same local time.
+	if savedProfile.TimeZone != timeZone {
+		if err := model.RescheduleReminders(userId, savedProfile.Location()); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// If (*model.Profile).Save function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, meResponse(user, *savedProfile))
+}
+
+/*
+meResponse function:
+
+1. Returns the user without their entries, and their profile.
+*/
+func meResponse(user model.User, profile model.Profile) gin.H {
+	return gin.H{
+		"user": gin.H{
+			"id":         user.ID,
+			"created_at": user.CreatedAt,
+			"username":   user.Username,
+			"email":      user.Email,
+			"role":       user.Role,
+		},
+		"profile": profile,
+	}
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetReminders function:
+
+1. Executes helper.CurrentUserId function and model.FindRemindersByUserId function.
+
+2. If model.FindRemindersByUserId function is successfully executed, StatusOK(200) is returned with the reminders of the user.
+*/
+func GetReminders(context *gin.Context) {
+	// Executes helper.CurrentUserId function and model.FindRemindersByUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reminders, err := model.FindRemindersByUserId(userId)
+	if err != nil {
+		// If model.FindRemindersByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindRemindersByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": reminders})
+}
+
+/*
+CreateReminder function:
+
+1. Executes the validation. weekdays, local_time and channel are required.
+
+2. Executes (*model.Reminder).Apply function in the time zone of the user. The reminder is enabled unless enabled is false.
+
+3. Executes (*model.Reminder).Save function.
+
+4. If (*model.Reminder).Save function is successfully executed, StatusCreated(201) is returned with the reminder and its next run.
+*/
+func CreateReminder(context *gin.Context) {
+	var input model.ReminderInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Weekdays == nil || input.LocalTime == nil || input.Channel == nil {
+		// If a required field is missing, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": "weekdays, local_time and channel are required."})
+		return
+	}
+
+	// Executes (*model.Reminder).Apply function in the time zone of the user.
+	userId, user, profile, ok := reminderOwner(context)
+	if !ok {
+		return
+	}
+	reminder := model.Reminder{UserID: userId, Enabled: true}
+	if err := reminder.Apply(input, user.Email, profile.Location()); err != nil {
+		// If the reminder is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.Reminder).Save function.
+	savedReminder, err := reminder.Save()
+	if errors.Is(err, model.ErrTooManyReminders) {
+		// If the user already has too many reminders, StatusConflict(409) is returned.
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		// If (*model.Reminder).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Reminder).Save function is successfully executed, StatusCreated(201) is returned.
+	context.JSON(http.StatusCreated, gin.H{"data": savedReminder})
+}
+
+/*
+UpdateReminder function:
+
+1. Executes the validation.
+
+2. Executes model.FindReminder function and (*model.Reminder).Apply function, which updates only the fields that are sent and schedules the next run again.
+
+3. Executes (*model.Reminder).Save function.
+
+4. If (*model.Reminder).Save function is successfully executed, StatusOK(200) is returned with the reminder.
+*/
+func UpdateReminder(context *gin.Context) {
+	reminderId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+	var input model.ReminderInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindReminder function and (*model.Reminder).Apply function.
+	userId, user, profile, ok := reminderOwner(context)
+	if !ok {
+		return
+	}
+	reminder, err := model.FindReminder(userId, reminderId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the reminder does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("The target reminderId does not exist. [reminderId: %d]", reminderId)})
+		return
+	}
+	if err != nil {
+		// If model.FindReminder function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := reminder.Apply(input, user.Email, profile.Location()); err != nil {
+		// If the reminder is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes (*model.Reminder).Save function.
+	savedReminder, err := reminder.Save()
+	if err != nil {
+		// If (*model.Reminder).Save function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If (*model.Reminder).Save function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": savedReminder})
+}
+
+/*
+DeleteReminder function:
+
+1. Executes helper.CurrentUserId function and model.DeleteReminder function.
+
+2. If model.DeleteReminder function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteReminder(context *gin.Context) {
+	reminderId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+
+	// Executes helper.CurrentUserId function and model.DeleteReminder function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = model.DeleteReminder(userId, reminderId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the reminder does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("The target reminderId does not exist. [reminderId: %d]", reminderId)})
+		return
+	}
+	if err != nil {
+		// If model.DeleteReminder function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.DeleteReminder function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+reminderOwner function:
+
+1. Executes helper.CurrentUserId function, model.FindUserById function and model.FindProfileByUserId function,
+because a reminder needs the email and the time zone of its user.
+
+2. If one of them fails to execute, StatusBadRequest(400) is returned, and ok is false.
+*/
+func reminderOwner(context *gin.Context) (userId uint, user model.User, profile model.Profile, ok bool) {
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, model.User{}, model.Profile{}, false
+	}
+	user, err = model.FindUserById(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, model.User{}, model.Profile{}, false
+	}
+	profile, err = model.FindProfileByUserId(userId)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, model.User{}, model.Profile{}, false
+	}
+	return userId, user, profile, true
+}
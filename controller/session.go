@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetSessions function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindActiveSessionsByUserId function.
+
+3. Marks the session of the request as current.
+
+4. If model.FindActiveSessionsByUserId function is successfully executed, StatusOK(200) is returned.
+*/
+func GetSessions(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindActiveSessionsByUserId function.
+	sessions, err := model.FindActiveSessionsByUserId(userId)
+	if err != nil {
+		// If model.FindActiveSessionsByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Marks the session of the request as current.
+	currentSessionId := helper.CurrentSessionId(context)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionId
+	}
+	// If model.FindActiveSessionsByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+/*
+DeleteSession function:
+
+1. Sets the sessionId to a value extracted from the URL parameter.
+
+2. Executes helper.CurrentUserId function.
+
+3. Executes model.RevokeSession function.
+
+4. If model.RevokeSession function is successfully executed, StatusNoContent(204) is returned.
+
+Tokens issued for the revoked session are rejected by JWTAuthMiddleware from now on.
+*/
+func DeleteSession(context *gin.Context) {
+	// Sets the sessionId to a value extracted from the URL parameter.
+	sessionId, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	if err != nil {
+		// If the URL parameter is invalid, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.RevokeSession function.
+	err = model.RevokeSession(userId, uint(sessionId))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target sessionId does not exist. [sessionId: %d]", sessionId)
+		// If the session does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.RevokeSession function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.RevokeSession function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
+
+/*
+DeleteAllSessions function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.RevokeAllSessions function.
+If the query parameter(keep_current) is true, the session of the request is kept.
+
+3. If model.RevokeAllSessions function is successfully executed, StatusOK(200) is returned with the number of revoked sessions.
+*/
+func DeleteAllSessions(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exceptId uint
+	if context.Query("keep_current") == "true" {
+		exceptId = helper.CurrentSessionId(context)
+	}
+
+	// Executes model.RevokeAllSessions function.
+	revoked, err := model.RevokeAllSessions(userId, exceptId)
+	if err != nil {
+		// If model.RevokeAllSessions function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.RevokeAllSessions function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+/*
+Logout function:
+
+1. Executes model.RevokeSession function on the session of the request.
+
+2. Executes helper.ClearSessionCookies function.
+
+3. If model.RevokeSession function is successfully executed, StatusNoContent(204) is returned.
+*/
+func Logout(context *gin.Context) {
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.RevokeSession function on the session of the request.
+	if err := model.RevokeSession(userId, helper.CurrentSessionId(context)); err != nil {
+		// If model.RevokeSession function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Executes helper.ClearSessionCookies function.
+	helper.ClearSessionCookies(context)
+	// If model.RevokeSession function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
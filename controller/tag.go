@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"diary_api/helper"
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+/*
+GetTags function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.FindTagSummariesByUserId function.
+
+3. If model.FindTagSummariesByUserId function is successfully executed, StatusOK(200) is returned with the tags and their usage counts.
+*/
+func GetTags(context *gin.Context) {
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.FindTagSummariesByUserId function.
+	tags, err := model.FindTagSummariesByUserId(userId)
+	if err != nil {
+		// If model.FindTagSummariesByUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.FindTagSummariesByUserId function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+/*
+UpdateTag function:
+
+1. Executes the validation.
+
+2. Executes helper.CurrentUserId function.
+
+3. Executes model.RenameTag function.
+If the user already has a tag with the new name, the tag is merged into it.
+
+4. If model.RenameTag function is successfully executed, StatusOK(200) is returned with the resulting tag.
+*/
+func UpdateTag(context *gin.Context) {
+	tagId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+	var input model.TagInput
+	// Executes the validation.
+	if err := context.ShouldBindJSON(&input); err != nil {
+		// If the validation fails, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name, err := model.NormalizeTagName(input.Name)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.RenameTag function.
+	tag, merged, err := model.RenameTag(userId, tagId, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target tagId does not exist. [tagId: %d]", tagId)
+		// If the tag does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.RenameTag function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.RenameTag function is successfully executed, StatusOK(200) is returned.
+	context.JSON(http.StatusOK, gin.H{"data": tag, "merged": merged})
+}
+
+/*
+DeleteTag function:
+
+1. Executes helper.CurrentUserId function.
+
+2. Executes model.DeleteTag function, which removes the tag from every entry.
+
+3. If model.DeleteTag function is successfully executed, StatusNoContent(204) is returned.
+*/
+func DeleteTag(context *gin.Context) {
+	tagId, ok := parseIdParam(context, "id")
+	if !ok {
+		return
+	}
+
+	// Executes helper.CurrentUserId function.
+	userId, err := helper.CurrentUserId(context)
+	if err != nil {
+		// If helper.CurrentUserId function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Executes model.DeleteTag function.
+	err = model.DeleteTag(userId, tagId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorMessage := fmt.Sprintf("The target tagId does not exist. [tagId: %d]", tagId)
+		// If the tag does not exist, StatusNotFound(404) is returned.
+		context.JSON(http.StatusNotFound, gin.H{"error": errorMessage})
+		return
+	}
+	if err != nil {
+		// If model.DeleteTag function fails to execute, StatusBadRequest(400) is returned.
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// If model.DeleteTag function is successfully executed, StatusNoContent(204) is returned.
+	context.Status(http.StatusNoContent)
+}
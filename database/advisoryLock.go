@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+)
+
+/*
+AdvisoryLock struct:
+
+1. Key
+
+A Postgres session-level advisory lock, held on a connection of its own, e.g. to elect the one instance of the app that runs a job.
+The lock is released by Postgres when the connection closes, so another instance takes over if the holder dies.
+
+FYI: https://www.postgresql.org/docs/current/explicit-locking.html#ADVISORY-LOCKS
+*/
+type AdvisoryLock struct {
+	Key  int64
+	conn *sql.Conn
+}
+
+/*
+TryAcquire function:
+
+1. If the lock is held, checks that its connection is still alive. If it is not, the lock is lost,
+and the connection is discarded instead of being returned to the pool, where it could still hold the lock.
+
+2. Otherwise, opens a connection and tries to acquire the lock on it without waiting(pg_try_advisory_lock).
+
+3. Returns whether the lock is held.
+*/
+func (lock *AdvisoryLock) TryAcquire(ctx context.Context) (bool, error) {
+	// If the lock is held, checks that its connection is still alive.
+	if lock.conn != nil {
+		if err := lock.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		_ = lock.conn.Raw(func(driverConn interface{}) error { return driver.ErrBadConn })
+		lock.conn.Close()
+		lock.conn = nil
+	}
+
+	// Opens a connection and tries to acquire the lock on it without waiting.
+	sqlDB, err := Database.DB()
+	if err != nil {
+		return false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lock.Key).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+	lock.conn = conn
+	return true, nil
+}
+
+/*
+Release function:
+
+1. Releases the lock if it is held, and closes its connection.
+*/
+func (lock *AdvisoryLock) Release(ctx context.Context) error {
+	if lock.conn == nil {
+		return nil
+	}
+	_, err := lock.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lock.Key)
+	lock.conn.Close()
+	lock.conn = nil
+	return err
+}
@@ -28,9 +28,15 @@ func Connect() {
 	password := os.Getenv("DB_PASSWORD")
 	databaseName := os.Getenv("DB_NAME")
 	port := os.Getenv("DB_PORT")
+	// The time zone of the connection only affects how timestamps are displayed.
+	// Local dates of entries are stored in their own columns, independent of it.
+	timeZone := os.Getenv("DB_TIMEZONE")
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
 
 	// Sets Data Source Name to dsn.
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Africa/Lagos", host, username, password, databaseName, port)
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=%s", host, username, password, databaseName, port, timeZone)
 	// Sets gorm.Dialector to pgDialector.
 	pgDialector := postgres.Open(dsn)
 
@@ -0,0 +1,52 @@
+package helper
+
+import (
+	"bytes"
+	"diary_api/model"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+/*
+ReadImageAttachment function:
+
+1. Reads the whole image, which is at most model.AttachmentMaxSize function bytes.
+
+2. Unless the user keeps the metadata of their photos, executes StripImageMetadata function.
+
+3. Sets the size of the attachment, and the width and the height of the image as it is shown.
+If thumbnails can be generated for the image, its thumbnail status is set to pending.
+*/
+func ReadImageAttachment(file io.Reader, attachment *model.Attachment, keepMetadata bool) ([]byte, error) {
+	// Reads the whole image.
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	// Executes StripImageMetadata function.
+	if !keepMetadata {
+		if data, err = StripImageMetadata(attachment.ContentType, data); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sets the size of the attachment, and the width and the height of the image.
+	attachment.Size = int64(len(data))
+	if !model.CanHaveThumbnails(attachment.ContentType) {
+		return data, nil
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	attachment.Width, attachment.Height = config.Width, config.Height
+	if attachment.ContentType == "image/jpeg" && JPEGOrientation(data) >= 5 {
+		// Orientations 5 to 8 turn the image by 90 degrees.
+		attachment.Width, attachment.Height = config.Height, config.Width
+	}
+	attachment.ThumbnailStatus = model.ThumbnailStatusPending
+	return data, nil
+}
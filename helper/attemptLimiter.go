@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"sync"
+	"time"
+)
+
+// maxAttemptLimiterKeys is the number of keys an AttemptLimiter keeps before it drops the expired ones.
+const maxAttemptLimiterKeys = 10000
+
+/*
+AttemptLimiter struct:
+
+1. Max
+
+2. Window
+
+Counts the failed attempts of each key, e.g. the IP address of a client, in a fixed window.
+A key that has failed Max times is refused until its window ends. The counts live in the memory of each instance of the app.
+*/
+type AttemptLimiter struct {
+	Max    int
+	Window time.Duration
+
+	mu       sync.Mutex
+	failures map[string]attemptWindow
+}
+
+// attemptWindow is the number of failed attempts of a key since start.
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+/*
+Allow function:
+
+1. Returns true if the key has failed fewer than Max times in its current window.
+
+2. Otherwise, returns false and how long until the window ends.
+*/
+func (limiter *AttemptLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	window, ok := limiter.failures[key]
+	if !ok || !now.Before(window.start.Add(limiter.Window)) || window.count < limiter.Max {
+		return true, 0
+	}
+	return false, window.start.Add(limiter.Window).Sub(now)
+}
+
+/*
+RecordFailure function:
+
+1. Counts a failed attempt of the key, in a new window if its last one has ended.
+
+2. Drops the keys whose window has ended once there are more than maxAttemptLimiterKeys, so that the memory stays bounded.
+*/
+func (limiter *AttemptLimiter) RecordFailure(key string, now time.Time) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.failures == nil {
+		limiter.failures = map[string]attemptWindow{}
+	}
+	if len(limiter.failures) >= maxAttemptLimiterKeys {
+		for otherKey, window := range limiter.failures {
+			if !now.Before(window.start.Add(limiter.Window)) {
+				delete(limiter.failures, otherKey)
+			}
+		}
+	}
+	window, ok := limiter.failures[key]
+	if !ok || !now.Before(window.start.Add(limiter.Window)) {
+		window = attemptWindow{start: now}
+	}
+	window.count++
+	limiter.failures[key] = window
+}
@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	limiter := &AttemptLimiter{Max: 3, Window: time.Minute}
+	start := time.Date(2023, 5, 13, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("203.0.113.1", start); !allowed {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+		limiter.RecordFailure("203.0.113.1", start.Add(time.Duration(i)*time.Second))
+	}
+
+	// The key is refused until its window ends, and the other keys are not.
+	allowed, retryAfter := limiter.Allow("203.0.113.1", start.Add(10*time.Second))
+	if allowed || retryAfter != 50*time.Second {
+		t.Errorf("Allow() = %v, %s, want false, 50s", allowed, retryAfter)
+	}
+	if allowed, _ := limiter.Allow("203.0.113.2", start.Add(10*time.Second)); !allowed {
+		t.Error("another key was refused")
+	}
+	if allowed, _ := limiter.Allow("203.0.113.1", start.Add(time.Minute)); !allowed {
+		t.Error("the key was refused after its window")
+	}
+
+	// A failure after the window starts a new one.
+	limiter.RecordFailure("203.0.113.1", start.Add(time.Minute))
+	if allowed, _ := limiter.Allow("203.0.113.1", start.Add(time.Minute)); !allowed {
+		t.Error("the key was refused after one failure in a new window")
+	}
+}
+
+func TestAttemptLimiterDropsExpiredKeys(t *testing.T) {
+	limiter := &AttemptLimiter{Max: 1, Window: time.Minute}
+	start := time.Date(2023, 5, 13, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < maxAttemptLimiterKeys; i++ {
+		limiter.RecordFailure(string(rune(i)), start)
+	}
+	limiter.RecordFailure("new", start.Add(time.Hour))
+	if len(limiter.failures) != 1 {
+		t.Errorf("the limiter keeps %d keys, want only the new one", len(limiter.failures))
+	}
+}
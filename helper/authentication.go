@@ -0,0 +1,182 @@
+package helper
+
+import (
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Authentication methods stored in the context.
+const (
+	AuthMethodJWT                 = "jwt"
+	AuthMethodPersonalAccessToken = "personal_access_token"
+)
+
+// Keys of the values stored in the context by Authenticate function.
+const (
+	contextUserIdKey     = "authUserId"
+	contextAuthMethodKey = "authMethod"
+	contextScopesKey     = "authScopes"
+	contextSessionIdKey  = "authSessionId"
+	contextRoleKey       = "authRole"
+)
+
+/*
+Authenticate function:
+
+1. Executes getTokenFromRequest function to get the token string.
+
+2. If the token string is a personal access token, executes authenticatePersonalAccessToken function.
+
+3. Otherwise, executes authenticateJWT function.
+
+The authenticated user id, method and scopes are stored in the context, so that they are only resolved once per request.
+*/
+func Authenticate(context *gin.Context) error {
+	// Executes getTokenFromRequest function to get the token string.
+	tokenString := getTokenFromRequest(context)
+	if tokenString == "" {
+		return errors.New("no token provided")
+	}
+	// If the token string is a personal access token, executes authenticatePersonalAccessToken function.
+	if strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+		return authenticatePersonalAccessToken(context, tokenString)
+	}
+	// Otherwise, executes authenticateJWT function.
+	return authenticateJWT(context)
+}
+
+/*
+authenticateJWT function:
+
+1. Executes ValidateJWT function.
+
+2. Extracts userId, sessionId and role from claims.
+
+3. Executes model.FindSessionById function and rejects revoked or expired sessions.
+
+4. Stores the user id, the session id and the role in the context.
+*/
+func authenticateJWT(context *gin.Context) error {
+	// Executes ValidateJWT function.
+	if err := ValidateJWT(context); err != nil {
+		return err
+	}
+
+	// Executes getToken function to get the parsed token.
+	token, _ := getToken(context)
+	// Executes Type assertions.
+	// FYI: https://go.dev/tour/methods/15
+	claims, _ := token.Claims.(jwt.MapClaims)
+	floadId, floadOk := claims["id"].(float64)
+	if !floadOk {
+		return errors.New("invalid token provided")
+	}
+
+	floadSessionId, floadSessionOk := claims["sid"].(float64)
+	if !floadSessionOk {
+		// Tokens without a session cannot be revoked, so they are not accepted.
+		return errors.New("invalid token provided")
+	}
+
+	// Extracts userId, sessionId and role from claims.
+	userId := uint(floadId)
+	sessionId := uint(floadSessionId)
+	role, _ := claims["role"].(string)
+	fmt.Printf("userId: %#v\n", userId)
+	fmt.Printf("sessionId: %#v\n", sessionId)
+
+	// Executes model.FindSessionById function and rejects revoked or expired sessions.
+	session, err := model.FindSessionById(sessionId)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userId || !session.IsActive() {
+		return errors.New("session has been revoked or has expired")
+	}
+	if err := session.Touch(); err != nil {
+		fmt.Println(err)
+	}
+
+	// Stores the user id, the session id and the role in the context.
+	context.Set(contextUserIdKey, userId)
+	context.Set(contextAuthMethodKey, AuthMethodJWT)
+	context.Set(contextSessionIdKey, sessionId)
+	context.Set(contextRoleKey, role)
+	return nil
+}
+
+/*
+CurrentUserId function:
+
+1. If the request has not been authenticated yet, executes Authenticate function.
+
+2. Returns the user id stored in the context.
+*/
+func CurrentUserId(context *gin.Context) (uint, error) {
+	// If the request has not been authenticated yet, executes Authenticate function.
+	if _, exists := context.Get(contextUserIdKey); !exists {
+		if err := Authenticate(context); err != nil {
+			return 0, err
+		}
+	}
+	// Returns the user id stored in the context.
+	return context.GetUint(contextUserIdKey), nil
+}
+
+/*
+CurrentSessionId function:
+
+1. Returns the session id stored in the context.
+
+0 is returned if the request was not authenticated by a JWT.
+*/
+func CurrentSessionId(context *gin.Context) uint {
+	return context.GetUint(contextSessionIdKey)
+}
+
+/*
+CurrentRole function:
+
+1. Returns the role carried in the JWT.
+
+An empty string is returned if the request was authenticated by a personal access token,
+so that personal access tokens are never allowed the routes of admins.
+*/
+func CurrentRole(context *gin.Context) string {
+	return context.GetString(contextRoleKey)
+}
+
+/*
+CurrentAuthMethod function:
+
+1. Returns the authentication method stored in the context.
+*/
+func CurrentAuthMethod(context *gin.Context) string {
+	return context.GetString(contextAuthMethodKey)
+}
+
+/*
+HasScope function:
+
+1. A JWT issued by /auth/login is allowed everything, so true is returned.
+
+2. A personal access token is only allowed the scopes it was granted.
+*/
+func HasScope(context *gin.Context, scope string) bool {
+	// A JWT issued by /auth/login is allowed everything, so true is returned.
+	if CurrentAuthMethod(context) == AuthMethodJWT {
+		return true
+	}
+	// A personal access token is only allowed the scopes it was granted.
+	for _, granted := range context.GetStringSlice(contextScopesKey) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
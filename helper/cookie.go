@@ -0,0 +1,170 @@
+package helper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Names of the cookies and the header used by the cookie-based session mode.
+const (
+	SessionCookieName = "diary_session"
+	CSRFCookieName    = "diary_csrf"
+	CSRFHeaderName    = "X-CSRF-Token"
+	// OIDCBindingCookieName is the cookie that ties an OpenID Connect login to the browser that started it.
+	OIDCBindingCookieName = "diary_oidc"
+)
+
+const contextAuthByCookieKey = "authByCookie"
+
+/*
+CookieModeEnabled function:
+
+1. Returns true if the AUTH_COOKIE_ENABLED environment variable is "true".
+
+In cookie mode, /auth/login can set the JWT in an HttpOnly cookie instead of returning it,
+so that browser clients do not have to keep it in localStorage.
+*/
+func CookieModeEnabled() bool {
+	return os.Getenv("AUTH_COOKIE_ENABLED") == "true"
+}
+
+/*
+SetSessionCookies function:
+
+1. Sets the JWT in an HttpOnly, Secure, SameSite cookie.
+
+2. Sets the CSRF token in a cookie that JavaScript can read, so that it can be sent back in the X-CSRF-Token header.
+
+3. Returns the CSRF token.
+
+Secure can be turned off for local development with AUTH_COOKIE_SECURE="false".
+*/
+func SetSessionCookies(context *gin.Context, jwt string, sessionId uint) string {
+	csrfToken := CSRFToken(sessionId)
+	maxAge := int(TokenTTL().Seconds())
+	// Sets the JWT in an HttpOnly, Secure, SameSite cookie.
+	http.SetCookie(context.Writer, newCookie(SessionCookieName, jwt, maxAge, true))
+	// Sets the CSRF token in a cookie that JavaScript can read.
+	http.SetCookie(context.Writer, newCookie(CSRFCookieName, csrfToken, maxAge, false))
+	// Returns the CSRF token.
+	return csrfToken
+}
+
+/*
+ClearSessionCookies function:
+
+1. Expires the session cookie and the CSRF cookie.
+*/
+func ClearSessionCookies(context *gin.Context) {
+	http.SetCookie(context.Writer, newCookie(SessionCookieName, "", -1, true))
+	http.SetCookie(context.Writer, newCookie(CSRFCookieName, "", -1, false))
+}
+
+/*
+SetOIDCBindingCookie function:
+
+1. Sets the browser binding of an OpenID Connect login in an HttpOnly, Secure cookie that is only sent to the callback of the provider.
+
+SameSite is Lax, because the identity provider redirects the browser to the callback from another site.
+*/
+func SetOIDCBindingCookie(context *gin.Context, provider string, binding string, maxAge int) {
+	cookie := newCookie(OIDCBindingCookieName, binding, maxAge, true)
+	cookie.Path = "/auth/oidc/" + url.PathEscape(provider) + "/callback"
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(context.Writer, cookie)
+}
+
+/*
+ClearOIDCBindingCookie function:
+
+1. Expires the browser binding cookie of the provider.
+*/
+func ClearOIDCBindingCookie(context *gin.Context, provider string) {
+	SetOIDCBindingCookie(context, provider, "", -1)
+}
+
+/*
+ValidateOIDCBinding function:
+
+1. Returns true if the hash of the browser binding cookie(binding) matches the hash stored with the login state.
+*/
+func ValidateOIDCBinding(binding string, bindingHash string) bool {
+	if binding == "" || bindingHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashPersonalAccessToken(binding)), []byte(bindingHash)) == 1
+}
+
+/*
+newCookie function:
+
+1. Returns a cookie with the attributes configured by the AUTH_COOKIE_* environment variables.
+*/
+func newCookie(name string, value string, maxAge int, httpOnly bool) *http.Cookie {
+	secure, err := strconv.ParseBool(os.Getenv("AUTH_COOKIE_SECURE"))
+	if err != nil {
+		// Cookies are Secure unless it is explicitly turned off.
+		secure = true
+	}
+	sameSite := http.SameSiteStrictMode
+	if strings.EqualFold(os.Getenv("AUTH_COOKIE_SAMESITE"), "lax") {
+		sameSite = http.SameSiteLaxMode
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   os.Getenv("AUTH_COOKIE_DOMAIN"),
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: httpOnly,
+		SameSite: sameSite,
+	}
+}
+
+/*
+CSRFToken function:
+
+1. Returns the HMAC of the session id, keyed with the JWT private key.
+
+Because the CSRF token is bound to the session, a token planted by another site or subdomain is rejected.
+*/
+func CSRFToken(sessionId uint) string {
+	mac := hmac.New(sha256.New, privateKey())
+	mac.Write([]byte("csrf:" + strconv.FormatUint(uint64(sessionId), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+/*
+AuthenticatedByCookie function:
+
+1. Returns true if the request was authenticated by the session cookie instead of the Authorization header.
+*/
+func AuthenticatedByCookie(context *gin.Context) bool {
+	return context.GetBool(contextAuthByCookieKey)
+}
+
+/*
+ValidateCSRFToken function:
+
+1. Returns true if the X-CSRF-Token header matches both the CSRF cookie and the CSRF token of the session(double-submit).
+*/
+func ValidateCSRFToken(context *gin.Context) bool {
+	headerToken := context.GetHeader(CSRFHeaderName)
+	cookieToken, err := context.Cookie(CSRFCookieName)
+	if err != nil || headerToken == "" {
+		return false
+	}
+	expected := CSRFToken(CurrentSessionId(context))
+	return subtle.ConstantTimeCompare([]byte(headerToken), []byte(cookieToken)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(headerToken), []byte(expected)) == 1
+}
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateOIDCBinding(t *testing.T) {
+	bindingHash := HashPersonalAccessToken("the-binding")
+	tests := []struct {
+		binding     string
+		bindingHash string
+		want        bool
+	}{
+		{"the-binding", bindingHash, true},
+		{"another-binding", bindingHash, false},
+		{"", bindingHash, false},
+		{"the-binding", "", false},
+		// The hash itself cannot be used as the cookie.
+		{bindingHash, bindingHash, false},
+	}
+	for _, test := range tests {
+		if got := ValidateOIDCBinding(test.binding, test.bindingHash); got != test.want {
+			t.Errorf("ValidateOIDCBinding(%q, %q) = %v, want %v", test.binding, test.bindingHash, got, test.want)
+		}
+	}
+}
+
+func TestSetOIDCBindingCookie(t *testing.T) {
+	t.Setenv("AUTH_COOKIE_SECURE", "")
+	t.Setenv("AUTH_COOKIE_SAMESITE", "")
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+
+	SetOIDCBindingCookie(context, "corp", "the-binding", 600)
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != OIDCBindingCookieName || cookie.Value != "the-binding" || cookie.MaxAge != 600 {
+		t.Errorf("cookie = %s=%s(Max-Age=%d)", cookie.Name, cookie.Value, cookie.MaxAge)
+	}
+	if cookie.Path != "/auth/oidc/corp/callback" {
+		t.Errorf("Path = %q, want the callback path", cookie.Path)
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("HttpOnly = %v, Secure = %v, SameSite = %v, want HttpOnly, Secure and Lax", cookie.HttpOnly, cookie.Secure, cookie.SameSite)
+	}
+}
@@ -0,0 +1,304 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Operations of a diff.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEdits bounds the work of diffTokens. Texts that differ more are diffed as a whole replacement.
+const maxDiffEdits = 2000
+
+// unifiedDiffContext is the number of unchanged lines around each change in a unified diff.
+const unifiedDiffContext = 3
+
+/*
+DiffChange struct:
+
+1. Op
+
+2. Text
+*/
+type DiffChange struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+/*
+DiffWords function:
+
+1. Splits both texts into words and the spaces between them.
+
+2. Returns the changes from the old text(before) to the new text(after), merging consecutive changes of the same operation.
+*/
+func DiffWords(before string, after string) []DiffChange {
+	changes := []DiffChange{}
+	for _, change := range diffTokens(splitWords(before), splitWords(after)) {
+		if last := len(changes) - 1; last >= 0 && changes[last].Op == change.Op {
+			changes[last].Text += change.Text
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+/*
+UnifiedDiff function:
+
+1. Diffs the texts line by line.
+
+2. Formats the changes as a unified diff with the names of both texts(beforeName, afterName), like diff -u.
+
+FYI: https://www.gnu.org/software/diffutils/manual/html_node/Unified-Format.html
+*/
+func UnifiedDiff(before string, after string, beforeName string, afterName string) string {
+	changes := diffTokens(splitLines(before), splitLines(after))
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", beforeName, afterName)
+	// beforeLines and afterLines are the line numbers(0-based) at the start of each change.
+	beforeLines := make([]int, len(changes)+1)
+	afterLines := make([]int, len(changes)+1)
+	for i, change := range changes {
+		beforeLines[i+1], afterLines[i+1] = beforeLines[i], afterLines[i]
+		if change.Op != DiffInsert {
+			beforeLines[i+1]++
+		}
+		if change.Op != DiffDelete {
+			afterLines[i+1]++
+		}
+	}
+
+	for start := 0; start < len(changes); {
+		// Finds the next change.
+		if changes[start].Op == DiffEqual {
+			start++
+			continue
+		}
+		// Extends the hunk while the next change is within twice the context.
+		end := start
+		for next := start; next < len(changes); next++ {
+			if changes[next].Op != DiffEqual {
+				end = next + 1
+			} else if next-end >= 2*unifiedDiffContext {
+				break
+			}
+		}
+		hunkStart := start - unifiedDiffContext
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + unifiedDiffContext
+		if hunkEnd > len(changes) {
+			hunkEnd = len(changes)
+		}
+
+		fmt.Fprintf(&builder, "@@ -%s +%s @@\n",
+			hunkRange(beforeLines[hunkStart], beforeLines[hunkEnd]-beforeLines[hunkStart]),
+			hunkRange(afterLines[hunkStart], afterLines[hunkEnd]-afterLines[hunkStart]))
+		for _, change := range changes[hunkStart:hunkEnd] {
+			prefix := " "
+			switch change.Op {
+			case DiffInsert:
+				prefix = "+"
+			case DiffDelete:
+				prefix = "-"
+			}
+			builder.WriteString(prefix + change.Text)
+			if !strings.HasSuffix(change.Text, "\n") {
+				builder.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = hunkEnd
+	}
+	return builder.String()
+}
+
+/*
+hunkRange function:
+
+1. Formats the start line(1-based) and the number of lines of a hunk, e.g. "3,4".
+*/
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		// An empty range refers to the line before it.
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+/*
+splitLines function:
+
+1. Splits the text into lines, keeping the line breaks.
+*/
+func splitLines(text string) []string {
+	lines := []string{}
+	for text != "" {
+		end := strings.IndexByte(text, '\n') + 1
+		if end == 0 {
+			end = len(text)
+		}
+		lines = append(lines, text[:end])
+		text = text[end:]
+	}
+	return lines
+}
+
+/*
+splitWords function:
+
+1. Splits the text into words and runs of spaces, so that joining them gives the text back.
+*/
+func splitWords(text string) []string {
+	words := []string{}
+	start := 0
+	previousSpace := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if i > start && space != previousSpace {
+			words = append(words, text[start:i])
+			start = i
+		}
+		previousSpace = space
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+/*
+diffTokens function:
+
+1. Skips the common prefix and suffix of the tokens.
+
+2. Finds the shortest edit script between the rest with the Myers algorithm.
+
+3. If the texts differ in more than maxDiffEdits tokens, the rest is diffed as a whole replacement.
+
+FYI: http://www.xmailserver.org/diff2.pdf
+*/
+func diffTokens(a []string, b []string) []DiffChange {
+	// Skips the common prefix and suffix of the tokens.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	changes := []DiffChange{}
+	for _, token := range a[:prefix] {
+		changes = append(changes, DiffChange{Op: DiffEqual, Text: token})
+	}
+	changes = append(changes, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, token := range a[len(a)-suffix:] {
+		changes = append(changes, DiffChange{Op: DiffEqual, Text: token})
+	}
+	return changes
+}
+
+/*
+myersDiff function:
+
+1. Finds the furthest reaching path on each diagonal(k) for each number of edits(d), remembering the paths of every round.
+
+2. Walks back from the end through the remembered paths to build the changes.
+*/
+func myersDiff(a []string, b []string) []DiffChange {
+	n, m := len(a), len(b)
+	maxEdits := n + m
+	if maxEdits > maxDiffEdits {
+		maxEdits = maxDiffEdits
+	}
+	offset := maxEdits + 1
+	v := make([]int, 2*maxEdits+3)
+	// trace[d] holds the paths before round d, for the diagonals -d-1 to d+1.
+	trace := [][]int{}
+
+	found := false
+	for d := 0; d <= maxEdits && !found; d++ {
+		trace = append(trace, append([]int{}, v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		// The texts differ too much, so they are diffed as a whole replacement.
+		changes := []DiffChange{}
+		for _, token := range a {
+			changes = append(changes, DiffChange{Op: DiffDelete, Text: token})
+		}
+		for _, token := range b {
+			changes = append(changes, DiffChange{Op: DiffInsert, Text: token})
+		}
+		return changes
+	}
+
+	// Walks back from the end through the remembered paths.
+	reversed := []DiffChange{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		path := trace[d]
+		k := x - y
+		var previousK int
+		if k == -d || (k != d && path[k-1+d+1] < path[k+1+d+1]) {
+			previousK = k + 1
+		} else {
+			previousK = k - 1
+		}
+		previousX := path[previousK+d+1]
+		previousY := previousX - previousK
+		for x > previousX && y > previousY {
+			reversed = append(reversed, DiffChange{Op: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == previousX {
+			reversed = append(reversed, DiffChange{Op: DiffInsert, Text: b[previousY]})
+		} else {
+			reversed = append(reversed, DiffChange{Op: DiffDelete, Text: a[previousX]})
+		}
+		x, y = previousX, previousY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffChange{Op: DiffEqual, Text: a[x-1]})
+		x--
+		y--
+	}
+
+	changes := make([]DiffChange, len(reversed))
+	for i, change := range reversed {
+		changes[len(reversed)-1-i] = change
+	}
+	return changes
+}
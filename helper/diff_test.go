@@ -0,0 +1,84 @@
+package helper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []DiffChange
+	}{
+		{"unchanged", "same", "same", []DiffChange{{DiffEqual, "same"}}},
+		{"both empty", "", "", []DiffChange{}},
+		{"inserted", "", "a b", []DiffChange{{DiffInsert, "a b"}}},
+		{"deleted", "a b", "", []DiffChange{{DiffDelete, "a b"}}},
+		{"replaced", "the quick fox", "the slow brown fox", []DiffChange{
+			{DiffEqual, "the "}, {DiffDelete, "quick"}, {DiffInsert, "slow brown"}, {DiffEqual, " fox"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DiffWords(test.before, test.after); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("DiffWords(%q, %q) = %#v, want %#v", test.before, test.after, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffWordsRebuildsBothTexts(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+	}{
+		{"a b c d e", "a c e f"},
+		{"日記を 書いた", "日記を 毎日 書いた"},
+		{"one\ntwo  three", "one two\nthree"},
+		// More than maxDiffEdits changes are diffed as a whole replacement.
+		{strings.Repeat("a ", maxDiffEdits+10), strings.Repeat("b ", maxDiffEdits+10)},
+	}
+	for _, test := range tests {
+		var before, after strings.Builder
+		for _, change := range DiffWords(test.before, test.after) {
+			if change.Op != DiffInsert {
+				before.WriteString(change.Text)
+			}
+			if change.Op != DiffDelete {
+				after.WriteString(change.Text)
+			}
+		}
+		if before.String() != test.before || after.String() != test.after {
+			t.Errorf("DiffWords(%q, %q) rebuilds %q and %q", test.before, test.after, before.String(), after.String())
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{"unchanged", "same\n", "same\n", "--- a\n+++ b\n"},
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"from empty", "", "x\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n"},
+		{"no newline at end of file", "a\nb", "a\nb\nc",
+			"--- a\n+++ b\n@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n\\ No newline at end of file\n"},
+		{"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n",
+			"1\nX\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\nY\n16\n",
+			"--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n@@ -12,5 +12,5 @@\n 12\n 13\n 14\n-15\n+Y\n 16\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := UnifiedDiff(test.before, test.after, "a", "b"); got != test.want {
+				t.Errorf("UnifiedDiff(%q, %q) = %q, want %q", test.before, test.after, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Markers of the JPEG segments.
+const (
+	jpegMarkerSOI   = 0xD8
+	jpegMarkerEOI   = 0xD9
+	jpegMarkerSOS   = 0xDA
+	jpegMarkerAPP1  = 0xE1
+	jpegMarkerAPP13 = 0xED
+	jpegMarkerCOM   = 0xFE
+)
+
+// exifOrientationTag is the EXIF tag of the orientation of the image.
+const exifOrientationTag = 0x0112
+
+// ErrInvalidImage is returned when the structure of an image file is broken.
+var ErrInvalidImage = errors.New("invalid image")
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// pngMetadataChunks are the PNG chunks that hold metadata: EXIF, text(e.g. XMP, comments) and the modification time.
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+/*
+StripImageMetadata function:
+
+1. Removes the metadata of a JPEG, PNG or WebP image, e.g. the GPS location, the camera and the date in EXIF, XMP and IPTC.
+The pixels are not decoded, so the quality of the image does not change.
+
+2. The EXIF orientation of a JPEG is kept, because the photo would be shown rotated without it.
+
+3. Other types are returned as they are.
+*/
+func StripImageMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEGMetadata(data)
+	case "image/png":
+		return stripPNGMetadata(data)
+	case "image/webp":
+		return stripWebPMetadata(data)
+	}
+	return data, nil
+}
+
+/*
+JPEGOrientation function:
+
+1. Returns the EXIF orientation(1-8) of a JPEG image, or 1 if it has none.
+
+FYI: https://www.exif.org/Exif2-2.PDF (4.6.4 TIFF Rev. 6.0 Attribute Information)
+*/
+func JPEGOrientation(data []byte) int {
+	orientation := 1
+	_ = walkJPEGSegments(data, func(marker byte, segment []byte) bool {
+		if marker == jpegMarkerAPP1 {
+			if value := exifOrientation(segment[4:]); value != 0 {
+				orientation = value
+				return false
+			}
+		}
+		return true
+	})
+	return orientation
+}
+
+/*
+stripJPEGMetadata function:
+
+1. Copies the segments of the JPEG before the image data, except APP1(EXIF, XMP), APP13(IPTC) and COM(comments).
+APP0(JFIF), APP2(ICC color profile) and APP14(Adobe) are kept, because they change how the image is decoded.
+
+2. Replaces the EXIF segment by a minimal one with the orientation only.
+
+3. Copies the image data from SOS(start of scan) as it is.
+*/
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write(data[:2])
+	orientationWritten := false
+	end, err := walkJPEGSegmentsFrom(data, func(marker byte, segment []byte) bool {
+		switch marker {
+		case jpegMarkerAPP1:
+			// Replaces the EXIF segment by a minimal one with the orientation only.
+			if orientation := exifOrientation(segment[4:]); orientation > 1 && !orientationWritten {
+				out.Write(orientationSegment(orientation))
+				orientationWritten = true
+			}
+		case jpegMarkerAPP13, jpegMarkerCOM:
+		default:
+			out.Write(segment)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Copies the image data from SOS as it is.
+	out.Write(data[end:])
+	return out.Bytes(), nil
+}
+
+/*
+walkJPEGSegments function:
+
+1. Executes walkJPEGSegmentsFrom function, ignoring where the image data starts.
+*/
+func walkJPEGSegments(data []byte, visit func(marker byte, segment []byte) bool) error {
+	_, err := walkJPEGSegmentsFrom(data, visit)
+	return err
+}
+
+/*
+walkJPEGSegmentsFrom function:
+
+1. Visits each segment of the JPEG before the image data, with its marker and its bytes(including the marker and the length).
+Visiting stops when visit returns false.
+
+2. Returns the offset of the SOS(start of scan) segment, where the image data starts.
+
+FYI: https://www.w3.org/Graphics/JPEG/itu-t81.pdf (B.1.1 Markers)
+*/
+func walkJPEGSegmentsFrom(data []byte, visit func(marker byte, segment []byte) bool) (int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return 0, ErrInvalidImage
+	}
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 0, ErrInvalidImage
+		}
+		marker := data[offset+1]
+		switch {
+		case marker == 0xFF:
+			// Fill bytes can precede a marker.
+			offset++
+			continue
+		case marker == jpegMarkerSOS || marker == jpegMarkerEOI:
+			return offset, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Markers without a length.
+			offset += 2
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 2 || offset+2+length > len(data) {
+			return 0, ErrInvalidImage
+		}
+		if !visit(marker, data[offset:offset+2+length]) {
+			return offset, nil
+		}
+		offset += 2 + length
+	}
+	return 0, ErrInvalidImage
+}
+
+/*
+exifOrientation function:
+
+1. Reads the orientation from the IFD0 of an EXIF payload("Exif\0\0" followed by a TIFF structure).
+
+2. Returns 0 if the payload is not EXIF or has no valid orientation.
+*/
+func exifOrientation(payload []byte) int {
+	if !bytes.HasPrefix(payload, exifHeader) {
+		return 0
+	}
+	tiff := payload[len(exifHeader):]
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// Each entry is a tag(2 bytes), a type(2), a count(4) and a value(4). The orientation is a SHORT(3).
+		if order.Uint16(tiff[entry:]) == exifOrientationTag && order.Uint16(tiff[entry+2:]) == 3 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+/*
+orientationSegment function:
+
+1. Returns an APP1 segment with an EXIF IFD0 that only has the orientation.
+*/
+func orientationSegment(orientation int) []byte {
+	var payload bytes.Buffer
+	payload.Write(exifHeader)
+	// TIFF header: big-endian, IFD0 right after the header(offset 8).
+	payload.WriteString("MM\x00*")
+	binary.Write(&payload, binary.BigEndian, uint32(8))
+	// IFD0 with a single entry: the orientation as one SHORT, padded to 4 bytes.
+	binary.Write(&payload, binary.BigEndian, uint16(1))
+	binary.Write(&payload, binary.BigEndian, []uint16{exifOrientationTag, 3})
+	binary.Write(&payload, binary.BigEndian, uint32(1))
+	binary.Write(&payload, binary.BigEndian, []uint16{uint16(orientation), 0})
+	// No next IFD.
+	binary.Write(&payload, binary.BigEndian, uint32(0))
+
+	segment := []byte{0xFF, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(payload.Len()+2))
+	return append(segment, payload.Bytes()...)
+}
+
+/*
+stripPNGMetadata function:
+
+1. Copies the chunks of the PNG, except pngMetadataChunks. The chunks are copied with their CRC, so they stay valid.
+
+FYI: https://www.w3.org/TR/png/#5Chunk-layout
+*/
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrInvalidImage
+	}
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	offset := len(pngSignature)
+	for offset < len(data) {
+		// Each chunk is a length(4 bytes), a type(4), the data and a CRC(4).
+		if offset+12 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if length < 0 || end > len(data) || end < offset {
+			return nil, ErrInvalidImage
+		}
+		chunkType := string(data[offset+4 : offset+8])
+		if !pngMetadataChunks[chunkType] {
+			out.Write(data[offset:end])
+		}
+		offset = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out.Bytes(), nil
+}
+
+/*
+stripWebPMetadata function:
+
+1. Copies the chunks of the WebP, except EXIF and XMP.
+
+2. Clears the EXIF and XMP flags of the VP8X(extended format) chunk, and updates the size of the RIFF container.
+
+FYI: https://developers.google.com/speed/webp/docs/riff_container
+*/
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+	var out bytes.Buffer
+	out.Write(data[:12])
+	offset := 12
+	for offset < len(data) {
+		// Each chunk is a FourCC(4 bytes), a size(4, little-endian) and the data, padded to an even size.
+		if offset+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		end := offset + 8 + size + size%2
+		if end > len(data) {
+			// The padding of the last chunk can be missing.
+			if offset+8+size != len(data) {
+				return nil, ErrInvalidImage
+			}
+			end = len(data)
+		}
+		switch string(data[offset : offset+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[offset:end]...)
+			if len(chunk) > 8 {
+				// Clears the EXIF(0x08) and XMP(0x04) flags.
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[offset:end])
+		}
+		offset = end
+	}
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
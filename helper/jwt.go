@@ -13,7 +13,27 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var privateKey = []byte(os.Getenv("JWT_PRIVATE_KEY"))
+/*
+privateKey function:
+
+1. Returns the key used to sign JWTs.
+
+It is read on every call, because the env file is loaded by main after the package variables are initialized.
+*/
+func privateKey() []byte {
+	return []byte(os.Getenv("JWT_PRIVATE_KEY"))
+}
+
+/*
+TokenTTL function:
+
+1. Returns the lifetime of a JWT, which is set by the TOKEN_TTL environment variable in seconds.
+*/
+func TokenTTL() time.Duration {
+	// Atoi is equivalent to ParseInt(s, 10, 0), converted to type int.
+	tokenTTL, _ := strconv.Atoi(os.Getenv("TOKEN_TTL"))
+	return time.Second * time.Duration(tokenTTL)
+}
 
 /*
 GenerateJWT function:
@@ -26,24 +46,25 @@ GenerateJWT function:
 
 4. Creates and returns a complete, signed JWT.
 */
-func GenerateJWT(user model.User) (string, error) {
+func GenerateJWT(user model.User, session model.Session) (string, error) {
 	// Sets tokenTTL.
-	// Atoi is equivalent to ParseInt(s, 10, 0), converted to type int.
-	tokenTTL, _ := strconv.Atoi(os.Getenv("TOKEN_TTL"))
+	tokenTTL := TokenTTL()
 	fmt.Printf("tokenTTL: %#v\n", tokenTTL)
 	// Sets claims.
 	// MapClaims is a claims type that uses the map[string]interface{} for JSON decoding.
 	claims := jwt.MapClaims{
-		"id":  user.ID,                                                      // the user’s id (id)
-		"iat": time.Now().Unix(),                                            // the time at which the token was issued (iat)
-		"eat": time.Now().Add(time.Second * time.Duration(tokenTTL)).Unix(), // the expiry date of the token (eat).
+		"id":   user.ID,                         // the user’s id (id)
+		"sid":  session.ID,                      // the session's id (sid)
+		"role": user.Role,                       // the user's role (role)
+		"iat":  time.Now().Unix(),               // the time at which the token was issued (iat)
+		"eat":  time.Now().Add(tokenTTL).Unix(), // the expiry date of the token (eat).
 	}
 	fmt.Printf("claims: %#v\n", claims)
 	// Creates a new Token with the specified signing method and claims.
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	fmt.Printf("token: %#v\n", token)
 	// Creates and returns a complete, signed JWT.
-	return token.SignedString(privateKey)
+	return token.SignedString(privateKey())
 }
 
 /*
@@ -83,41 +104,21 @@ func ValidateJWT(context *gin.Context) error {
 /*
 CurrentUser function:
 
-1. Executes ValidateJWT function.
-
-2. Executes getToken function to get the parsed token.
+1. Executes CurrentUserId function.
 
-3. Extracts userId from claims.
+2. Executes model.FindUserByIdPreloadEntries function with userId.
 
-4. Executes model.FindUserByIdPreloadEntries function with userId.
-
-5. If model.FindUserByIdPreloadEntries function is successfully executed, it returns the user struct and nil.
+3. If model.FindUserByIdPreloadEntries function is successfully executed, it returns the user struct and nil.
 */
 func CurrentUser(context *gin.Context) (model.User, error) {
-	// Executes ValidateJWT function.
-	err := ValidateJWT(context)
+	// Executes CurrentUserId function.
+	// The user id is resolved from either a JWT or a personal access token.
+	userId, err := CurrentUserId(context)
 	if err != nil {
-		// If getToken function fails to execute,
+		// If CurrentUserId function fails to execute,
 		// it returns the empty struct and an error.
 		return model.User{}, err
 	}
-
-	// Executes getToken function to get the parsed token.
-	token, _ := getToken(context)
-	// Executes Type assertions.
-	// FYI: https://go.dev/tour/methods/15
-	claims, ok := token.Claims.(jwt.MapClaims)
-	fmt.Printf("claims: %#v\n", claims)
-	fmt.Printf("ok: %#v\n", ok)
-
-	// Executes Type assertions.
-	// FYI: https://go.dev/tour/methods/15
-	floadId, floadOk := claims["id"].(float64)
-	fmt.Printf("floadId: %#v\n", floadId)
-	fmt.Printf("floadOk: %#v\n", floadOk)
-
-	// Extracts userId from claims.
-	userId := uint(floadId)
 	fmt.Printf("userId: %#v\n", userId)
 
 	// Executes model.FindUserByIdPreloadEntries function with userId.
@@ -153,7 +154,7 @@ func jwtParseKeyFunc(token *jwt.Token) (interface{}, error) {
 		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
 	// If token.Method is castable to type *jwt.SigningMethodHMAC, privateKey and nil are returned.
-	return privateKey, nil
+	return privateKey(), nil
 }
 
 /*
@@ -180,17 +181,32 @@ func getToken(context *gin.Context) (*jwt.Token, error) {
 /*
 getTokenFromRequest function:
 
-1. Retrieves the bearer token from the request.
+1. Retrieves the bearer token from the Authorization header.
 
-2. Bearer tokens come in the format `bearer <JWT>`,
+2. Bearer tokens come in the format `Bearer <JWT>`, and the scheme is case-insensitive.
 
-3. So the retrieved string is split and the JWT string is returned.
+3. If there is no Authorization header and the cookie mode is enabled, the JWT is retrieved from the session cookie,
+and the context records that the request was authenticated by a cookie.
 */
 func getTokenFromRequest(context *gin.Context) string {
+	// Retrieves the bearer token from the Authorization header.
 	bearerToken := context.Request.Header.Get("Authorization")
-	splitToken := strings.Split(bearerToken, " ")
-	if len(splitToken) == 2 {
-		return splitToken[1]
+	if bearerToken != "" {
+		// Bearer tokens come in the format `Bearer <JWT>`, and the scheme is case-insensitive.
+		// FYI: https://www.rfc-editor.org/rfc/rfc7235#section-2.1
+		scheme, token, found := strings.Cut(strings.TrimSpace(bearerToken), " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	// If there is no Authorization header and the cookie mode is enabled, the JWT is retrieved from the session cookie.
+	if CookieModeEnabled() {
+		if token, err := context.Cookie(SessionCookieName); err == nil && token != "" {
+			context.Set(contextAuthByCookieKey, true)
+			return token
+		}
 	}
 	return ""
 }
@@ -0,0 +1,669 @@
+package helper
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+The Markdown renderer supports the common subset of CommonMark:
+headings, paragraphs, line breaks, block quotes, lists, code blocks, thematic breaks,
+emphasis, strong emphasis, strikethrough, code spans, links, images and autolinks.
+
+It is sanitizing by construction: every piece of text is escaped, raw HTML is rendered as text,
+and only http, https and mailto URLs(and relative ones) become links.
+
+FYI: https://spec.commonmark.org/
+*/
+
+// hardBreak marks a hard line break in the text of a paragraph. NUL never appears in the input, because it is replaced first.
+const hardBreak = "\x00"
+
+var (
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreakPattern = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextPattern        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fencePattern         = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	blockQuotePattern    = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemPattern      = regexp.MustCompile(`^( {0,3})([-+*]|[0-9]{1,9}[.)])( {1,4}|[ \t]*$)`)
+	languagePattern      = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+	blankLinePattern     = regexp.MustCompile(`\n[ \t]*\n`)
+	autolinkPattern      = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\s]*|[A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*)>`)
+)
+
+/*
+RenderMarkdown function:
+
+1. Normalizes the line breaks and replaces NUL characters.
+
+2. Renders the blocks of the source as sanitized HTML.
+*/
+func RenderMarkdown(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+	source = strings.ReplaceAll(source, "\t", "    ")
+	return renderBlocks(strings.Split(source, "\n"), false)
+}
+
+/*
+RenderPlainText function:
+
+1. Escapes the text.
+
+2. Renders paragraphs separated by blank lines, and keeps the line breaks within them.
+*/
+func RenderPlainText(text string) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	var builder strings.Builder
+	for _, paragraph := range blankLinePattern.Split(text, -1) {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		builder.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br />\n") + "</p>\n")
+	}
+	return builder.String()
+}
+
+/*
+renderBlocks function:
+
+1. Renders the lines block by block.
+
+2. In a tight list item(tight), paragraphs are rendered without <p>.
+*/
+func renderBlocks(lines []string, tight bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fencePattern.MatchString(line):
+			i = renderFencedCode(&builder, lines, i)
+
+		case atxHeadingPattern.MatchString(line):
+			match := atxHeadingPattern.FindStringSubmatch(line)
+			level := len(match[1])
+			fmt.Fprintf(&builder, "<h%d>%s</h%d>\n", level, renderInline(strings.TrimSpace(match[2])), level)
+			i++
+
+		case thematicBreakPattern.MatchString(line):
+			builder.WriteString("<hr />\n")
+			i++
+
+		case blockQuotePattern.MatchString(line):
+			quoted := []string{}
+			for ; i < len(lines) && blockQuotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, blockQuotePattern.ReplaceAllString(lines[i], ""))
+			}
+			builder.WriteString("<blockquote>\n" + renderBlocks(quoted, false) + "</blockquote>\n")
+
+		case listItemPattern.MatchString(line):
+			i = renderList(&builder, lines, i)
+
+		case strings.HasPrefix(line, "    "):
+			// Indented code block.
+			code := []string{}
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+			}
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			builder.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "\n</code></pre>\n")
+
+		default:
+			i = renderParagraph(&builder, lines, i, tight)
+		}
+	}
+	return builder.String()
+}
+
+/*
+renderFencedCode function:
+
+1. Renders the lines up to the closing fence as a code block, with the language of the info string.
+
+2. Returns the index of the line after the block.
+*/
+func renderFencedCode(builder *strings.Builder, lines []string, start int) int {
+	match := fencePattern.FindStringSubmatch(lines[start])
+	indent, fence := len(match[1]), match[2]
+	info := strings.Fields(match[3])
+
+	code := []string{}
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		// Removes up to the indentation of the opening fence.
+		line := lines[i]
+		for j := 0; j < indent && strings.HasPrefix(line, " "); j++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+
+	builder.WriteString("<pre><code")
+	if len(info) > 0 && languagePattern.MatchString(info[0]) {
+		builder.WriteString(` class="language-` + html.EscapeString(info[0]) + `"`)
+	}
+	builder.WriteString(">")
+	if len(code) > 0 {
+		builder.WriteString(html.EscapeString(strings.Join(code, "\n")) + "\n")
+	}
+	builder.WriteString("</code></pre>\n")
+	return i
+}
+
+/*
+renderList function:
+
+1. Collects the items of the list, which have the same kind of marker.
+The lines of an item are indented by the width of its marker.
+
+2. A list is loose if its items are separated by blank lines. Otherwise its paragraphs are rendered without <p>.
+
+3. Returns the index of the line after the list.
+*/
+func renderList(builder *strings.Builder, lines []string, start int) int {
+	first := listItemPattern.FindStringSubmatch(lines[start])
+	ordered := !strings.ContainsAny(first[2], "-+*")
+	delimiter := first[2][len(first[2])-1:]
+
+	items := [][]string{}
+	loose := false
+	i := start
+	for i < len(lines) {
+		match := listItemPattern.FindStringSubmatch(lines[i])
+		if match == nil || ordered == strings.ContainsAny(match[2], "-+*") || match[2][len(match[2])-1:] != delimiter {
+			break
+		}
+		// The content of the item is indented by the width of the marker.
+		contentIndent := len(match[0])
+		if strings.TrimSpace(lines[i][len(match[0]):]) == "" {
+			contentIndent = len(match[1]) + len(match[2]) + 1
+		}
+		item := []string{strings.TrimLeft(lines[i][len(match[0]):], " ")}
+		i++
+
+		blank := false
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				blank = true
+				item = append(item, "")
+				i++
+				continue
+			}
+			if leadingSpaces(line) >= contentIndent {
+				if blank {
+					loose = true
+				}
+				item = append(item, line[contentIndent:])
+				blank = false
+				i++
+				continue
+			}
+			// A lazy continuation line of a paragraph.
+			if !blank && !startsBlock(line) {
+				item = append(item, strings.TrimLeft(line, " "))
+				i++
+				continue
+			}
+			break
+		}
+		if blank && i < len(lines) && listItemPattern.MatchString(lines[i]) {
+			loose = true
+		}
+		items = append(items, item)
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	builder.WriteString("<" + tag)
+	if number, _ := strconv.Atoi(strings.TrimRight(first[2], ".)")); ordered && number != 1 {
+		fmt.Fprintf(builder, ` start="%d"`, number)
+	}
+	builder.WriteString(">\n")
+	for _, item := range items {
+		content := renderBlocks(item, !loose)
+		builder.WriteString("<li>" + strings.TrimSuffix(content, "\n") + "</li>\n")
+	}
+	builder.WriteString("</" + tag + ">\n")
+	return i
+}
+
+/*
+renderParagraph function:
+
+1. Collects the lines of the paragraph, up to a blank line or the start of another block.
+
+2. If the paragraph is underlined with "=" or "-", renders it as a heading(setext heading).
+
+3. Returns the index of the line after the paragraph.
+*/
+func renderParagraph(builder *strings.Builder, lines []string, start int, tight bool) int {
+	paragraph := []string{}
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if i > start {
+			// The underline of a setext heading takes precedence over a thematic break("---").
+			if match := setextPattern.FindStringSubmatch(line); match != nil {
+				level := 1
+				if match[1][0] == '-' {
+					level = 2
+				}
+				fmt.Fprintf(builder, "<h%d>%s</h%d>\n", level, renderInline(joinParagraph(paragraph)), level)
+				return i + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		paragraph = append(paragraph, line)
+	}
+
+	content := renderInline(joinParagraph(paragraph))
+	if tight {
+		builder.WriteString(content + "\n")
+	} else {
+		builder.WriteString("<p>" + content + "</p>\n")
+	}
+	return i
+}
+
+/*
+joinParagraph function:
+
+1. Joins the lines of a paragraph, marking the hard line breaks(two spaces or a backslash at the end of a line).
+*/
+func joinParagraph(lines []string) string {
+	var builder strings.Builder
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " ")
+		if i == len(lines)-1 {
+			builder.WriteString(strings.TrimRight(line, " "))
+			break
+		}
+		switch {
+		case strings.HasSuffix(line, "  "):
+			builder.WriteString(strings.TrimRight(line, " ") + hardBreak)
+		case strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\"):
+			builder.WriteString(strings.TrimSuffix(line, "\\") + hardBreak)
+		default:
+			builder.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+	return builder.String()
+}
+
+/*
+startsBlock function:
+
+1. Returns true if the line starts a block that interrupts a paragraph.
+*/
+func startsBlock(line string) bool {
+	return fencePattern.MatchString(line) || atxHeadingPattern.MatchString(line) || thematicBreakPattern.MatchString(line) ||
+		blockQuotePattern.MatchString(line) || listItemPattern.MatchString(line)
+}
+
+/*
+leadingSpaces function:
+
+1. Returns the number of spaces at the start of the line.
+*/
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+/*
+renderInline function:
+
+1. Renders the inline elements of the text: escapes, code spans, links, images, autolinks, emphasis and line breaks.
+
+2. Escapes everything else.
+*/
+func renderInline(text string) string {
+	var builder strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && isASCIIPunctuation(text[i+1]):
+			// A backslash escape.
+			builder.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == hardBreak[0]:
+			builder.WriteString("<br />\n")
+			i++
+			continue
+
+		case c == '`':
+			if end, code, ok := parseCodeSpan(text, i); ok {
+				builder.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end
+				continue
+			}
+
+		case c == '!' && strings.HasPrefix(text[i+1:], "["):
+			if end, label, destination, title, ok := parseLink(text, i+1); ok {
+				if url, safe := safeURL(destination, true); safe {
+					builder.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(plainText(label)) + `"`)
+					if title != "" {
+						builder.WriteString(` title="` + html.EscapeString(title) + `"`)
+					}
+					builder.WriteString(" />")
+				} else {
+					builder.WriteString(html.EscapeString(plainText(label)))
+				}
+				i = end
+				continue
+			}
+
+		case c == '[':
+			if end, label, destination, title, ok := parseLink(text, i); ok {
+				if url, safe := safeURL(destination, false); safe {
+					builder.WriteString(`<a href="` + html.EscapeString(url) + `"`)
+					if title != "" {
+						builder.WriteString(` title="` + html.EscapeString(title) + `"`)
+					}
+					builder.WriteString(` rel="nofollow noopener noreferrer">` + renderInline(label) + "</a>")
+				} else {
+					// Unsafe links(e.g. javascript:) are rendered as their text only.
+					builder.WriteString(renderInline(label))
+				}
+				i = end
+				continue
+			}
+
+		case c == '<':
+			if match := autolinkPattern.FindStringSubmatch(text[i:]); match != nil {
+				destination := match[1]
+				if !strings.Contains(destination, ":") {
+					destination = "mailto:" + destination
+				}
+				if url, safe := safeURL(destination, false); safe {
+					builder.WriteString(`<a href="` + html.EscapeString(url) + `" rel="nofollow noopener noreferrer">` + html.EscapeString(match[1]) + "</a>")
+				} else {
+					builder.WriteString(html.EscapeString(match[0]))
+				}
+				i += len(match[0])
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if end, rendered, ok := parseEmphasis(text, i); ok {
+				builder.WriteString(rendered)
+				i = end
+				continue
+			}
+		}
+
+		// Escapes everything else, keeping whole runs of delimiters together so that they are not reopened.
+		size := 1
+		if c == '*' || c == '_' || c == '~' || c == '`' {
+			for i+size < len(text) && text[i+size] == c {
+				size++
+			}
+		} else if c >= utf8.RuneSelf {
+			_, size = utf8.DecodeRuneInString(text[i:])
+		}
+		builder.WriteString(html.EscapeString(text[i : i+size]))
+		i += size
+	}
+	return builder.String()
+}
+
+/*
+parseCodeSpan function:
+
+1. Finds the closing backtick run of the same length as the opening one.
+
+2. Returns the end of the code span and its content, with line breaks as spaces and one surrounding space stripped.
+*/
+func parseCodeSpan(text string, start int) (end int, code string, ok bool) {
+	size := 0
+	for start+size < len(text) && text[start+size] == '`' {
+		size++
+	}
+	for i := start + size; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(text) && text[i+run] == '`' {
+			run++
+		}
+		if run == size {
+			code = strings.ReplaceAll(strings.ReplaceAll(text[start+size:i], hardBreak, " "), "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			return i + run, code, true
+		}
+		i += run
+	}
+	return 0, "", false
+}
+
+/*
+parseLink function:
+
+1. Finds the closing bracket of the label, which can contain nested brackets.
+
+2. Parses the destination and the optional title in parentheses after it, e.g. [label](https://example.com "title").
+*/
+func parseLink(text string, start int) (end int, label string, destination string, title string, ok bool) {
+	depth := 0
+	closing := -1
+	for i := start; i < len(text) && closing < 0; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = i
+			}
+		case '`':
+			// Brackets in code spans do not count.
+			if codeEnd, _, found := parseCodeSpan(text, i); found {
+				i = codeEnd - 1
+			}
+		}
+	}
+	if closing < 0 || closing+1 >= len(text) || text[closing+1] != '(' {
+		return 0, "", "", "", false
+	}
+	label = text[start+1 : closing]
+
+	// Parses the destination and the optional title.
+	// The destination can contain balanced parentheses, and the title can contain any of them.
+	rest := text[closing+2:]
+	parenthesis := -1
+	depth, quote := 0, byte(0)
+	for i := 0; i < len(rest) && parenthesis < 0; i++ {
+		switch c := rest[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case (c == '"' || c == '\'') && i > 0 && (rest[i-1] == ' ' || rest[i-1] == '\n'):
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				parenthesis = i
+			}
+			depth--
+		}
+	}
+	if parenthesis < 0 {
+		return 0, "", "", "", false
+	}
+	inside := strings.TrimSpace(strings.ReplaceAll(rest[:parenthesis], "\n", " "))
+	if strings.HasPrefix(inside, "<") {
+		if closingAngle := strings.IndexByte(inside, '>'); closingAngle > 0 {
+			destination, inside = inside[1:closingAngle], strings.TrimSpace(inside[closingAngle+1:])
+		}
+	} else if space := strings.IndexAny(inside, " "); space >= 0 {
+		destination, inside = inside[:space], strings.TrimSpace(inside[space:])
+	} else {
+		destination, inside = inside, ""
+	}
+	if inside != "" {
+		if len(inside) < 2 || !strings.ContainsAny(inside[:1], `"'(`) {
+			return 0, "", "", "", false
+		}
+		title = inside[1 : len(inside)-1]
+	}
+	return closing + 2 + parenthesis + 1, label, destination, title, true
+}
+
+/*
+parseEmphasis function:
+
+1. Reads the opening delimiter run(*, _ or ~~) and finds a closing run of the same character and length.
+
+2. Renders one delimiter as <em>, two as <strong>(or <del> for ~~), and three as both.
+*/
+func parseEmphasis(text string, start int) (end int, rendered string, ok bool) {
+	c := text[start]
+	size := 0
+	for start+size < len(text) && text[start+size] == c {
+		size++
+	}
+	if size > 3 || (c == '~' && size != 2) {
+		return 0, "", false
+	}
+	// The opening run must be followed by a non-space, and "_" must not be inside a word.
+	after := start + size
+	if after >= len(text) || isSpaceByte(text[after]) {
+		return 0, "", false
+	}
+	if c == '_' && start > 0 && isWordByte(text[start-1]) {
+		return 0, "", false
+	}
+
+	for i := after + 1; i < len(text); i++ {
+		if text[i] == '`' {
+			// Delimiters in code spans do not count.
+			if codeEnd, _, found := parseCodeSpan(text, i); found {
+				i = codeEnd - 1
+			}
+			continue
+		}
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if text[i] != c {
+			continue
+		}
+		run := 0
+		for i+run < len(text) && text[i+run] == c {
+			run++
+		}
+		closingAfter := i + run
+		if run != size || isSpaceByte(text[i-1]) || (c == '_' && closingAfter < len(text) && isWordByte(text[closingAfter])) {
+			i += run - 1
+			continue
+		}
+
+		inner := renderInline(text[after:i])
+		switch {
+		case c == '~':
+			rendered = "<del>" + inner + "</del>"
+		case size == 1:
+			rendered = "<em>" + inner + "</em>"
+		case size == 2:
+			rendered = "<strong>" + inner + "</strong>"
+		default:
+			rendered = "<em><strong>" + inner + "</strong></em>"
+		}
+		return closingAfter, rendered, true
+	}
+	return 0, "", false
+}
+
+/*
+safeURL function:
+
+1. Returns the URL if it is relative, or if its scheme is http, https or mailto(not for images).
+
+2. Otherwise, e.g. javascript: or data:, ok is false.
+*/
+func safeURL(raw string, image bool) (url string, ok bool) {
+	url = strings.TrimSpace(raw)
+	for _, r := range url {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return "", false
+		}
+	}
+	// The scheme is the part before the first ":", if it comes before any "/", "?" or "#".
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || (strings.IndexAny(url, "/?#") >= 0 && strings.IndexAny(url, "/?#") < colon) {
+		return url, true
+	}
+	switch strings.ToLower(url[:colon]) {
+	case "http", "https":
+		return url, true
+	case "mailto":
+		return url, !image
+	}
+	return "", false
+}
+
+/*
+plainText function:
+
+1. Returns the text of inline Markdown without the markup, e.g. for the alt text of an image.
+*/
+func plainText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune("*_~`[]", r) {
+			return -1
+		}
+		if r == rune(hardBreak[0]) {
+			return ' '
+		}
+		return r
+	}, text)
+}
+
+func isASCIIPunctuation(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\n' || c == hardBreak[0]
+}
+
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,71 @@
+package helper
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"heading", "# Title", "<h1>Title</h1>\n"},
+		{"emphasis", "Hello *world* and **bold**", "<p>Hello <em>world</em> and <strong>bold</strong></p>\n"},
+		{"hard line break", "line one  \nline two", "<p>line one<br />\nline two</p>\n"},
+		{"list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"block quote", "> quote", "<blockquote>\n<p>quote</p>\n</blockquote>\n"},
+		{"fenced code", "```go\nx := \"<\"\n```", "<pre><code class=\"language-go\">x := &#34;&lt;&#34;\n</code></pre>\n"},
+		{"raw HTML is escaped", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"code span is escaped", "`<b>` & \"q\"", "<p><code>&lt;b&gt;</code> &amp; &#34;q&#34;</p>\n"},
+		{"link", "[x](https://example.com \"t\")", "<p><a href=\"https://example.com\" title=\"t\" rel=\"nofollow noopener noreferrer\">x</a></p>\n"},
+		{"quote in a link is escaped", "[x](\"onclick=alert(1))", "<p><a href=\"&#34;onclick=alert(1)\" rel=\"nofollow noopener noreferrer\">x</a></p>\n"},
+		{"javascript link is dropped", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"image", "![a *b*](/img.png)", "<p><img src=\"/img.png\" alt=\"a b\" /></p>\n"},
+		{"data image is dropped", "![a](data:image/png;base64,AAA)", "<p>a</p>\n"},
+		{"autolink", "<https://example.com>", "<p><a href=\"https://example.com\" rel=\"nofollow noopener noreferrer\">https://example.com</a></p>\n"},
+		{"javascript autolink is text", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := RenderMarkdown(test.source); got != test.want {
+				t.Errorf("RenderMarkdown(%q) = %q, want %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderPlainText(t *testing.T) {
+	got := RenderPlainText("a <b>\r\n\r\nc & d\ne")
+	want := "<p>a &lt;b&gt;</p>\n<p>c &amp; d<br />\ne</p>\n"
+	if got != want {
+		t.Errorf("RenderPlainText() = %q, want %q", got, want)
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		raw    string
+		image  bool
+		wantOK bool
+	}{
+		{"https://example.com/a?b#c", false, true},
+		{"HTTP://example.com", false, true},
+		{"mailto:someone@example.com", false, true},
+		{"mailto:someone@example.com", true, false},
+		{"/relative/path", true, true},
+		{"relative:colon/after", false, false},
+		{"./a:b", false, true},
+		{"?q=a:b", false, true},
+		{"javascript:alert(1)", false, false},
+		{"JavaScript:alert(1)", false, false},
+		{"data:text/html;base64,AAA", true, false},
+		{"vbscript:msgbox", false, false},
+		{"java\tscript:alert(1)", false, false},
+		{"https://example.com/a b", false, false},
+		{"https://example.com/\u0000", false, false},
+	}
+	for _, test := range tests {
+		if _, ok := safeURL(test.raw, test.image); ok != test.wantOK {
+			t.Errorf("safeURL(%q, %v) ok = %v, want %v", test.raw, test.image, ok, test.wantOK)
+		}
+	}
+}
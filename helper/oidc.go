@@ -0,0 +1,468 @@
+package helper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+/*
+OIDCProvider struct:
+
+1. Name
+
+2. Issuer
+
+3. ClientID
+
+4. ClientSecret
+
+5. RedirectURL
+
+6. Scopes
+
+Providers are configured by environment variables. For a provider named "corp":
+
+OIDC_PROVIDERS="corp"
+OIDC_CORP_ISSUER="https://login.example.com"
+OIDC_CORP_CLIENT_ID="..."
+OIDC_CORP_CLIENT_SECRET="..."
+OIDC_CORP_REDIRECT_URL="http://localhost:8000/auth/oidc/corp/callback"
+OIDC_CORP_SCOPES="openid email profile" (optional)
+*/
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+/*
+OIDCClaims struct:
+
+1. Subject
+
+2. Email
+
+3. EmailVerified
+
+4. PreferredUsername
+
+5. Nonce
+*/
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Nonce             string
+}
+
+// oidcDiscovery is the part of the discovery document that is used.
+// FYI: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcJWK is a JSON Web Key.
+// FYI: https://www.rfc-editor.org/rfc/rfc7517
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcProviderMetadata caches the discovery document and the signing keys of a provider.
+type oidcProviderMetadata struct {
+	discovery oidcDiscovery
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+const oidcMetadataTTL = time.Hour
+
+// oidcMetadataMinRefreshInterval is how often the discovery document and the keys of a provider can be fetched at most,
+// so that tokens with unknown key ids, or a provider that is down, cannot make every login fetch them again.
+const oidcMetadataMinRefreshInterval = time.Minute
+
+var (
+	oidcMetadataMutex     sync.Mutex
+	oidcMetadataCache     = map[string]*oidcProviderMetadata{}
+	oidcMetadataFetchedAt = map[string]time.Time{}
+	oidcHTTPClient        = &http.Client{Timeout: 10 * time.Second}
+)
+
+/*
+FindOIDCProvider function:
+
+1. Checks that the provider is listed in OIDC_PROVIDERS.
+
+2. Reads the settings of the provider from the environment variables.
+*/
+func FindOIDCProvider(name string) (OIDCProvider, error) {
+	// Checks that the provider is listed in OIDC_PROVIDERS.
+	listed := false
+	for _, candidate := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if strings.TrimSpace(candidate) == name && name != "" {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return OIDCProvider{}, fmt.Errorf("unknown OIDC provider: %s", name)
+	}
+
+	// Reads the settings of the provider from the environment variables.
+	envPrefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	provider := OIDCProvider{
+		Name:         name,
+		Issuer:       strings.TrimSuffix(os.Getenv(envPrefix+"ISSUER"), "/"),
+		ClientID:     os.Getenv(envPrefix + "CLIENT_ID"),
+		ClientSecret: os.Getenv(envPrefix + "CLIENT_SECRET"),
+		RedirectURL:  os.Getenv(envPrefix + "REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv(envPrefix + "SCOPES")),
+	}
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = []string{"openid", "email", "profile"}
+	}
+	if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+		return OIDCProvider{}, fmt.Errorf("OIDC provider is not fully configured: %s", name)
+	}
+	return provider, nil
+}
+
+/*
+GenerateOIDCRandomString function:
+
+1. Returns a random URL-safe string, used for the state, the nonce and the PKCE code verifier.
+*/
+func GenerateOIDCRandomString() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+/*
+AuthorizationURL function:
+
+1. Fetches the discovery document.
+
+2. Derives the PKCE code challenge(S256) from the code verifier.
+
+3. Returns the URL of the authorization endpoint the browser is redirected to.
+
+FYI: https://www.rfc-editor.org/rfc/rfc7636
+*/
+func (provider OIDCProvider) AuthorizationURL(state string, nonce string, codeVerifier string) (string, error) {
+	// Fetches the discovery document.
+	metadata, err := provider.metadata(false)
+	if err != nil {
+		return "", err
+	}
+	// Derives the PKCE code challenge(S256) from the code verifier.
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURL)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	// Returns the URL of the authorization endpoint the browser is redirected to.
+	separator := "?"
+	if strings.Contains(metadata.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+/*
+Exchange function:
+
+1. Exchanges the authorization code and the PKCE code verifier for tokens at the token endpoint.
+
+2. Executes verifyIDToken function on the returned ID token, with the nonce of the login.
+
+3. Returns the claims of the ID token.
+*/
+func (provider OIDCProvider) Exchange(code string, codeVerifier string, nonce string) (OIDCClaims, error) {
+	metadata, err := provider.metadata(false)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+
+	// Exchanges the authorization code and the PKCE code verifier for tokens at the token endpoint.
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	request, err := http.NewRequest(http.MethodPost, metadata.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+
+	response, err := oidcHTTPClient.Do(request)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	defer response.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokenResponse); err != nil {
+		return OIDCClaims{}, err
+	}
+	if response.StatusCode != http.StatusOK || tokenResponse.IDToken == "" {
+		return OIDCClaims{}, fmt.Errorf("token exchange failed: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+
+	// Executes verifyIDToken function on the returned ID token.
+	return provider.verifyIDToken(tokenResponse.IDToken, nonce)
+}
+
+/*
+verifyIDToken function:
+
+1. Verifies the signature of the ID token with the keys published by the provider.
+
+2. Verifies the issuer, the audience, the expiry and the nonce, which must be the one sent with the authorization request.
+
+3. Returns the claims of the ID token.
+
+FYI: https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+*/
+func (provider OIDCProvider) verifyIDToken(idToken string, nonce string) (OIDCClaims, error) {
+	// Verifies the signature of the ID token with the keys published by the provider.
+	// MapClaims.Valid verifies exp, iat and nbf.
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	token, err := parser.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.signingKey(kid)
+	})
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return OIDCClaims{}, errors.New("invalid ID token")
+	}
+
+	// Verifies the issuer, the audience, the expiry and the nonce.
+	metadata, err := provider.metadata(false)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	if !claims.VerifyIssuer(metadata.discovery.Issuer, true) {
+		return OIDCClaims{}, errors.New("ID token has an unexpected issuer")
+	}
+	if !claims.VerifyAudience(provider.ClientID, true) {
+		return OIDCClaims{}, errors.New("ID token has an unexpected audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return OIDCClaims{}, errors.New("ID token has expired")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return OIDCClaims{}, errors.New("ID token has an unexpected nonce")
+	}
+
+	// Returns the claims of the ID token.
+	result := OIDCClaims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	result.Nonce, _ = claims["nonce"].(string)
+	// Some providers send email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+	if result.Subject == "" {
+		return OIDCClaims{}, errors.New("ID token has no subject")
+	}
+	return result, nil
+}
+
+/*
+signingKey function:
+
+1. Looks up the key with the key id(kid) in the cached keys of the provider.
+
+2. If the key is unknown, the keys are fetched again once, because the provider may have rotated them.
+They are fetched at most once per oidcMetadataMinRefreshInterval, so an unknown key id is refused from the cache until then.
+*/
+func (provider OIDCProvider) signingKey(kid string) (interface{}, error) {
+	for _, refresh := range []bool{false, true} {
+		metadata, err := provider.metadata(refresh)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := metadata.keys[kid]; ok {
+			return key, nil
+		}
+		// A provider with a single key may omit the key id.
+		if kid == "" && len(metadata.keys) == 1 {
+			for _, key := range metadata.keys {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+/*
+metadata function:
+
+1. Returns the cached discovery document and keys unless they are older than oidcMetadataTTL or refresh is true.
+
+2. If they were fetched, or failed to be fetched, less than oidcMetadataMinRefreshInterval ago, returns the cached ones, or an error if there are none.
+
+3. Otherwise, fetches the discovery document and the keys of the provider.
+*/
+func (provider OIDCProvider) metadata(refresh bool) (*oidcProviderMetadata, error) {
+	oidcMetadataMutex.Lock()
+	defer oidcMetadataMutex.Unlock()
+
+	// Returns the cached discovery document and keys unless they are stale.
+	cached, ok := oidcMetadataCache[provider.Issuer]
+	if ok && !refresh && time.Since(cached.fetchedAt) < oidcMetadataTTL {
+		return cached, nil
+	}
+
+	// Fetches them at most once per oidcMetadataMinRefreshInterval.
+	if fetchedAt, attempted := oidcMetadataFetchedAt[provider.Issuer]; attempted && time.Since(fetchedAt) < oidcMetadataMinRefreshInterval {
+		if ok {
+			return cached, nil
+		}
+		return nil, errors.New("the discovery document of the provider could not be fetched, try again later")
+	}
+	oidcMetadataFetchedAt[provider.Issuer] = time.Now()
+
+	// Fetches the discovery document.
+	var discovery oidcDiscovery
+	if err := fetchJSON(provider.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != provider.Issuer {
+		return nil, fmt.Errorf("discovery document has an unexpected issuer: %s", discovery.Issuer)
+	}
+
+	// Fetches the keys of the provider.
+	var jwks struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := fetchJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]interface{}{}
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Keys of unsupported types are skipped.
+			fmt.Println(err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	metadata := &oidcProviderMetadata{discovery: discovery, keys: keys, fetchedAt: time.Now()}
+	oidcMetadataCache[provider.Issuer] = metadata
+	return metadata, nil
+}
+
+/*
+publicKey function:
+
+1. Converts an RSA or EC JSON Web Key into a public key.
+*/
+func (jwk oidcJWK) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+/*
+fetchJSON function:
+
+1. Sends a GET request to the URL and decodes the JSON response into the target.
+*/
+func fetchJSON(targetURL string, target interface{}) error {
+	response, err := oidcHTTPClient.Get(targetURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from %s: %d", targetURL, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
@@ -0,0 +1,192 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// mockOIDCIssuer is an identity provider that returns the ID token(idToken) from its token endpoint.
+type mockOIDCIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	idToken   string
+	jwksCalls int32
+}
+
+func newMockOIDCIssuer(t *testing.T) *mockOIDCIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &mockOIDCIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(writer http.ResponseWriter, request *http.Request) {
+		json.NewEncoder(writer).Encode(oidcDiscovery{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			JWKSURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&issuer.jwksCalls, 1)
+		json.NewEncoder(writer).Encode(map[string]interface{}{"keys": []oidcJWK{{
+			Kid: "key-1",
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(writer http.ResponseWriter, request *http.Request) {
+		clientId, clientSecret, _ := request.BasicAuth()
+		if request.FormValue("code") != "the-code" || request.FormValue("code_verifier") != "the-verifier" ||
+			clientId != "diary" || clientSecret != "secret" {
+			writer.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(writer).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(writer).Encode(map[string]string{"id_token": issuer.idToken})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (issuer *mockOIDCIssuer) provider() OIDCProvider {
+	return OIDCProvider{Name: "mock", Issuer: issuer.server.URL, ClientID: "diary", ClientSecret: "secret", RedirectURL: "http://localhost/callback"}
+}
+
+// validClaims are the claims of an ID token that passes the validation.
+func (issuer *mockOIDCIssuer) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            issuer.server.URL,
+		"aud":            "diary",
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          "the-nonce",
+		"email":          "testuser01@example.com",
+		"email_verified": true,
+	}
+}
+
+func (issuer *mockOIDCIssuer) sign(t *testing.T, claims jwt.MapClaims, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCExchangeVerifiesTheIDToken(t *testing.T) {
+	issuer := newMockOIDCIssuer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(claims jwt.MapClaims)
+		kid     string
+		key     *rsa.PrivateKey
+		wantErr string
+	}{
+		{name: "valid", change: func(jwt.MapClaims) {}},
+		{name: "other issuer", change: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }, wantErr: "issuer"},
+		{name: "other audience", change: func(claims jwt.MapClaims) { claims["aud"] = "another-client" }, wantErr: "audience"},
+		{name: "expired", change: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: "expired"},
+		{name: "no expiry", change: func(claims jwt.MapClaims) { delete(claims, "exp") }, wantErr: "expired"},
+		{name: "other nonce", change: func(claims jwt.MapClaims) { claims["nonce"] = "replayed" }, wantErr: "nonce"},
+		{name: "no nonce", change: func(claims jwt.MapClaims) { delete(claims, "nonce") }, wantErr: "nonce"},
+		{name: "no subject", change: func(claims jwt.MapClaims) { delete(claims, "sub") }, wantErr: "subject"},
+		{name: "signed by another key", change: func(jwt.MapClaims) {}, key: otherKey, wantErr: "verification"},
+		{name: "unknown key id", change: func(jwt.MapClaims) {}, kid: "key-2", wantErr: "unknown signing key"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := issuer.validClaims()
+			test.change(claims)
+			kid, key := test.kid, test.key
+			if kid == "" {
+				kid = "key-1"
+			}
+			if key == nil {
+				key = issuer.key
+			}
+			issuer.idToken = issuer.sign(t, claims, kid, key)
+
+			result, err := issuer.provider().Exchange("the-code", "the-verifier", "the-nonce")
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Subject != "subject-1" || result.Email != "testuser01@example.com" || !result.EmailVerified || result.Nonce != "the-nonce" {
+					t.Errorf("claims = %+v", result)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want one about %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRejectsUnsignedTokens(t *testing.T) {
+	issuer := newMockOIDCIssuer(t)
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodNone, jwt.SigningMethodHS256} {
+		token := jwt.NewWithClaims(method, issuer.validClaims())
+		token.Header["kid"] = "key-1"
+		var key interface{} = jwt.UnsafeAllowNoneSignatureType
+		if method == jwt.SigningMethodHS256 {
+			// The public key is known to everyone, so it must not work as an HMAC secret.
+			key = issuer.key.PublicKey.N.Bytes()
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issuer.idToken = signed
+		if _, err := issuer.provider().Exchange("the-code", "the-verifier", "the-nonce"); err == nil {
+			t.Errorf("an ID token signed with %s was accepted", method.Alg())
+		}
+	}
+}
+
+func TestOIDCExchangeSendsThePKCEVerifier(t *testing.T) {
+	issuer := newMockOIDCIssuer(t)
+	issuer.idToken = issuer.sign(t, issuer.validClaims(), "key-1", issuer.key)
+	if _, err := issuer.provider().Exchange("the-code", "another-verifier", "the-nonce"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("error = %v, want invalid_grant", err)
+	}
+}
+
+func TestOIDCUnknownKeyIdsRefreshTheKeysAtMostOnce(t *testing.T) {
+	issuer := newMockOIDCIssuer(t)
+	provider := issuer.provider()
+	for i := 0; i < 5; i++ {
+		issuer.idToken = issuer.sign(t, issuer.validClaims(), "rotated-key", issuer.key)
+		if _, err := provider.Exchange("the-code", "the-verifier", "the-nonce"); err == nil {
+			t.Fatal("an ID token with an unknown key id was accepted")
+		}
+	}
+	if calls := atomic.LoadInt32(&issuer.jwksCalls); calls != 1 {
+		t.Errorf("the keys were fetched %d times, want once per oidcMetadataMinRefreshInterval", calls)
+	}
+}
@@ -0,0 +1,316 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+PDFBook writes a simple book as a PDF: A4 pages of headings and wrapped paragraphs, with page numbers.
+
+The PDF is streamed: each page is written as soon as it is full, so that only one page is kept in memory.
+The text is set in the standard Helvetica font, which every PDF reader has, so that no font is embedded.
+Its encoding(WinAnsiEncoding) covers Western European languages, and other characters are replaced with "?".
+
+FYI: https://opensource.adobe.com/dc-acrobat-sdk-docs/pdfstandards/PDF32000_2008.pdf
+*/
+type PDFBook struct {
+	writer  io.Writer
+	written int
+	err     error
+	// offsets are the byte offsets of the objects by their number. Object 0 is not used.
+	offsets []int
+	pageIds []int
+	page    bytes.Buffer
+	inPage  bool
+	// y is the baseline of the next line on the page, from the bottom.
+	y     float64
+	title string
+}
+
+// Fixed object numbers of the PDF. The objects of the pages are numbered after them.
+const (
+	pdfCatalogId = iota + 1
+	pdfPagesId
+	pdfFontId
+	pdfBoldFontId
+	pdfInfoId
+)
+
+// The layout of the pages, in points(1/72 inch).
+const (
+	pdfPageWidth   = 595.28
+	pdfPageHeight  = 841.89
+	pdfMargin      = 72.0
+	pdfFontSize    = 11.0
+	pdfLeading     = 15.0
+	pdfHeadingSize = 15.0
+	pdfFooterSize  = 9.0
+)
+
+// helveticaWidths are the widths of the printable ASCII characters(from " " to "~") in Helvetica, in 1/1000 of the font size.
+// The other characters are measured as helveticaDefaultWidth.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+const helveticaDefaultWidth = 556
+
+// winAnsiCharacters are the characters of WinAnsiEncoding outside of Latin-1, which is the rest of the encoding.
+var winAnsiCharacters = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88, '‰': 0x89,
+	'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+/*
+NewPDFBook function:
+
+1. Writes the header of the PDF, and the fonts.
+
+2. Returns the book. The pages are added with Heading and Paragraph functions, and the PDF is finished with Close function.
+*/
+func NewPDFBook(writer io.Writer, title string) *PDFBook {
+	book := &PDFBook{writer: writer, offsets: make([]int, pdfInfoId+1), title: title}
+	// The second line marks the file as binary for the programs that transfer it.
+	book.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	book.writeObject(pdfFontId, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	book.writeObject(pdfBoldFontId, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	return book
+}
+
+/*
+Heading function:
+
+1. Starts a new page if the heading and the first lines after it do not fit on the current one.
+
+2. Draws the heading in bold.
+*/
+func (book *PDFBook) Heading(text string) {
+	if !book.inPage || book.y-(pdfHeadingSize+3*pdfLeading) < pdfMargin {
+		book.newPage()
+	} else {
+		book.y -= pdfLeading
+	}
+	book.y -= pdfHeadingSize - pdfFontSize
+	book.drawText("F2", pdfHeadingSize, pdfMargin, book.y, encodeWinAnsi(text))
+	book.y -= pdfHeadingSize + pdfLeading/2
+}
+
+/*
+Paragraph function:
+
+1. Wraps each line of the text to the width of the page. Blank lines are kept as space.
+
+2. Draws the lines, and starts a new page whenever the current one is full.
+*/
+func (book *PDFBook) Paragraph(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			book.y -= pdfLeading / 2
+			continue
+		}
+		for _, wrapped := range wrapWinAnsi(encodeWinAnsi(line), pdfFontSize, pdfPageWidth-2*pdfMargin) {
+			if !book.inPage || book.y < pdfMargin {
+				book.newPage()
+			}
+			book.drawText("F1", pdfFontSize, pdfMargin, book.y, wrapped)
+			book.y -= pdfLeading
+		}
+	}
+	book.y -= pdfLeading / 2
+}
+
+/*
+Close function:
+
+1. Finishes the last page. A book without content still has an empty page.
+
+2. Writes the page tree, the catalog, the document information, the cross-reference table and the trailer.
+
+3. Returns the first error of writing the PDF.
+*/
+func (book *PDFBook) Close() error {
+	if !book.inPage {
+		book.newPage()
+	}
+	book.finishPage()
+
+	kids := make([]string, len(book.pageIds))
+	for i, id := range book.pageIds {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	book.writeObject(pdfPagesId, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	book.writeObject(pdfCatalogId, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesId))
+	book.writeObject(pdfInfoId, fmt.Sprintf("<< /Title (%s) /Producer (diary_api) /CreationDate (D:%s) >>",
+		escapePDFString(encodeWinAnsi(book.title)), time.Now().UTC().Format("20060102150405Z")))
+
+	// The cross-reference table has an entry of exactly 20 bytes for each object.
+	xref := book.written
+	book.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(book.offsets)))
+	for _, offset := range book.offsets[1:] {
+		book.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	book.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(book.offsets), pdfCatalogId, pdfInfoId, xref))
+	return book.err
+}
+
+/*
+newPage function:
+
+1. Writes the current page, if any, and starts an empty one.
+*/
+func (book *PDFBook) newPage() {
+	if book.inPage {
+		book.finishPage()
+	}
+	book.inPage = true
+	book.page.Reset()
+	book.y = pdfPageHeight - pdfMargin
+}
+
+/*
+finishPage function:
+
+1. Draws the page number at the bottom of the page.
+
+2. Writes the content of the page and the page itself as objects.
+*/
+func (book *PDFBook) finishPage() {
+	number := []byte(fmt.Sprint(len(book.pageIds) + 1))
+	book.drawText("F1", pdfFooterSize, (pdfPageWidth-measureWinAnsi(number, pdfFooterSize))/2, pdfMargin/2, number)
+
+	contentId := book.newObjectId()
+	book.writeObject(contentId, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", book.page.Len(), book.page.String()))
+	pageId := book.newObjectId()
+	book.writeObject(pageId, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesId, pdfPageWidth, pdfPageHeight, pdfFontId, pdfBoldFontId, contentId))
+	book.pageIds = append(book.pageIds, pageId)
+	book.inPage = false
+}
+
+// drawText draws the encoded text on the current page with its baseline at (x, y).
+func (book *PDFBook) drawText(font string, size float64, x float64, y float64, text []byte) {
+	fmt.Fprintf(&book.page, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escapePDFString(text))
+}
+
+// newObjectId returns the number of a new object.
+func (book *PDFBook) newObjectId() int {
+	book.offsets = append(book.offsets, 0)
+	return len(book.offsets) - 1
+}
+
+// writeObject writes the object with its number, and records its offset.
+func (book *PDFBook) writeObject(id int, body string) {
+	book.offsets[id] = book.written
+	book.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", id, body))
+}
+
+// write writes to the writer, and keeps the first error, after which nothing is written.
+func (book *PDFBook) write(data string) {
+	if book.err != nil {
+		return
+	}
+	n, err := io.WriteString(book.writer, data)
+	book.written += n
+	book.err = err
+}
+
+/*
+encodeWinAnsi function:
+
+1. Encodes the text in WinAnsiEncoding. Control characters are left out, and the characters outside of the encoding are replaced with "?".
+*/
+func encodeWinAnsi(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0x20 || r == 0x7F:
+			continue
+		case r < 0x80, r >= 0xA0 && r <= 0xFF:
+			encoded = append(encoded, byte(r))
+		default:
+			if b, ok := winAnsiCharacters[r]; ok {
+				encoded = append(encoded, b)
+			} else {
+				encoded = append(encoded, '?')
+			}
+		}
+	}
+	return encoded
+}
+
+// measureWinAnsi returns the width of the encoded text in points.
+func measureWinAnsi(text []byte, size float64) float64 {
+	width := 0
+	for _, b := range text {
+		if b >= 0x20 && b <= 0x7E {
+			width += helveticaWidths[b-0x20]
+		} else {
+			width += helveticaDefaultWidth
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+/*
+wrapWinAnsi function:
+
+1. Breaks the encoded text into lines that fit in the width, between words.
+
+2. Words that are longer than a line are broken between characters.
+*/
+func wrapWinAnsi(text []byte, size float64, width float64) [][]byte {
+	lines := [][]byte{}
+	var line []byte
+	for _, word := range bytes.Fields(text) {
+		candidate := append(append(append([]byte{}, line...), ' '), word...)
+		if len(line) == 0 {
+			candidate = word
+		}
+		if measureWinAnsi(candidate, size) <= width {
+			line = candidate
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+		// Breaks the words that are longer than a line between characters.
+		for measureWinAnsi(word, size) > width {
+			end := 1
+			for end < len(word) && measureWinAnsi(word[:end+1], size) <= width {
+				end++
+			}
+			lines = append(lines, word[:end])
+			word = word[end:]
+		}
+		line = word
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escapePDFString escapes the backslashes and the parentheses of a literal string of the PDF.
+func escapePDFString(text []byte) string {
+	var escaped strings.Builder
+	for _, b := range text {
+		if b == '\\' || b == '(' || b == ')' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(b)
+	}
+	return escaped.String()
+}
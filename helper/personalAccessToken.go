@@ -0,0 +1,87 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"diary_api/model"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PersonalAccessTokenPrefix tells personal access tokens apart from JWTs.
+const PersonalAccessTokenPrefix = "dpat_"
+
+/*
+GeneratePersonalAccessToken function:
+
+1. Generates a random token.
+
+2. Returns the token, its display prefix and its SHA-256 hash.
+
+The token is only returned to the user once. Only the hash is stored.
+*/
+func GeneratePersonalAccessToken() (token string, prefix string, tokenHash string, err error) {
+	// Generates a random token.
+	randomBytes := make([]byte, 32)
+	if _, err = rand.Read(randomBytes); err != nil {
+		return "", "", "", err
+	}
+	token = PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+	// Returns the token, its display prefix and its SHA-256 hash.
+	return token, token[:len(PersonalAccessTokenPrefix)+6], HashPersonalAccessToken(token), nil
+}
+
+/*
+HashPersonalAccessToken function:
+
+1. Returns the hex encoded SHA-256 hash of the token.
+
+The token has 256 bits of entropy, so a fast hash is enough and allows looking it up by its hash.
+*/
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+authenticatePersonalAccessToken function:
+
+1. Finds the token by its hash.
+
+2. Rejects expired tokens, and tokens of disabled users.
+
+3. Updates the last-used timestamp.
+
+4. Stores the user id and the scopes in the context.
+*/
+func authenticatePersonalAccessToken(context *gin.Context, tokenString string) error {
+	// Finds the token by its hash.
+	token, err := model.FindPersonalAccessTokenByHash(HashPersonalAccessToken(tokenString))
+	if err != nil {
+		return err
+	}
+	// Rejects expired tokens, and tokens of disabled users.
+	if token.IsExpired() {
+		return errors.New("personal access token has expired")
+	}
+	user, err := model.FindUserById(token.UserID)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 || user.IsDisabled() {
+		return model.ErrUserDisabled
+	}
+	// Updates the last-used timestamp.
+	if err := token.Touch(); err != nil {
+		fmt.Println(err)
+	}
+	// Stores the user id and the scopes in the context.
+	context.Set(contextUserIdKey, token.UserID)
+	context.Set(contextAuthMethodKey, AuthMethodPersonalAccessToken)
+	context.Set(contextScopesKey, token.Scopes)
+	return nil
+}
@@ -0,0 +1,38 @@
+package helper
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// ShareTokenPrefix tells the tokens of share links apart from other tokens.
+const ShareTokenPrefix = "dshr_"
+
+/*
+GenerateShareToken function:
+
+1. Generates a random token of a share link.
+
+2. Returns the token, its display prefix and its SHA-256 hash.
+
+The token is only returned to the user once. Only the hash is stored.
+*/
+func GenerateShareToken() (token string, prefix string, tokenHash string, err error) {
+	// Generates a random token of a share link.
+	randomBytes := make([]byte, 32)
+	if _, err = rand.Read(randomBytes); err != nil {
+		return "", "", "", err
+	}
+	token = ShareTokenPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+	// Returns the token, its display prefix and its SHA-256 hash.
+	return token, token[:len(ShareTokenPrefix)+6], HashShareToken(token), nil
+}
+
+/*
+HashShareToken function:
+
+1. Returns the hex encoded SHA-256 hash of the token, the same way as HashPersonalAccessToken function.
+*/
+func HashShareToken(token string) string {
+	return HashPersonalAccessToken(token)
+}
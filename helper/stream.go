@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// contextStreamAbortedKey is the key of the context that marks a response that failed after its headers were sent.
+const contextStreamAbortedKey = "streamAborted"
+
+/*
+AbortStream function:
+
+1. Adds the error to the context, so that it is logged with the request.
+
+2. Marks the response as aborted. AbortStreamMiddleware then drops the connection,
+so that the client sees an incomplete response instead of a truncated file that looks complete.
+
+It is used once the status and the headers have been sent, e.g. by the streamed exports.
+*/
+func AbortStream(context *gin.Context, err error) {
+	_ = context.Error(err)
+	context.Set(contextStreamAbortedKey, true)
+}
+
+/*
+StreamAborted function:
+
+1. Returns true if the response has been marked by AbortStream function.
+*/
+func StreamAborted(context *gin.Context) bool {
+	return context.GetBool(contextStreamAbortedKey)
+}
@@ -0,0 +1,162 @@
+package helper
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// thumbnailJPEGQuality is the quality of the thumbnails encoded as JPEG.
+const thumbnailJPEGQuality = 82
+
+/*
+ToRGBA function:
+
+1. Converts the decoded image to RGBA, because reading the pixels of other types(e.g. YCbCr of JPEG) one by one is slow.
+*/
+func ToRGBA(source image.Image) *image.RGBA {
+	bounds := source.Bounds()
+	pixels := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(pixels, pixels.Bounds(), source, bounds.Min, draw.Src)
+	return pixels
+}
+
+/*
+ResizeImage function:
+
+1. Scales the image down to fit in a square of maxSide pixels, keeping its aspect ratio. Images that already fit are returned as they are.
+
+2. Each pixel of the result is the average of the pixels it covers(box filter), which keeps downscaled photos smooth.
+*/
+func ResizeImage(pixels *image.RGBA, maxSide int) *image.RGBA {
+	sourceWidth, sourceHeight := pixels.Bounds().Dx(), pixels.Bounds().Dy()
+	width, height := sourceWidth, sourceHeight
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			width, height = maxSide, max1(sourceHeight*maxSide/sourceWidth)
+		} else {
+			width, height = max1(sourceWidth*maxSide/sourceHeight), maxSide
+		}
+	}
+	if width == sourceWidth && height == sourceHeight {
+		return pixels
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, height, sourceHeight)
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, width, sourceWidth)
+			var r, g, b, a, count int
+			for sy := y0; sy < y1; sy++ {
+				row := pixels.Pix[sy*pixels.Stride+x0*4 : sy*pixels.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += int(row[i])
+					g += int(row[i+1])
+					b += int(row[i+2])
+					a += int(row[i+3])
+					count++
+				}
+			}
+			offset := y*resized.Stride + x*4
+			resized.Pix[offset] = uint8(r / count)
+			resized.Pix[offset+1] = uint8(g / count)
+			resized.Pix[offset+2] = uint8(b / count)
+			resized.Pix[offset+3] = uint8(a / count)
+		}
+	}
+	return resized
+}
+
+/*
+span function:
+
+1. Returns the range of source pixels [start, end) covered by the pixel(i) of a line of size pixels, scaled from sourceSize pixels.
+*/
+func span(i int, size int, sourceSize int) (int, int) {
+	start := i * sourceSize / size
+	end := (i + 1) * sourceSize / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+/*
+max1 function:
+
+1. Returns the value, or 1 if it is smaller, so that a very thin image keeps at least one pixel.
+*/
+func max1(value int) int {
+	if value < 1 {
+		return 1
+	}
+	return value
+}
+
+/*
+OrientImage function:
+
+1. Rotates and flips the image as its EXIF orientation(1-8) says, so that it is shown upright without the orientation.
+
+FYI: https://magnushoff.com/articles/jpeg-orientation/
+*/
+func OrientImage(source *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return source
+	}
+	width, height := source.Bounds().Dx(), source.Bounds().Dy()
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		// Orientations 5 to 8 turn the image by 90 degrees.
+		outWidth, outHeight = height, width
+	}
+	oriented := image.NewRGBA(image.Rect(0, 0, outWidth, outHeight))
+	for y := 0; y < outHeight; y++ {
+		for x := 0; x < outWidth; x++ {
+			// Finds the source pixel of each pixel of the result.
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = width-1-x, y
+			case 3:
+				sx, sy = width-1-x, height-1-y
+			case 4:
+				sx, sy = x, height-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, height-1-x
+			case 7:
+				sx, sy = width-1-y, height-1-x
+			case 8:
+				sx, sy = width-1-y, x
+			}
+			copy(oriented.Pix[y*oriented.Stride+x*4:y*oriented.Stride+x*4+4], source.Pix[sy*source.Stride+sx*4:sy*source.Stride+sx*4+4])
+		}
+	}
+	return oriented
+}
+
+/*
+EncodeThumbnail function:
+
+1. Encodes the image as PNG if it has transparent pixels, and as JPEG otherwise.
+
+2. Returns the encoded image and its content type.
+*/
+func EncodeThumbnail(thumbnail *image.RGBA) ([]byte, string, error) {
+	var out bytes.Buffer
+	if !thumbnail.Opaque() {
+		if err := png.Encode(&out, thumbnail); err != nil {
+			return nil, "", err
+		}
+		return out.Bytes(), "image/png", nil
+	}
+	if err := jpeg.Encode(&out, thumbnail, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), "image/jpeg", nil
+}
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"diary_api/model"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// accountPurgeInterval is how often the accounts due for deletion are looked up.
+const accountPurgeInterval = time.Hour
+
+/*
+StartAccountPurger function:
+
+1. Starts a goroutine that executes purgeAccounts function on startup and every accountPurgeInterval.
+
+Accounts are hard deleted once the grace period set by DELETE /api/account has passed.
+*/
+func StartAccountPurger() {
+	go func() {
+		ticker := time.NewTicker(accountPurgeInterval)
+		defer ticker.Stop()
+		for {
+			purgeAccounts()
+			<-ticker.C
+		}
+	}()
+}
+
+/*
+purgeAccounts function:
+
+1. Executes model.FindUserIdsDueForDeletion function.
+
+2. Executes model.HardDeleteUser function for each user, which checks again that the user is still due for deletion.
+*/
+func purgeAccounts() {
+	// Executes model.FindUserIdsDueForDeletion function.
+	userIds, err := model.FindUserIdsDueForDeletion()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	// Executes model.HardDeleteUser function for each user.
+	for _, userId := range userIds {
+		err := model.HardDeleteUser(userId)
+		if errors.Is(err, model.ErrUserDeletionCancelled) {
+			// The deletion has been cancelled since the user was found, so the user is kept.
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Error purging the account [userId: %d]: %v\n", userId, err)
+			continue
+		}
+		fmt.Printf("Purged the account [userId: %d]\n", userId)
+	}
+}
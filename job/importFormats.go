@@ -0,0 +1,543 @@
+package job
+
+import (
+	"archive/zip"
+	"diary_api/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxImportedEntrySize is the maximum size of a Markdown file of an imported ZIP in bytes.
+const maxImportedEntrySize = 10 << 20
+
+// zipMagic is the first bytes of a ZIP file.
+const zipMagic = "PK\x03\x04"
+
+// errNotZip is returned when a format that needs a ZIP is given another file.
+var errNotZip = errors.New("the file is not a ZIP")
+
+var (
+	// markdownLinkPattern matches the targets of the links and the images of Markdown, e.g. ![](photos/a.jpg).
+	markdownLinkPattern = regexp.MustCompile(`\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+	// datePrefixPattern matches a date at the start of a file name, e.g. 2023-05-13-12.md.
+	datePrefixPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})`)
+)
+
+// frontMatterTimeLayouts are the layouts of the timestamps accepted in front matter.
+var frontMatterTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+/*
+importItem struct:
+
+1. Name
+
+2. Err
+
+3. Content, Format
+
+4. EntryDate, TimeZone, CreatedAt
+
+5. Tags, Mood, Fields
+
+6. Status
+
+7. Skip
+
+8. Attachments
+
+An entry read from an imported file, before it is saved. Err is set if the item could not be read.
+*/
+type importItem struct {
+	// Name identifies the item in the errors of the job, e.g. the file name in a ZIP or the index of an entry in a JSON file.
+	Name      string
+	Err       error
+	Content   string
+	Format    string
+	EntryDate *model.Date
+	TimeZone  string
+	CreatedAt *time.Time
+	Tags      []string
+	Mood      *int
+	Fields    map[string]interface{}
+	Status    string
+	// Encrypted items are imported as encrypted entries, whose content must be an envelope encrypted with the key of the user.
+	Encrypted bool
+	// Skip is set for the items that are not imported, e.g. the deleted entries of an account export.
+	Skip        bool
+	Attachments []*zip.File
+}
+
+// importReader reads the items of an imported file, and executes visit for each of them in order.
+type importReader func(file io.ReaderAt, size int64, visit func(item importItem) error) error
+
+// importReaders are the readers of the import formats.
+var importReaders = map[string]importReader{
+	model.ImportFormatDayOne:   readDayOneImport,
+	model.ImportFormatMarkdown: readMarkdownImport,
+	model.ImportFormatJSON:     readJSONImport,
+}
+
+/*
+isZip function:
+
+1. Returns whether the file starts like a ZIP.
+*/
+func isZip(file io.ReaderAt) bool {
+	magic := make([]byte, len(zipMagic))
+	n, _ := file.ReadAt(magic, 0)
+	return string(magic[:n]) == zipMagic
+}
+
+/*
+eachJSONArrayItem function:
+
+1. Decodes the top-level object of the JSON one token at a time, and skips its values until the key.
+
+2. Executes visit with each item of the array at the key, so that the whole file is never loaded into memory at once.
+*/
+func eachJSONArrayItem(reader io.Reader, key string, visit func(index int, raw json.RawMessage) error) error {
+	decoder := json.NewDecoder(reader)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return errors.New("the file is not a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token != key {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return fmt.Errorf("%s must be an array", key)
+		}
+		for index := 0; decoder.More(); index++ {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return err
+			}
+			if err := visit(index, raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("the file has no %s", key)
+}
+
+/*
+dayOneEntry struct:
+
+An entry of the JSON export of Day One.
+*/
+type dayOneEntry struct {
+	Text         string    `json:"text"`
+	CreationDate time.Time `json:"creationDate"`
+	TimeZone     string    `json:"timeZone"`
+	Tags         []string  `json:"tags"`
+	Photos       []struct {
+		MD5  string `json:"md5"`
+		Type string `json:"type"`
+	} `json:"photos"`
+}
+
+/*
+readDayOneImport function:
+
+1. Reads the JSON export of Day One, alone or in the ZIP Day One exports, where every JSON file is a journal and the photos are in photos/.
+
+2. Keeps the text as Markdown, and sets the entry date to the local date of creationDate in the time zone of the entry.
+
+3. Attaches the photos of each entry that are in the ZIP, found by their MD5.
+*/
+func readDayOneImport(file io.ReaderAt, size int64, visit func(item importItem) error) error {
+	if !isZip(file) {
+		return readDayOneJournal(io.NewSectionReader(file, 0, size), "", nil, visit)
+	}
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+	files := map[string]*zip.File{}
+	journals := []*zip.File{}
+	for _, zipFile := range archive.File {
+		files[zipFile.Name] = zipFile
+		if path.Ext(zipFile.Name) == ".json" && !strings.Contains(zipFile.Name, "/") {
+			journals = append(journals, zipFile)
+		}
+	}
+	if len(journals) == 0 {
+		return errors.New("the ZIP has no journal(JSON file)")
+	}
+	for _, journal := range journals {
+		reader, err := journal.Open()
+		if err != nil {
+			return err
+		}
+		err = readDayOneJournal(reader, journal.Name+" ", files, visit)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", journal.Name, err)
+		}
+	}
+	return nil
+}
+
+/*
+readDayOneJournal function:
+
+1. Executes eachJSONArrayItem function with the entries of the journal, and converts each of them to an item.
+*/
+func readDayOneJournal(reader io.Reader, prefix string, files map[string]*zip.File, visit func(item importItem) error) error {
+	return eachJSONArrayItem(reader, "entries", func(index int, raw json.RawMessage) error {
+		item := importItem{Name: fmt.Sprintf("%sentries[%d]", prefix, index), Format: model.EntryFormatCommonMark}
+		var entry dayOneEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			item.Err = err
+			return visit(item)
+		}
+		item.Content = entry.Text
+		item.Tags = entry.Tags
+		if !entry.CreationDate.IsZero() {
+			created := entry.CreationDate
+			item.CreatedAt = &created
+			location := time.UTC
+			if loaded, err := time.LoadLocation(entry.TimeZone); err == nil && entry.TimeZone != "" {
+				location = loaded
+				item.TimeZone = entry.TimeZone
+			}
+			date := model.NewDate(created.In(location))
+			item.EntryDate = &date
+		}
+		for _, photo := range entry.Photos {
+			if zipFile, ok := files[fmt.Sprintf("photos/%s.%s", photo.MD5, photo.Type)]; ok {
+				item.Attachments = append(item.Attachments, zipFile)
+			}
+		}
+		return visit(item)
+	})
+}
+
+/*
+exportedEntry struct:
+
+An entry of the JSON export of this app.
+*/
+type exportedEntry struct {
+	Content   string      `json:"content"`
+	EntryDate *model.Date `json:"entry_date"`
+	TimeZone  string      `json:"time_zone"`
+	Tags      []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+	Mood      *int                   `json:"mood"`
+	Fields    map[string]interface{} `json:"fields"`
+	Format    string                 `json:"format"`
+	Status    string                 `json:"status"`
+	Encrypted bool                   `json:"encrypted"`
+	CreatedAt time.Time              `json:"CreatedAt"`
+	DeletedAt *time.Time             `json:"DeletedAt"`
+}
+
+/*
+readJSONImport function:
+
+1. Reads the JSON export of this app: GET /api/entry/export?format=json, or manifest.json in the ZIP of GET /api/account/export.
+
+2. Converts each entry to an item. The deleted entries of an account export are skipped.
+The attachments are not in the JSON export, so they are not imported.
+*/
+func readJSONImport(file io.ReaderAt, size int64, visit func(item importItem) error) error {
+	var reader io.Reader = io.NewSectionReader(file, 0, size)
+	if isZip(file) {
+		archive, err := zip.NewReader(file, size)
+		if err != nil {
+			return err
+		}
+		manifest, err := archive.Open("manifest.json")
+		if err != nil {
+			return errors.New("the ZIP has no manifest.json")
+		}
+		defer manifest.Close()
+		reader = manifest
+	}
+	return eachJSONArrayItem(reader, "entries", func(index int, raw json.RawMessage) error {
+		item := importItem{Name: fmt.Sprintf("entries[%d]", index)}
+		var entry exportedEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			item.Err = err
+			return visit(item)
+		}
+		item.Content = entry.Content
+		item.EntryDate = entry.EntryDate
+		item.TimeZone = entry.TimeZone
+		for _, tag := range entry.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		item.Mood = entry.Mood
+		item.Fields = entry.Fields
+		item.Format = entry.Format
+		item.Status = entry.Status
+		item.Encrypted = entry.Encrypted
+		if !entry.CreatedAt.IsZero() {
+			item.CreatedAt = &entry.CreatedAt
+		}
+		item.Skip = entry.DeletedAt != nil
+		return visit(item)
+	})
+}
+
+/*
+readMarkdownImport function:
+
+1. Reads every Markdown file(.md, .markdown) of the ZIP in the order of their names. Hidden files and the folders macOS adds are ignored.
+
+2. Reads the front matter of each file, and attaches the files of the ZIP its links and images point to, e.g. ![](photos/a.jpg).
+*/
+func readMarkdownImport(file io.ReaderAt, size int64, visit func(item importItem) error) error {
+	if !isZip(file) {
+		return errNotZip
+	}
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+	files := map[string]*zip.File{}
+	markdownFiles := []*zip.File{}
+	for _, zipFile := range archive.File {
+		files[zipFile.Name] = zipFile
+		base := path.Base(zipFile.Name)
+		if zipFile.FileInfo().IsDir() || strings.HasPrefix(zipFile.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		if extension := strings.ToLower(path.Ext(base)); extension == ".md" || extension == ".markdown" {
+			markdownFiles = append(markdownFiles, zipFile)
+		}
+	}
+	sort.Slice(markdownFiles, func(i, j int) bool { return markdownFiles[i].Name < markdownFiles[j].Name })
+
+	for _, markdownFile := range markdownFiles {
+		item, err := readMarkdownFile(markdownFile, files)
+		item.Name = markdownFile.Name
+		item.Err = err
+		if err := visit(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+readMarkdownFile function:
+
+1. Reads the file, and executes parseFrontMatter function.
+
+2. Sets the entry from the front matter: date, time_zone, tags, mood, fields, format, encrypted and created_at.
+A title becomes the first heading, unless the entry is encrypted. Without a date, the date at the start of the file name is used, e.g. 2023-05-13-12.md.
+
+3. Sets the attachments to the files of the ZIP the links point to, relative to the Markdown file.
+*/
+func readMarkdownFile(markdownFile *zip.File, files map[string]*zip.File) (importItem, error) {
+	item := importItem{Format: model.EntryFormatCommonMark}
+	// Reads the file.
+	if markdownFile.UncompressedSize64 > maxImportedEntrySize {
+		return item, fmt.Errorf("the file must not be larger than %d bytes", maxImportedEntrySize)
+	}
+	reader, err := markdownFile.Open()
+	if err != nil {
+		return item, err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxImportedEntrySize))
+	reader.Close()
+	if err != nil {
+		return item, err
+	}
+	values, body, err := parseFrontMatter(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	if err != nil {
+		return item, err
+	}
+	item.Content = body
+
+	// Sets the entry from the front matter.
+	for _, key := range []string{"date", "entry_date", "created_at", "created"} {
+		value, ok := values[key].(string)
+		if !ok || value == "" {
+			continue
+		}
+		if date, err := model.ParseDate(value); err == nil {
+			if item.EntryDate == nil {
+				item.EntryDate = &date
+			}
+			continue
+		}
+		created, err := parseFrontMatterTime(value)
+		if err != nil {
+			return item, fmt.Errorf("%s: %w", key, err)
+		}
+		if item.CreatedAt == nil {
+			item.CreatedAt = &created
+		}
+		if item.EntryDate == nil {
+			date := model.NewDate(created)
+			item.EntryDate = &date
+		}
+	}
+	if item.EntryDate == nil {
+		if match := datePrefixPattern.FindStringSubmatch(path.Base(markdownFile.Name)); match != nil {
+			if date, err := model.ParseDate(match[1]); err == nil {
+				item.EntryDate = &date
+			}
+		}
+	}
+	if item.EntryDate == nil {
+		return item, errors.New("the date is missing: set date in the front matter or start the file name with it")
+	}
+	for _, key := range []string{"time_zone", "timezone"} {
+		if value, ok := values[key].(string); ok {
+			item.TimeZone = value
+		}
+	}
+	switch tags := values["tags"].(type) {
+	case []interface{}:
+		for _, tag := range tags {
+			item.Tags = append(item.Tags, fmt.Sprint(tag))
+		}
+	case string:
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+	}
+	if mood, ok := values["mood"].(float64); ok {
+		value := int(mood)
+		item.Mood = &value
+	}
+	if fields, ok := values["fields"].(map[string]interface{}); ok {
+		item.Fields = fields
+	}
+	if format, ok := values["format"].(string); ok {
+		item.Format = format
+	}
+	if encrypted, ok := values["encrypted"].(bool); ok {
+		item.Encrypted = encrypted
+	}
+	if title, ok := values["title"].(string); ok && title != "" && !item.Encrypted {
+		item.Content = fmt.Sprintf("# %s\n\n%s", title, item.Content)
+	}
+
+	// Sets the attachments to the files of the ZIP the links point to.
+	attached := map[string]bool{}
+	for _, match := range markdownLinkPattern.FindAllStringSubmatch(item.Content, -1) {
+		target, err := url.PathUnescape(match[1])
+		if err != nil || strings.Contains(target, ":") || strings.HasPrefix(target, "#") {
+			continue
+		}
+		name := path.Join(path.Dir(markdownFile.Name), target)
+		if zipFile, ok := files[name]; ok && !attached[name] && !zipFile.FileInfo().IsDir() {
+			attached[name] = true
+			item.Attachments = append(item.Attachments, zipFile)
+		}
+	}
+	return item, nil
+}
+
+/*
+parseFrontMatter function:
+
+1. If the source starts with "---", reads the front matter up to the next "---" line, and returns the rest as the body.
+
+2. Reads each "key: value" line with parseFrontMatterValue function, and the "- item" lines after a key without a value as a list.
+
+It reads the simple YAML that journaling apps and static site generators write, not every YAML document.
+*/
+func parseFrontMatter(source string) (values map[string]interface{}, body string, err error) {
+	values = map[string]interface{}{}
+	if !strings.HasPrefix(source, "---\n") {
+		return values, source, nil
+	}
+	lines := strings.Split(source, "\n")
+	listKey := ""
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		if line == "---" || line == "..." {
+			return values, strings.TrimLeft(strings.Join(lines[i+1:], "\n"), "\n"), nil
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") && listKey != "" {
+			list, _ := values[listKey].([]interface{})
+			values[listKey] = append(list, parseFrontMatterValue(strings.TrimSpace(trimmed[2:])))
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, "", fmt.Errorf("invalid front matter line %d: %q", i+1, line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		listKey = ""
+		if value == "" {
+			listKey = key
+			values[key] = []interface{}{}
+			continue
+		}
+		values[key] = parseFrontMatterValue(value)
+	}
+	return nil, "", errors.New("the front matter is not closed with ---")
+}
+
+/*
+parseFrontMatterValue function:
+
+1. Reads the value as JSON, which is also valid YAML, e.g. "2023-05-13", 4, ["a","b"] or {"sleep":8}.
+
+2. Otherwise reads a list without quotes, e.g. [a, b], or a string, without its single quotes if any.
+*/
+func parseFrontMatterValue(value string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+		return decoded
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		list := []interface{}{}
+		for _, element := range strings.Split(value[1:len(value)-1], ",") {
+			if element = strings.Trim(strings.TrimSpace(element), `"'`); element != "" {
+				list = append(list, element)
+			}
+		}
+		return list
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+/*
+parseFrontMatterTime function:
+
+1. Parses a timestamp in one of frontMatterTimeLayouts. Timestamps without an offset are read as UTC.
+*/
+func parseFrontMatterTime(value string) (time.Time, error) {
+	for _, layout := range frontMatterTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %q", value)
+}
@@ -0,0 +1,241 @@
+package job
+
+import (
+	"archive/zip"
+	"bytes"
+	"diary_api/model"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// zipFile is a file of a ZIP built by buildZip.
+type zipFile struct {
+	name    string
+	content string
+}
+
+func buildZip(t *testing.T, files ...zipFile) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, file := range files {
+		entry, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func readImport(t *testing.T, format string, data []byte) ([]importItem, error) {
+	t.Helper()
+	items := []importItem{}
+	err := importReaders[format](bytes.NewReader(data), int64(len(data)), func(item importItem) error {
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+func attachmentNames(item importItem) []string {
+	names := []string{}
+	for _, file := range item.Attachments {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+func dateString(date *model.Date) string {
+	if date == nil {
+		return ""
+	}
+	return date.String()
+}
+
+func TestReadDayOneImport(t *testing.T) {
+	journal := `{"metadata":{"version":"1.0"},"entries":[
+		{"text":"Late night","creationDate":"2023-05-13T16:30:00Z","timeZone":"Asia/Tokyo","tags":["travel"],"photos":[{"md5":"abc","type":"jpeg"},{"md5":"missing","type":"jpeg"}]},
+		{"text":"No time zone","creationDate":"2023-05-13T16:30:00Z"},
+		{"text":1}
+	]}`
+
+	t.Run("JSON", func(t *testing.T) {
+		items, err := readImport(t, model.ImportFormatDayOne, []byte(journal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 3 {
+			t.Fatalf("read %d items, want 3", len(items))
+		}
+		// The entry date is the local date in the time zone of the entry.
+		if got := dateString(items[0].EntryDate); got != "2023-05-14" || items[0].TimeZone != "Asia/Tokyo" {
+			t.Errorf("entry date = %s %s, want 2023-05-14 Asia/Tokyo", got, items[0].TimeZone)
+		}
+		if items[0].Content != "Late night" || items[0].Format != model.EntryFormatCommonMark || !reflect.DeepEqual(items[0].Tags, []string{"travel"}) {
+			t.Errorf("item = %+v", items[0])
+		}
+		if got := dateString(items[1].EntryDate); got != "2023-05-13" || items[1].TimeZone != "" {
+			t.Errorf("entry date without a time zone = %s %q, want 2023-05-13 in UTC", got, items[1].TimeZone)
+		}
+		if items[2].Err == nil || items[2].Name != "entries[2]" {
+			t.Errorf("invalid item = %+v, want an error", items[2])
+		}
+	})
+
+	t.Run("ZIP with photos", func(t *testing.T) {
+		data := buildZip(t,
+			zipFile{"Journal.json", journal},
+			zipFile{"photos/abc.jpeg", "jpeg"},
+		)
+		items, err := readImport(t, model.ImportFormatDayOne, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if items[0].Name != "Journal.json entries[0]" {
+			t.Errorf("name = %q", items[0].Name)
+		}
+		if got := attachmentNames(items[0]); !reflect.DeepEqual(got, []string{"photos/abc.jpeg"}) {
+			t.Errorf("attachments = %v, want the photo in the ZIP", got)
+		}
+	})
+
+	t.Run("ZIP without a journal", func(t *testing.T) {
+		if _, err := readImport(t, model.ImportFormatDayOne, buildZip(t, zipFile{"photos/abc.jpeg", "jpeg"})); err == nil {
+			t.Error("no error for a ZIP without a journal")
+		}
+	})
+}
+
+func TestReadJSONImport(t *testing.T) {
+	export := `{"user":{"username":"testuser01"},"entries":[
+		{"content":"Hello","entry_date":"2023-05-13","time_zone":"Asia/Tokyo","tags":[{"name":"work"}],"mood":4,"fields":{"sleep":8},"format":"commonmark","status":"published","CreatedAt":"2023-05-13T12:00:00Z"},
+		{"content":"Deleted","entry_date":"2023-05-12","DeletedAt":"2023-05-20T00:00:00Z"},
+		"not an entry"
+	]}`
+
+	for name, data := range map[string][]byte{
+		"entry export":   []byte(export),
+		"account export": buildZip(t, zipFile{"manifest.json", export}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			items, err := readImport(t, model.ImportFormatJSON, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 3 {
+				t.Fatalf("read %d items, want 3", len(items))
+			}
+			first := items[0]
+			if first.Content != "Hello" || dateString(first.EntryDate) != "2023-05-13" || first.TimeZone != "Asia/Tokyo" ||
+				!reflect.DeepEqual(first.Tags, []string{"work"}) || first.Mood == nil || *first.Mood != 4 ||
+				first.Fields["sleep"] != float64(8) || first.Format != "commonmark" || first.CreatedAt == nil || first.Skip {
+				t.Errorf("item = %+v", first)
+			}
+			if !items[1].Skip {
+				t.Error("the deleted entry is not skipped")
+			}
+			if items[2].Err == nil {
+				t.Error("no error for an invalid entry")
+			}
+		})
+	}
+
+	t.Run("ZIP without a manifest", func(t *testing.T) {
+		if _, err := readImport(t, model.ImportFormatJSON, buildZip(t, zipFile{"entries.json", export})); err == nil {
+			t.Error("no error for a ZIP without manifest.json")
+		}
+	})
+	t.Run("no entries", func(t *testing.T) {
+		if _, err := readImport(t, model.ImportFormatJSON, []byte(`{"data":[]}`)); err == nil {
+			t.Error("no error for a file without entries")
+		}
+	})
+}
+
+func TestReadMarkdownImport(t *testing.T) {
+	data := buildZip(t,
+		zipFile{"2023/2023-05-13-trip.md", "---\ntitle: Kyoto\ntags: [travel, food]\nmood: 5\nfields: {\"sleep\": 7}\ntime_zone: Asia/Tokyo\n---\n\nTemples.\n\n![](photos/a%20b.jpg) ![](photos/a%20b.jpg) [x](https://example.com)\n"},
+		zipFile{"2023/photos/a b.jpg", "jpeg"},
+		zipFile{"notes.markdown", "---\ndate: 2023-05-14 21:30:00+09:00\ntags:\n  - home\n  - 'cat''s'\n---\nAt home."},
+		zipFile{"undated.md", "No date."},
+		zipFile{"__MACOSX/2023/._2023-05-13-trip.md", "resource fork"},
+		zipFile{".hidden.md", "hidden"},
+		zipFile{"readme.txt", "not Markdown"},
+	)
+	items, err := readImport(t, model.ImportFormatMarkdown, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	if want := []string{"2023/2023-05-13-trip.md", "notes.markdown", "undated.md"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("items = %v, want %v", names, want)
+	}
+
+	// The date comes from the file name, and the title becomes the first heading.
+	trip := items[0]
+	if trip.Err != nil || dateString(trip.EntryDate) != "2023-05-13" || trip.TimeZone != "Asia/Tokyo" ||
+		!reflect.DeepEqual(trip.Tags, []string{"travel", "food"}) || trip.Mood == nil || *trip.Mood != 5 || trip.Fields["sleep"] != float64(7) {
+		t.Errorf("item = %+v", trip)
+	}
+	if want := "# Kyoto\n\nTemples.\n\n"; trip.Content[:len(want)] != want {
+		t.Errorf("content = %q, want it to start with %q", trip.Content, want)
+	}
+	if got := attachmentNames(trip); !reflect.DeepEqual(got, []string{"2023/photos/a b.jpg"}) {
+		t.Errorf("attachments = %v, want the image once", got)
+	}
+
+	// The date comes from the timestamp in the front matter.
+	notes := items[1]
+	if notes.Err != nil || dateString(notes.EntryDate) != "2023-05-14" || notes.CreatedAt == nil ||
+		!reflect.DeepEqual(notes.Tags, []string{"home", "cat's"}) || notes.Content != "At home." {
+		t.Errorf("item = %+v", notes)
+	}
+
+	if items[2].Err == nil {
+		t.Error("no error for a file without a date")
+	}
+
+	t.Run("not a ZIP", func(t *testing.T) {
+		if _, err := readImport(t, model.ImportFormatMarkdown, []byte("# Title")); !errors.Is(err, errNotZip) {
+			t.Errorf("error = %v, want errNotZip", err)
+		}
+	})
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		wantValues map[string]interface{}
+		wantBody   string
+		wantErr    bool
+	}{
+		{"no front matter", "Just text", map[string]interface{}{}, "Just text", false},
+		{"values", "---\nMood: 3\nencrypted: true\ntitle: 'It''s'\nlist: [a, \"b\"]\n# comment\n---\nBody",
+			map[string]interface{}{"mood": float64(3), "encrypted": true, "title": "It's", "list": []interface{}{"a", "b"}}, "Body", false},
+		{"closed with ...", "---\ndate: 2023-05-13\n...\n\nBody", map[string]interface{}{"date": "2023-05-13"}, "Body", false},
+		{"not closed", "---\ndate: 2023-05-13\nBody", nil, "", true},
+		{"invalid line", "---\nno colon\n---\nBody", nil, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, body, err := parseFrontMatter(test.source)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want an error: %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(values, test.wantValues) || body != test.wantBody {
+				t.Errorf("parseFrontMatter() = %#v, %q, want %#v, %q", values, body, test.wantValues, test.wantBody)
+			}
+		})
+	}
+}
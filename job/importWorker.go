@@ -0,0 +1,320 @@
+package job
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"diary_api/helper"
+	"diary_api/model"
+	"diary_api/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// importPollInterval is how often the pending imports are looked up when no upload wakes the worker.
+const importPollInterval = time.Minute
+
+// importProgressInterval is how often the progress of a running import is saved, so that clients can poll it.
+const importProgressInterval = 2 * time.Second
+
+// errDuplicateEntry is returned when an imported entry has the same date and content as an existing one.
+var errDuplicateEntry = errors.New("duplicate entry")
+
+// importWake wakes the worker when a file is uploaded. Its buffer of 1 collapses concurrent wake-ups.
+var importWake = make(chan struct{}, 1)
+
+/*
+StartImportWorker function:
+
+1. Starts a goroutine that executes runPendingImports function on startup,
+when WakeImportWorker function is executed, and every importPollInterval.
+
+Several instances of the app can run the worker, because each job is claimed by one of them(model.ClaimImportJob).
+*/
+func StartImportWorker() {
+	go func() {
+		for {
+			runPendingImports()
+			select {
+			case <-importWake:
+			case <-time.After(importPollInterval):
+			}
+		}
+	}()
+}
+
+/*
+WakeImportWorker function:
+
+1. Wakes the worker without waiting for it, e.g. after a file is uploaded.
+*/
+func WakeImportWorker() {
+	select {
+	case importWake <- struct{}{}:
+	default:
+	}
+}
+
+/*
+runPendingImports function:
+
+1. Executes model.ClaimImportJob function and runImport function until no jobs are pending.
+
+2. Finishes each job, and deletes its file from the blob store once it has completed or failed.
+*/
+func runPendingImports() {
+	for {
+		importJob, found, err := model.ClaimImportJob()
+		if err != nil {
+			fmt.Printf("Error claiming an import job: %v\n", err)
+			return
+		}
+		if !found {
+			return
+		}
+		runErr := runImport(&importJob)
+		if err := importJob.Finish(runErr); err != nil {
+			fmt.Printf("Error finishing the import job [importJobId: %d]: %v\n", importJob.ID, err)
+			continue
+		}
+		if err := storage.Blobs.Delete(context.Background(), importJob.StorageKey); err != nil {
+			fmt.Printf("Error deleting the blob [key: %s]: %v\n", importJob.StorageKey, err)
+		}
+	}
+}
+
+/*
+runImport function:
+
+1. Copies the uploaded file from the blob store to a temporary file, because ZIPs are read at random.
+
+2. Reads the file once to count the items, so that the progress has a total.
+
+3. Reads it again and executes importEntry function with each item, saving the progress every importProgressInterval.
+Items that cannot be imported are recorded on the job, and the others are still imported.
+*/
+func runImport(importJob *model.ImportJob) error {
+	ctx := context.Background()
+	read, ok := importReaders[importJob.Format]
+	if !ok {
+		return fmt.Errorf("unknown import format: %s", importJob.Format)
+	}
+	profile, err := model.FindProfileByUserId(importJob.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Copies the uploaded file from the blob store to a temporary file.
+	blob, err := storage.Blobs.Open(ctx, importJob.StorageKey)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", "diary-import-*")
+	if err != nil {
+		blob.Close()
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	size, err := io.Copy(file, blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+
+	// Reads the file once to count the items.
+	err = read(file, size, func(item importItem) error {
+		importJob.Total++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := importJob.SaveProgress(); err != nil {
+		return err
+	}
+
+	// Reads it again and executes importEntry function with each item.
+	hashes := map[string]map[string]bool{}
+	lastSaved := time.Now()
+	err = read(file, size, func(item importItem) error {
+		importJob.Processed++
+		switch {
+		case item.Err != nil:
+			importJob.AddError(item.Name, item.Err)
+		case item.Skip:
+			importJob.Skipped++
+		default:
+			err := importEntry(ctx, importJob, profile, item, hashes)
+			if errors.Is(err, errDuplicateEntry) {
+				importJob.Skipped++
+			} else if err != nil {
+				importJob.AddError(item.Name, err)
+			} else {
+				importJob.Imported++
+			}
+		}
+		if time.Since(lastSaved) >= importProgressInterval {
+			lastSaved = time.Now()
+			return importJob.SaveProgress()
+		}
+		return nil
+	})
+	return err
+}
+
+/*
+importEntry function:
+
+1. Sets the content, the format, the status, the encryption, the entry date and the time zone of the entry as AddEntry function does.
+Encrypted entries are checked against the key of the user by (*model.Entry).Save function. Time zones that do not exist are replaced with the time zone of the user.
+
+2. If an entry of the user on the same date has the same content hash(model.ImportContentHash), errDuplicateEntry is returned.
+The hashes of each date are loaded once per job, and the imported entries are added to them.
+
+3. Sets the tags, the mood and the custom fields, and keeps the original creation time.
+
+4. Executes (*model.Entry).Save function, and executes importAttachment function with each attachment.
+An attachment that fails is recorded on the job, and the entry is kept.
+*/
+func importEntry(ctx context.Context, importJob *model.ImportJob, profile model.Profile, item importItem, hashes map[string]map[string]bool) error {
+	// Sets the content, the format, the status, the entry date and the time zone of the entry.
+	if strings.TrimSpace(item.Content) == "" {
+		return errors.New("the entry has no content")
+	}
+	entry := model.Entry{Content: item.Content, UserID: importJob.UserID, Status: model.EntryStatusPublished, Format: model.EntryFormatPlain, JournalID: importJob.JournalID, Encrypted: item.Encrypted}
+	switch item.Format {
+	case "", model.EntryFormatPlain:
+	case model.EntryFormatCommonMark:
+		entry.Format = model.EntryFormatCommonMark
+	default:
+		return fmt.Errorf("format must be plain or commonmark: %s", item.Format)
+	}
+	if item.Status == model.EntryStatusDraft {
+		entry.Status = model.EntryStatusDraft
+	}
+	var timeZone *string
+	if _, err := time.LoadLocation(item.TimeZone); err == nil && item.TimeZone != "" && item.TimeZone != "Local" {
+		timeZone = &item.TimeZone
+	}
+	if err := entry.ApplyEntryDate(item.EntryDate, timeZone, profile.TimeZone); err != nil {
+		return err
+	}
+
+	// If an entry of the user on the same date has the same content hash, errDuplicateEntry is returned.
+	date := entry.EntryDate.String()
+	if hashes[date] == nil {
+		dateHashes, err := model.FindEntryContentHashes(importJob.UserID, importJob.JournalID, entry.EntryDate)
+		if err != nil {
+			return err
+		}
+		hashes[date] = dateHashes
+	}
+	hash := model.ImportContentHash(entry.Content)
+	if hashes[date][hash] {
+		return errDuplicateEntry
+	}
+
+	// Sets the tags, the mood and the custom fields, and keeps the original creation time.
+	if err := entry.ApplyTags(item.Tags, false); err != nil {
+		return err
+	}
+	if item.Mood != nil && (*item.Mood < 1 || *item.Mood > 5) {
+		return fmt.Errorf("mood must be between 1 and 5: %d", *item.Mood)
+	}
+	entry.Mood = item.Mood
+	if err := entry.ApplyFields(item.Fields); err != nil {
+		return err
+	}
+	if item.CreatedAt != nil {
+		entry.CreatedAt = *item.CreatedAt
+		entry.UpdatedAt = *item.CreatedAt
+	}
+
+	// Executes (*model.Entry).Save function, and executes importAttachment function with each attachment.
+	if _, err := entry.Save(); err != nil {
+		return err
+	}
+	hashes[date][hash] = true
+	for _, zipFile := range item.Attachments {
+		if err := importAttachment(ctx, &entry, zipFile, profile.KeepPhotoMetadata); err != nil {
+			importJob.AddError(fmt.Sprintf("%s: %s", item.Name, zipFile.Name), err)
+		}
+	}
+	return nil
+}
+
+/*
+importAttachment function:
+
+1. Reads the file from the ZIP, which must not be larger than model.AttachmentMaxSize function bytes.
+
+2. Detects its type from its content as UploadAttachment function does, and executes helper.ReadImageAttachment function for images.
+
+3. Stores the file in the blob store, and executes (*model.Attachment).Save function. Thumbnails are generated by the thumbnail worker.
+*/
+func importAttachment(ctx context.Context, entry *model.Entry, zipFile *zip.File, keepMetadata bool) error {
+	// Reads the file from the ZIP.
+	maxSize := model.AttachmentMaxSize()
+	if zipFile.UncompressedSize64 > uint64(maxSize) {
+		return fmt.Errorf("the file must not be larger than %d bytes", maxSize)
+	}
+	reader, err := zipFile.Open()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize))
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	// Detects its type from its content.
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	contentType, err := model.DetectAttachmentContentType(head)
+	if err != nil {
+		return err
+	}
+	attachment := model.Attachment{
+		EntryID:         entry.ID,
+		UserID:          entry.UserID,
+		FileName:        model.NormalizeAttachmentFileName(zipFile.Name),
+		ContentType:     contentType,
+		Size:            int64(len(data)),
+		ThumbnailStatus: model.ThumbnailStatusNone,
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		if data, err = helper.ReadImageAttachment(bytes.NewReader(data), &attachment, keepMetadata); err != nil {
+			return err
+		}
+	}
+
+	// Stores the file in the blob store, and executes (*model.Attachment).Save function.
+	storageKey, err := model.NewAttachmentStorageKey(entry.UserID)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if err := storage.Blobs.Put(ctx, storageKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return err
+	}
+	attachment.SHA256 = hex.EncodeToString(sum[:])
+	attachment.StorageKey = storageKey
+	if _, err := attachment.Save(); err != nil {
+		_ = storage.Blobs.Delete(ctx, storageKey)
+		return err
+	}
+	if attachment.ThumbnailStatus == model.ThumbnailStatusPending {
+		WakeThumbnailWorker()
+	}
+	return nil
+}
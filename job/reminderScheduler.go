@@ -0,0 +1,179 @@
+package job
+
+import (
+	"context"
+	"diary_api/database"
+	"diary_api/model"
+	"diary_api/notifier"
+	"fmt"
+	"time"
+)
+
+// reminderTickInterval is how often the due reminders are looked up.
+const reminderTickInterval = time.Minute
+
+// reminderBatchSize is how many due reminders are sent per query.
+const reminderBatchSize = 100
+
+// reminderMaxDelay is how late a reminder can be sent, e.g. after the app was down. A reminder that is later than that is skipped.
+const reminderMaxDelay = time.Hour
+
+// reminderLockKey is the key of the advisory lock held by the instance of the app that sends the reminders.
+const reminderLockKey int64 = 0x7265_6d69_6e64 // "remind"
+
+// reminderLock elects the one instance of the app that sends the reminders.
+var reminderLock = &database.AdvisoryLock{Key: reminderLockKey}
+
+/*
+StartReminderScheduler function:
+
+1. Starts a goroutine that tries to acquire reminderLock on startup and every reminderTickInterval.
+
+2. Executes runDueReminders function while the lock is held, so that only one instance of the app sends the reminders.
+If the instance that holds it dies, Postgres releases the lock and another instance takes over at its next tick.
+*/
+func StartReminderScheduler() {
+	go func() {
+		ticker := time.NewTicker(reminderTickInterval)
+		defer ticker.Stop()
+		for {
+			acquired, err := reminderLock.TryAcquire(context.Background())
+			if err != nil {
+				fmt.Printf("Error acquiring the reminder lock: %v\n", err)
+			}
+			if acquired {
+				runDueReminders()
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+/*
+runDueReminders function:
+
+1. Executes model.FindDueReminders function until no reminders are due.
+
+2. Executes runReminder function for each reminder. If none of a batch could be claimed, e.g. because the database is down, the rest waits for the next tick.
+*/
+func runDueReminders() {
+	for {
+		now := time.Now()
+		reminders, err := model.FindDueReminders(now, reminderBatchSize)
+		if err != nil {
+			fmt.Printf("Error finding the due reminders: %v\n", err)
+			return
+		}
+		claimed := 0
+		for i := range reminders {
+			if runReminder(&reminders[i], now) {
+				claimed++
+			}
+		}
+		if len(reminders) < reminderBatchSize || claimed == 0 {
+			return
+		}
+	}
+}
+
+/*
+runReminder function:
+
+1. Executes (*model.Reminder).Claim function, which moves the reminder to its next run, so that it is never sent twice.
+
+2. Skips the reminder if it is more than reminderMaxDelay late, or if the user has already written an entry on its local date(model.HasEntryOnDate).
+
+3. Otherwise, sends it through the notifier of its channel.
+
+4. Executes (*model.Reminder).RecordRun function, and returns whether the reminder was claimed.
+*/
+func runReminder(reminder *model.Reminder, now time.Time) bool {
+	scheduledAt := *reminder.NextRunAt
+	location, err := model.FindUserLocation(reminder.UserID)
+	if err != nil {
+		fmt.Printf("Error finding the time zone of the user [reminderId: %d, userId: %d]: %v\n", reminder.ID, reminder.UserID, err)
+		return false
+	}
+
+	// Executes (*model.Reminder).Claim function.
+	claimed, err := reminder.Claim(now, location)
+	if err != nil {
+		fmt.Printf("Error claiming the reminder [reminderId: %d]: %v\n", reminder.ID, err)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	// Skips the reminder if it is too late, or if the user has already written that day.
+	if now.Sub(scheduledAt) > reminderMaxDelay {
+		fmt.Printf("Skipped the reminder, which is %s late [reminderId: %d]\n", now.Sub(scheduledAt).Round(time.Minute), reminder.ID)
+		recordReminderRun(reminder, now, true, nil)
+		return true
+	}
+	written, err := model.HasEntryOnDate(reminder.UserID, model.NewDate(scheduledAt.In(location)))
+	if err != nil {
+		recordReminderRun(reminder, now, false, err)
+		return true
+	}
+	if written {
+		recordReminderRun(reminder, now, true, nil)
+		return true
+	}
+
+	// Sends it through the notifier of its channel.
+	recordReminderRun(reminder, now, false, sendReminder(reminder, scheduledAt))
+	return true
+}
+
+/*
+sendReminder function:
+
+1. Executes model.FindUserById function, and builds the notification with a default text if the reminder has no message.
+
+2. Sends it through the notifier of the channel of the reminder.
+*/
+func sendReminder(reminder *model.Reminder, scheduledAt time.Time) error {
+	// Executes model.FindUserById function, and builds the notification.
+	user, err := model.FindUserById(reminder.UserID)
+	if err != nil {
+		return err
+	}
+	notification := notifier.Notification{
+		UserID:      user.ID,
+		Username:    user.Username,
+		WebhookURL:  reminder.WebhookURL,
+		Subject:     "Time to write in your diary",
+		Body:        reminder.Message,
+		ScheduledAt: scheduledAt,
+	}
+	if notification.Body == "" {
+		notification.Body = fmt.Sprintf("Hi %s, you have not written in your diary today. Take a few minutes to write about your day.", user.Username)
+	}
+	if reminder.Channel == notifier.ChannelEmail && user.Email != nil {
+		notification.Email = *user.Email
+	}
+
+	// Sends it through the notifier of the channel.
+	channelNotifier, ok := notifier.Notifiers[reminder.Channel]
+	if !ok {
+		return fmt.Errorf("the channel is not available on this server: %q", reminder.Channel)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reminderTickInterval)
+	defer cancel()
+	return channelNotifier.Notify(ctx, notification)
+}
+
+/*
+recordReminderRun function:
+
+1. Executes (*model.Reminder).RecordRun function, and prints the error of the run if any.
+*/
+func recordReminderRun(reminder *model.Reminder, now time.Time, skipped bool, runErr error) {
+	if runErr != nil {
+		fmt.Printf("Error sending the reminder [reminderId: %d]: %v\n", reminder.ID, runErr)
+	}
+	if err := reminder.RecordRun(now, skipped, runErr); err != nil {
+		fmt.Printf("Error recording the run of the reminder [reminderId: %d]: %v\n", reminder.ID, err)
+	}
+}
@@ -0,0 +1,137 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"diary_api/helper"
+	"diary_api/model"
+	"diary_api/storage"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"time"
+)
+
+// thumbnailPollInterval is how often the pending thumbnails are looked up when no upload wakes the worker.
+const thumbnailPollInterval = time.Minute
+
+// maxThumbnailSourcePixels bounds the memory used to decode an image(4 bytes per pixel). Larger images get no thumbnails.
+const maxThumbnailSourcePixels = 50_000_000
+
+// thumbnailWake wakes the worker when an image is uploaded. Its buffer of 1 collapses concurrent wake-ups.
+var thumbnailWake = make(chan struct{}, 1)
+
+/*
+StartThumbnailWorker function:
+
+1. Starts a goroutine that executes generatePendingThumbnails function on startup,
+when WakeThumbnailWorker function is executed, and every thumbnailPollInterval.
+
+Several instances of the app can run the worker, because each attachment is locked while it is processed.
+*/
+func StartThumbnailWorker() {
+	go func() {
+		for {
+			generatePendingThumbnails()
+			select {
+			case <-thumbnailWake:
+			case <-time.After(thumbnailPollInterval):
+			}
+		}
+	}()
+}
+
+/*
+WakeThumbnailWorker function:
+
+1. Wakes the worker without waiting for it, e.g. after an image is uploaded.
+*/
+func WakeThumbnailWorker() {
+	select {
+	case thumbnailWake <- struct{}{}:
+	default:
+	}
+}
+
+/*
+generatePendingThumbnails function:
+
+1. Executes model.ProcessPendingThumbnails function with generateThumbnails function until no thumbnails are pending.
+*/
+func generatePendingThumbnails() {
+	for {
+		found, err := model.ProcessPendingThumbnails(generateThumbnails)
+		if err != nil {
+			fmt.Printf("Error generating thumbnails: %v\n", err)
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+/*
+generateThumbnails function:
+
+1. Reads and decodes the original image, and sets its width and height as it is shown.
+
+2. Rotates and flips it by its EXIF orientation, because the thumbnails have no metadata.
+
+3. For each size in model.AttachmentVariantSizes that is smaller than the image, scales it down and stores it at (*model.Attachment).VariantKey.
+*/
+func generateThumbnails(attachment *model.Attachment) error {
+	ctx := context.Background()
+	// Reads and decodes the original image.
+	blob, err := storage.Blobs.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("attachment %d: %w", attachment.ID, err)
+	}
+	if config.Width*config.Height > maxThumbnailSourcePixels {
+		return fmt.Errorf("attachment %d: the image is too large for thumbnails (%dx%d)", attachment.ID, config.Width, config.Height)
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("attachment %d: %w", attachment.ID, err)
+	}
+	orientation := 1
+	if attachment.ContentType == "image/jpeg" {
+		orientation = helper.JPEGOrientation(data)
+	}
+	attachment.Width, attachment.Height = config.Width, config.Height
+	if orientation >= 5 {
+		// Orientations 5 to 8 turn the image by 90 degrees.
+		attachment.Width, attachment.Height = config.Height, config.Width
+	}
+
+	// Scales it down for each size that is smaller than the image.
+	attachment.Variants = []string{}
+	pixels := helper.ToRGBA(source)
+	for _, variant := range model.AttachmentVariantSizes {
+		if pixels.Bounds().Dx() <= variant.MaxSide && pixels.Bounds().Dy() <= variant.MaxSide {
+			break
+		}
+		thumbnail := helper.OrientImage(helper.ResizeImage(pixels, variant.MaxSide), orientation)
+		encoded, contentType, err := helper.EncodeThumbnail(thumbnail)
+		if err != nil {
+			return err
+		}
+		if err := storage.Blobs.Put(ctx, attachment.VariantKey(variant.Name), bytes.NewReader(encoded), int64(len(encoded)), contentType); err != nil {
+			return err
+		}
+		attachment.Variants = append(attachment.Variants, variant.Name)
+		attachment.ThumbnailContentType = contentType
+	}
+	return nil
+}
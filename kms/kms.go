@@ -0,0 +1,123 @@
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Key management drivers, set by KMS_DRIVER.
+const (
+	DriverLocal = "local"
+)
+
+// ErrMasterKeyNotFound is returned when a data key was wrapped with a master key that is not configured.
+var ErrMasterKeyNotFound = errors.New("master key not found")
+
+/*
+KeyManager interface:
+
+1. CurrentKeyID
+
+2. Wrap
+
+3. Unwrap
+
+A key manager keeps the master keys, which wrap(encrypt) the data keys of the users. The data keys encrypt the content, and are stored wrapped in the database.
+Rotating a master key only wraps the data keys again, so the content does not need to be encrypted again.
+*/
+type KeyManager interface {
+	// CurrentKeyID returns the id of the master key Wrap uses.
+	CurrentKeyID() string
+	// Wrap encrypts the data key with the current master key, and returns the id of the master key with the wrapped key.
+	Wrap(ctx context.Context, dataKey []byte) (masterKeyId string, wrapped []byte, err error)
+	// Unwrap decrypts the data key wrapped with the master key(masterKeyId).
+	// If the master key is not configured, ErrMasterKeyNotFound is returned.
+	Unwrap(ctx context.Context, masterKeyId string, wrapped []byte) ([]byte, error)
+}
+
+// Keys is nil when no master key is configured, and the content is then stored in plaintext.
+var Keys KeyManager
+
+/*
+Connect function:
+
+1. Retrieves the environment variables of the key management driver(KMS_DRIVER).
+
+2. Sets Keys to the key manager, or leaves it nil if KMS_MASTER_KEYS is not set.
+*/
+func Connect() error {
+	driver := os.Getenv("KMS_DRIVER")
+	switch driver {
+	case "", DriverLocal:
+		if os.Getenv("KMS_MASTER_KEYS") == "" {
+			fmt.Println("KMS_MASTER_KEYS is not set: the content of new entries is stored in plaintext.")
+			return nil
+		}
+		keys, err := NewLocalKeyManager(os.Getenv("KMS_MASTER_KEYS"))
+		if err != nil {
+			return err
+		}
+		Keys = keys
+		return nil
+	}
+	return fmt.Errorf("unknown KMS_DRIVER: %q (use %q)", driver, DriverLocal)
+}
+
+/*
+Seal function:
+
+1. Encrypts the plaintext with AES-256-GCM by the key, with a random nonce and the additional data(aad), which is authenticated but not encrypted.
+
+2. Returns the nonce followed by the ciphertext and its tag.
+*/
+func Seal(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+/*
+Open function:
+
+1. Splits the nonce from the sealed value(Seal), and decrypts it with AES-256-GCM by the key.
+
+2. If the value has been modified, or was sealed by another key or with other additional data(aad), an error is returned.
+*/
+func Open(key []byte, sealed []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("the sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+/*
+newGCM function:
+
+1. Returns AES-GCM of the key, which must be 32 bytes(AES-256).
+*/
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the key must be 32 bytes: %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,95 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	sealed, err := Seal(key, []byte("dear diary"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := Open(key, sealed, []byte("aad"))
+	if err != nil || string(opened) != "dear diary" {
+		t.Fatalf("Open() = %q, %v, want the plaintext", opened, err)
+	}
+	if again, _ := Seal(key, []byte("dear diary"), []byte("aad")); bytes.Equal(again, sealed) {
+		t.Error("Seal() reused its nonce")
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name   string
+		key    []byte
+		sealed []byte
+		aad    string
+	}{
+		{"modified", key, tampered, "aad"},
+		{"other additional data", key, sealed, "other"},
+		{"other key", bytes.Repeat([]byte{2}, 32), sealed, "aad"},
+		{"too short", key, sealed[:10], "aad"},
+		{"key too short", key[:16], sealed, "aad"},
+	}
+	for _, test := range tests {
+		if _, err := Open(test.key, test.sealed, []byte(test.aad)); err == nil {
+			t.Errorf("Open() of the %s value succeeded", test.name)
+		}
+	}
+}
+
+func TestLocalKeyManager(t *testing.T) {
+	current := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	old := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	oldManager, err := NewLocalKeyManager("2023-05:" + old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := bytes.Repeat([]byte{3}, 32)
+	oldId, wrapped, err := oldManager.Wrap(context.Background(), dataKey)
+	if err != nil || oldId != "2023-05" {
+		t.Fatalf("Wrap() = %q, %v", oldId, err)
+	}
+
+	// The first key is the current one, and the old ones still unwrap.
+	manager, err := NewLocalKeyManager("2023-11:" + current + ", 2023-05:" + old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.CurrentKeyID() != "2023-11" {
+		t.Errorf("CurrentKeyID() = %q, want 2023-11", manager.CurrentKeyID())
+	}
+	if unwrapped, err := manager.Unwrap(context.Background(), oldId, wrapped); err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("Unwrap() with the old key = %v, %v", unwrapped, err)
+	}
+	// The id of the master key is authenticated, so a wrapped key cannot be relabeled.
+	if _, err := manager.Unwrap(context.Background(), "2023-11", wrapped); err == nil {
+		t.Error("Unwrap() of a relabeled key succeeded")
+	}
+	if _, err := manager.Unwrap(context.Background(), "2022-01", wrapped); !errors.Is(err, ErrMasterKeyNotFound) {
+		t.Errorf("Unwrap() with an unknown key = %v, want ErrMasterKeyNotFound", err)
+	}
+}
+
+func TestNewLocalKeyManagerRejectsInvalidConfigs(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	for _, config := range []string{
+		"",
+		key,
+		"2023-11:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"2023-11:not base64!",
+		"2023-11:" + key + ",2023-11:" + key,
+		"bad id:" + key,
+	} {
+		if _, err := NewLocalKeyManager(config); err == nil {
+			t.Errorf("NewLocalKeyManager(%q) succeeded", strings.ReplaceAll(config, key, "<key>"))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// masterKeyIdPattern matches the id of a master key, e.g. "2023-05".
+var masterKeyIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+/*
+LocalKeyManager struct:
+
+1. CurrentID
+
+2. MasterKeys
+
+The master keys are read from the configuration, and never leave the process. It stands in for a KMS(e.g. AWS KMS or Vault),
+which would keep the master keys out of the process, behind the same KeyManager interface.
+*/
+type LocalKeyManager struct {
+	CurrentID  string
+	MasterKeys map[string][]byte
+}
+
+/*
+NewLocalKeyManager function:
+
+1. Parses the master keys from the configuration(KMS_MASTER_KEYS): comma separated pairs of an id and a base64-encoded 32-byte key,
+e.g. "2023-11:<key>,2023-05:<key>".
+
+2. The first key is the current key, which wraps new data keys. The others only unwrap the data keys that have not been rotated yet.
+*/
+func NewLocalKeyManager(config string) (*LocalKeyManager, error) {
+	manager := &LocalKeyManager{MasterKeys: map[string][]byte{}}
+	for _, pair := range strings.Split(config, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || !masterKeyIdPattern.MatchString(id) {
+			return nil, errors.New("KMS_MASTER_KEYS must be comma separated pairs of an id and a base64-encoded key, e.g. \"2023-11:<key>\"")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("the master key %q must be 32 bytes encoded in base64, e.g. the output of: openssl rand -base64 32", id)
+		}
+		if _, exists := manager.MasterKeys[id]; exists {
+			return nil, fmt.Errorf("the master key %q is configured twice", id)
+		}
+		manager.MasterKeys[id] = key
+		if manager.CurrentID == "" {
+			manager.CurrentID = id
+		}
+	}
+	return manager, nil
+}
+
+/*
+CurrentKeyID function:
+
+1. Returns the id of the first master key of the configuration.
+*/
+func (manager *LocalKeyManager) CurrentKeyID() string {
+	return manager.CurrentID
+}
+
+/*
+Wrap function:
+
+1. Executes Seal function with the current master key. The id of the master key is the additional data, so that a wrapped key cannot be relabeled.
+*/
+func (manager *LocalKeyManager) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := Seal(manager.MasterKeys[manager.CurrentID], dataKey, []byte(manager.CurrentID))
+	if err != nil {
+		return "", nil, err
+	}
+	return manager.CurrentID, wrapped, nil
+}
+
+/*
+Unwrap function:
+
+1. Executes Open function with the master key(masterKeyId). If it is not configured, ErrMasterKeyNotFound is returned.
+*/
+func (manager *LocalKeyManager) Unwrap(ctx context.Context, masterKeyId string, wrapped []byte) ([]byte, error) {
+	masterKey, ok := manager.MasterKeys[masterKeyId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrMasterKeyNotFound, masterKeyId)
+	}
+	return Open(masterKey, wrapped, []byte(masterKeyId))
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"diary_api/controller"
 	"diary_api/database"
+	"diary_api/job"
+	"diary_api/kms"
 	"diary_api/middleware"
 	"diary_api/model"
+	"diary_api/notifier"
+	"diary_api/storage"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -18,13 +24,30 @@ main function:
 
 1. Executes loadEnv function.
 
-2. Executes loadDatabase function.
+2. Executes loadKeys function.
 
-3. Executes serveApplication function.
+3. Executes loadDatabase function.
+
+4. Executes loadStorage function and loadNotifiers function.
+
+5. If a command is given, executes runCommand function and exits.
+
+6. Executes startJobs function.
+
+7. Executes serveApplication function.
 */
 func main() {
 	loadEnv()
+	loadKeys()
 	loadDatabase()
+	loadStorage()
+	loadNotifiers()
+	// Runs a maintenance command instead of the server, e.g. "go run main.go rotate-keys".
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1])
+		return
+	}
+	startJobs()
 	serveApplication()
 }
 
@@ -47,12 +70,54 @@ func loadEnv() {
 	}
 }
 
+/*
+loadKeys function:
+
+1. Loads the master keys that wrap the data keys of the content encrypted at rest, set by KMS_DRIVER and KMS_MASTER_KEYS.
+*/
+func loadKeys() {
+	if err := kms.Connect(); err != nil {
+		log.Fatalf("Error loading the master keys: %v", err)
+	}
+}
+
+/*
+runCommand function:
+
+1. "rotate-keys" wraps the data keys again with the current master key, the first of KMS_MASTER_KEYS.
+The content is not encrypted again, and the old master keys can be removed from KMS_MASTER_KEYS once it is done.
+
+2. "encrypt-entries" encrypts the content of the entries and the revisions written before a master key was configured.
+*/
+func runCommand(command string) {
+	switch command {
+	case "rotate-keys":
+		rotated, err := model.RotateDataKeys(context.Background())
+		if err != nil {
+			log.Fatalf("Error rotating the data keys after %d keys: %v", rotated, err)
+		}
+		fmt.Printf("Wrapped %d data keys with the master key %q.\n", rotated, kms.Keys.CurrentKeyID())
+	case "encrypt-entries":
+		for _, table := range []string{"entries", "entry_revisions"} {
+			encrypted, err := model.EncryptPlaintextContent(table)
+			if err != nil {
+				log.Fatalf("Error encrypting %s after %d rows: %v", table, encrypted, err)
+			}
+			fmt.Printf("Encrypted the content of %d rows of %s.\n", encrypted, table)
+		}
+	default:
+		log.Fatalf("Unknown command: %q (use \"rotate-keys\" or \"encrypt-entries\")", command)
+	}
+}
+
 /*
 loadDatabase function:
 
 1. Opens the connection using the GORM PostgreSQL driver.
 
-2. Runs auto migration for given models.
+2. Runs auto migration for given models, and backfills the entry dates, the first entry revisions and the thumbnails of attachments.
+
+3. Promotes the users listed in ADMIN_USERNAMES to admins.
 */
 func loadDatabase() {
 	// Opens the connection using the GORM PostgreSQL driver.
@@ -61,36 +126,225 @@ func loadDatabase() {
 	// Runs auto migration for given models.
 	// FYI: https://gorm.io/docs/migration.html
 	database.Database.AutoMigrate(&model.User{})
+	database.Database.AutoMigrate(&model.Tag{})
+	database.Database.AutoMigrate(&model.FieldDefinition{})
 	database.Database.AutoMigrate(&model.Entry{})
+	database.Database.AutoMigrate(&model.PersonalAccessToken{})
+	database.Database.AutoMigrate(&model.Session{})
+	database.Database.AutoMigrate(&model.UserIdentity{})
+	database.Database.AutoMigrate(&model.OIDCLoginState{})
+	database.Database.AutoMigrate(&model.Profile{})
+	database.Database.AutoMigrate(&model.EntryRevision{})
+	database.Database.AutoMigrate(&model.Attachment{})
+	database.Database.AutoMigrate(&model.EntryShare{})
+	database.Database.AutoMigrate(&model.Journal{})
+	database.Database.AutoMigrate(&model.JournalMember{})
+	database.Database.AutoMigrate(&model.Comment{})
+	database.Database.AutoMigrate(&model.Reaction{})
+	database.Database.AutoMigrate(&model.ImportJob{})
+	database.Database.AutoMigrate(&model.EncryptionKey{})
+	database.Database.AutoMigrate(&model.DataKey{})
+	database.Database.AutoMigrate(&model.Reminder{})
+
+	// Sets the entry date of the entries written before entry dates existed.
+	if err := model.BackfillEntryDates(); err != nil {
+		log.Fatalf("Error backfilling entry dates: %v", err)
+	}
+	// Creates the first revision of the entries written before revisions existed.
+	if err := model.BackfillEntryRevisions(); err != nil {
+		log.Fatalf("Error backfilling entry revisions: %v", err)
+	}
+	// Queues the thumbnails of the images uploaded before thumbnails existed.
+	if err := model.BackfillAttachmentThumbnails(); err != nil {
+		log.Fatalf("Error backfilling attachment thumbnails: %v", err)
+	}
+
+	// Promotes the users listed in ADMIN_USERNAMES to admins.
+	if adminUsernames := strings.Fields(strings.ReplaceAll(os.Getenv("ADMIN_USERNAMES"), ",", " ")); len(adminUsernames) > 0 {
+		if err := model.PromoteAdmins(adminUsernames); err != nil {
+			log.Fatalf("Error promoting admins: %v", err)
+		}
+	}
+}
+
+/*
+loadStorage function:
+
+1. Connects to the blob store of the attachments set by STORAGE_DRIVER, the local filesystem or an S3-compatible bucket.
+*/
+func loadStorage() {
+	if err := storage.Connect(); err != nil {
+		log.Fatalf("Error connecting to the storage: %v", err)
+	}
+}
+
+/*
+loadNotifiers function:
+
+1. Sets the notifiers of the channels the reminders are sent through. Email is only available if SMTP_HOST is set.
+*/
+func loadNotifiers() {
+	if err := notifier.Connect(); err != nil {
+		log.Fatalf("Error loading the notifiers: %v", err)
+	}
+}
+
+/*
+startJobs function:
+
+1. Starts the background jobs.
+*/
+func startJobs() {
+	// Hard deletes the accounts whose deletion grace period has passed.
+	job.StartAccountPurger()
+	// Generates the thumbnails of the uploaded images.
+	job.StartThumbnailWorker()
+	// Imports the uploaded files of other journaling apps.
+	job.StartImportWorker()
+	// Sends the writing reminders. Only the instance that holds the advisory lock sends them.
+	job.StartReminderScheduler()
 }
 
 /*
 serveApplication function:
 
-1. Returns an Engine instance with the Logger and Recovery middleware already attached.
+1. Returns an Engine instance with AbortStreamMiddleware, and the Logger and Recovery middleware after it.
 
-2. Creates a new router group(publicRoutes).
+2. Creates a new router group(publicRoutes), and a new router group(shareRoutes) for the public links to shared entries.
 
 3. Creates a new router group(protectedRoutes) with additional custom middleware(JWTAuthMiddleware).
+Routes that can be called with a personal access token require the matching scope(ScopeMiddleware).
+
+4. Creates a new router group(adminRoutes) that only admins and support staff can access(RoleMiddleware).
 
-4. Attaches the router to a http.Server and starts listening and serving HTTP requests.
+5. Attaches the router to a http.Server and starts listening and serving HTTP requests.
 */
 func serveApplication() {
-	// Returns an Engine instance with the Logger and Recovery middleware already attached.
-	router := gin.Default()
+	// Returns an Engine instance with AbortStreamMiddleware, and the Logger and Recovery middleware after it.
+	// AbortStreamMiddleware drops the connection of a streamed response that fails, e.g. an export.
+	router := gin.New()
+	router.Use(middleware.AbortStreamMiddleware(), gin.Logger(), gin.Recovery())
 
 	// Creates a new router group(publicRoutes).
 	publicRoutes := router.Group("/auth")
 	publicRoutes.POST("/register", controller.Register)
 	publicRoutes.POST("/login", controller.Login)
+	publicRoutes.POST("/password", controller.ResetPassword)
+	// Cancels the deletion of the account during its grace period.
+	publicRoutes.POST("/account/restore", controller.RestoreAccount)
+	// Logs in with an external identity provider(OpenID Connect).
+	publicRoutes.GET("/oidc/:provider/start", controller.StartOIDCLogin)
+	publicRoutes.GET("/oidc/:provider/callback", controller.OIDCCallback)
+
+	// Creates a new router group(shareRoutes) for the public links to shared entries. No authentication is needed.
+	shareRoutes := router.Group("/s")
+	shareRoutes.GET("/:token", controller.ViewSharedEntry)
+	shareRoutes.POST("/:token", controller.ViewSharedEntry)
 
 	// Creates a new router group(protectedRoutes) with additional custom middleware(JWTAuthMiddleware).
 	protectedRoutes := router.Group("/api")
 	// Adds middleware to the group.
 	protectedRoutes.Use(middleware.JWTAuthMiddleware())
-	protectedRoutes.POST("/entry", controller.AddEntry)
-	protectedRoutes.GET("/entry", controller.GetAllEntries)
-	protectedRoutes.GET("/entry/:id", controller.GetEntry)
+	protectedRoutes.POST("/entry", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.AddEntry)
+	protectedRoutes.GET("/entry", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetAllEntries)
+	protectedRoutes.GET("/entry/export", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.ExportEntries)
+	protectedRoutes.GET("/entry/:id", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetEntry)
+	protectedRoutes.PATCH("/entry/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UpdateEntry)
+	protectedRoutes.GET("/entry/:id/revisions", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetEntryRevisions)
+	protectedRoutes.GET("/entry/:id/revisions/:rev", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetEntryRevision)
+	protectedRoutes.POST("/entry/:id/revisions/:rev/restore", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.RestoreEntryRevision)
+	protectedRoutes.GET("/entry/:id/diff", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetEntryDiff)
+	protectedRoutes.POST("/entry/:id/attachments", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UploadAttachment)
+	protectedRoutes.GET("/entry/:id/attachments", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetAttachments)
+	protectedRoutes.GET("/entry/:id/attachments/:attachmentId", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.DownloadAttachment)
+	protectedRoutes.DELETE("/entry/:id/attachments/:attachmentId", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.DeleteAttachment)
+	protectedRoutes.POST("/entry/:id/share", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.CreateEntryShare)
+	protectedRoutes.GET("/entry/:id/shares", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetEntryShares)
+	protectedRoutes.DELETE("/entry/:id/shares/:shareId", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.RevokeEntryShare)
+	protectedRoutes.GET("/entry/:id/comments", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetComments)
+	protectedRoutes.POST("/entry/:id/comments", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.AddComment)
+	protectedRoutes.DELETE("/entry/:id/comments/:commentId", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.DeleteComment)
+	protectedRoutes.GET("/entry/:id/reactions", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetReactions)
+	protectedRoutes.POST("/entry/:id/reactions", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.AddReaction)
+	protectedRoutes.DELETE("/entry/:id/reactions/:emoji", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.RemoveReaction)
+	// Drafts are autosaved while they are written, and excluded from the listings until they are published.
+	protectedRoutes.GET("/drafts", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetDrafts)
+	protectedRoutes.POST("/drafts", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.CreateDraft)
+	protectedRoutes.PUT("/drafts/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.AutosaveDraft)
+	protectedRoutes.POST("/drafts/:id/publish", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.PublishDraft)
+	protectedRoutes.GET("/calendar", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetCalendar)
+	protectedRoutes.GET("/tags", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetTags)
+	protectedRoutes.PATCH("/tags/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UpdateTag)
+	protectedRoutes.DELETE("/tags/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.DeleteTag)
+	// Journals are shared by their members. Their entries are written with journal_id, and listed with GET /entry?journal_id=.
+	protectedRoutes.POST("/journals", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.CreateJournal)
+	protectedRoutes.GET("/journals", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetJournals)
+	protectedRoutes.GET("/journals/invitations", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetJournalInvitations)
+	protectedRoutes.GET("/journals/:id", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetJournal)
+	protectedRoutes.PATCH("/journals/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UpdateJournal)
+	protectedRoutes.DELETE("/journals/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.DeleteJournal)
+	protectedRoutes.POST("/journals/:id/accept", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.AcceptJournalInvitation)
+	protectedRoutes.POST("/journals/:id/members", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.InviteJournalMember)
+	protectedRoutes.PATCH("/journals/:id/members/:userId", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UpdateJournalMember)
+	protectedRoutes.DELETE("/journals/:id/members/:userId", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.RemoveJournalMember)
+	protectedRoutes.GET("/fields", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetFieldDefinitions)
+	protectedRoutes.POST("/fields", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.CreateFieldDefinition)
+	protectedRoutes.PATCH("/fields/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UpdateFieldDefinition)
+	protectedRoutes.DELETE("/fields/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.DeleteFieldDefinition)
+	// Imports run in the background. Their progress is polled with GET /import/:id.
+	protectedRoutes.POST("/import", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.ImportEntries)
+	protectedRoutes.GET("/import", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetImportJobs)
+	protectedRoutes.GET("/import/:id", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetImportJob)
+	// The wrapped key of end-to-end encrypted entries can be read by their clients, but only changed with a JWT issued by /auth/login.
+	protectedRoutes.GET("/encryption/key", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetEncryptionKey)
+	protectedRoutes.PUT("/encryption/key", middleware.JWTOnlyMiddleware(), controller.PutEncryptionKey)
+	protectedRoutes.DELETE("/encryption/key", middleware.JWTOnlyMiddleware(), controller.DeleteEncryptionKey)
+	// Reminders fire at a local time in the time zone of the profile, unless the user has already written that day.
+	protectedRoutes.GET("/reminders", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetReminders)
+	protectedRoutes.POST("/reminders", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.CreateReminder)
+	protectedRoutes.PATCH("/reminders/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.UpdateReminder)
+	protectedRoutes.DELETE("/reminders/:id", middleware.ScopeMiddleware(model.ScopeEntriesWrite), controller.DeleteReminder)
+
+	// Links an identity of an external identity provider(OpenID Connect) to the user. Only with a JWT issued by /auth/login.
+	protectedRoutes.POST("/identities/:provider", middleware.JWTOnlyMiddleware(), controller.LinkOIDCIdentity)
+
+	// Personal access tokens can only be managed with a JWT issued by /auth/login.
+	tokenRoutes := protectedRoutes.Group("/tokens")
+	tokenRoutes.Use(middleware.JWTOnlyMiddleware())
+	tokenRoutes.POST("", controller.CreatePersonalAccessToken)
+	tokenRoutes.GET("", controller.GetPersonalAccessTokens)
+	tokenRoutes.DELETE("/:id", controller.DeletePersonalAccessToken)
+
+	// Sessions can only be managed with a JWT issued by /auth/login.
+	sessionRoutes := protectedRoutes.Group("/sessions")
+	sessionRoutes.Use(middleware.JWTOnlyMiddleware())
+	sessionRoutes.GET("", controller.GetSessions)
+	// Logs out everywhere.
+	sessionRoutes.DELETE("", controller.DeleteAllSessions)
+	sessionRoutes.DELETE("/:id", controller.DeleteSession)
+	// Logs out of the session of the request, and clears the session cookie.
+	protectedRoutes.POST("/logout", middleware.JWTOnlyMiddleware(), controller.Logout)
+
+	protectedRoutes.GET("/me", middleware.ScopeMiddleware(model.ScopeEntriesRead), controller.GetMe)
+	protectedRoutes.PATCH("/me", middleware.JWTOnlyMiddleware(), controller.UpdateMe)
+
+	// The account can only be exported and deleted with a JWT issued by /auth/login.
+	accountRoutes := protectedRoutes.Group("/account")
+	accountRoutes.Use(middleware.JWTOnlyMiddleware())
+	accountRoutes.GET("/export", controller.ExportAccount)
+	accountRoutes.DELETE("", controller.DeleteAccount)
+
+	// Creates a new router group(adminRoutes) that only admins and support staff can access.
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(middleware.JWTAuthMiddleware(), middleware.JWTOnlyMiddleware())
+	adminRoutes.Use(middleware.RoleMiddleware(model.RoleAdmin, model.RoleSupport))
+	adminRoutes.GET("/users", controller.AdminGetUsers)
+	adminRoutes.GET("/users/:id", controller.AdminGetUser)
+	adminRoutes.POST("/users/:id/password-reset", controller.AdminRequirePasswordReset)
+	// Only admins can disable users and change roles.
+	adminRoutes.POST("/users/:id/disable", middleware.RoleMiddleware(model.RoleAdmin), controller.AdminDisableUser)
+	adminRoutes.POST("/users/:id/enable", middleware.RoleMiddleware(model.RoleAdmin), controller.AdminEnableUser)
+	adminRoutes.PUT("/users/:id/role", middleware.RoleMiddleware(model.RoleAdmin), controller.AdminUpdateUserRole)
 
 	// Attaches the router to a http.Server and starts listening and serving HTTP requests.
 	router.Run(":8000")
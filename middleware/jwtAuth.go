@@ -11,18 +11,22 @@ import (
 /*
 JWTAuthMiddleware function:
 
-1. Executes helper.ValidateJWT function.
+1. Executes helper.Authenticate function.
 
-2. If helper.ValidateJWT function is successfully executed, the pending handlers is executed inside the calling handler.
+2. If the request was authenticated by the session cookie and its method is unsafe, executes helper.ValidateCSRFToken function.
+
+3. If the request is authenticated, the pending handlers is executed inside the calling handler.
+
+A JWT issued by /auth/login in the Authorization header or in the session cookie, and a personal access token are accepted.
 
 FYI: https://gin-gonic.com/docs/examples/custom-middleware/
 */
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(context *gin.Context) {
-		// Executes helper.ValidateJWT function.
-		err := helper.ValidateJWT(context)
+		// Executes helper.Authenticate function.
+		err := helper.Authenticate(context)
 		if err != nil {
-			// If helper.ValidateJWT fails to execute, "Authentication required" is returned.
+			// If helper.Authenticate fails to execute, "Authentication required" is returned.
 			context.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 			fmt.Printf("##### ERROR #####")
 			fmt.Println(err)
@@ -30,8 +34,28 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			context.Abort()
 			return
 		}
-		// If helper.ValidateJWT function is successfully executed,
+		// If the request was authenticated by the session cookie and its method is unsafe, executes helper.ValidateCSRFToken function.
+		// A cookie is sent by the browser automatically, so the CSRF token proves that the request comes from our own page.
+		if helper.AuthenticatedByCookie(context) && !isSafeMethod(context.Request.Method) && !helper.ValidateCSRFToken(context) {
+			// If the CSRF token is missing or does not match, StatusForbidden(403) is returned.
+			context.JSON(http.StatusForbidden, gin.H{"error": "CSRF token is missing or invalid"})
+			// Prevents pending handlers from being called.
+			context.Abort()
+			return
+		}
+		// If the request is authenticated,
 		// the pending handlers is executed inside the calling handler.
 		context.Next()
 	}
 }
+
+/*
+isSafeMethod function:
+
+1. Returns true for the methods that do not change the state of the server.
+
+FYI: https://www.rfc-editor.org/rfc/rfc9110#section-9.2.1
+*/
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"diary_api/helper"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+RoleMiddleware function:
+
+1. Executes helper.CurrentRole function.
+
+2. If the role carried in the JWT is one of the roles, the pending handlers is executed inside the calling handler.
+
+It must be used after JWTAuthMiddleware.
+*/
+func RoleMiddleware(roles ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Executes helper.CurrentRole function.
+		currentRole := helper.CurrentRole(context)
+		for _, role := range roles {
+			if currentRole == role {
+				// If the role carried in the JWT is one of the roles,
+				// the pending handlers is executed inside the calling handler.
+				context.Next()
+				return
+			}
+		}
+		// Otherwise, StatusForbidden(403) is returned.
+		context.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to access this resource"})
+		// Prevents pending handlers from being called.
+		context.Abort()
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"diary_api/helper"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+ScopeMiddleware function:
+
+1. Executes helper.HasScope function.
+
+2. If the request is allowed the scope, the pending handlers is executed inside the calling handler.
+
+It must be used after JWTAuthMiddleware.
+*/
+func ScopeMiddleware(scope string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Executes helper.HasScope function.
+		if !helper.HasScope(context, scope) {
+			// If the token has not been granted the scope, StatusForbidden(403) is returned.
+			errorMessage := fmt.Sprintf("The token is missing the required scope. [scope: %s]", scope)
+			context.JSON(http.StatusForbidden, gin.H{"error": errorMessage})
+			// Prevents pending handlers from being called.
+			context.Abort()
+			return
+		}
+		// If the request is allowed the scope,
+		// the pending handlers is executed inside the calling handler.
+		context.Next()
+	}
+}
+
+/*
+JWTOnlyMiddleware function:
+
+1. Executes helper.CurrentAuthMethod function.
+
+2. If the request was authenticated by a JWT, the pending handlers is executed inside the calling handler.
+
+It keeps personal access tokens away from routes that manage the account itself, e.g. creating more tokens.
+It must be used after JWTAuthMiddleware.
+*/
+func JWTOnlyMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Executes helper.CurrentAuthMethod function.
+		if helper.CurrentAuthMethod(context) != helper.AuthMethodJWT {
+			// If the request was not authenticated by a JWT, StatusForbidden(403) is returned.
+			context.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with a personal access token"})
+			// Prevents pending handlers from being called.
+			context.Abort()
+			return
+		}
+		// If the request was authenticated by a JWT,
+		// the pending handlers is executed inside the calling handler.
+		context.Next()
+	}
+}
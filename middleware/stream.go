@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"diary_api/helper"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+AbortStreamMiddleware function:
+
+1. Executes the pending handlers.
+
+2. If the response has been marked by helper.AbortStream function, panics with http.ErrAbortHandler,
+which makes net/http drop the connection(or reset the HTTP/2 stream) without logging a stack trace.
+
+It must be used before the Logger and Recovery middleware, because Recovery would catch the panic and complete the response.
+*/
+func AbortStreamMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Executes the pending handlers.
+		context.Next()
+		if helper.StreamAborted(context) {
+			// Drops the connection, so that the response is not completed.
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"diary_api/database"
+	"diary_api/storage"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultAttachmentMaxSize is the maximum size of an attachment in bytes if ATTACHMENT_MAX_SIZE is not set.
+const defaultAttachmentMaxSize = 10 << 20
+
+// maxAttachmentFileNameLength is the maximum length of the file name of an attachment in characters.
+const maxAttachmentFileNameLength = 255
+
+// Sizes of an attachment that can be downloaded. Thumbnails fit in a square of AttachmentVariantSizes pixels.
+const (
+	AttachmentSizeOriginal = "original"
+	AttachmentSizeThumb    = "thumb"
+	AttachmentSizeMedium   = "medium"
+)
+
+// AttachmentVariantSizes are the thumbnails generated for images, smallest first.
+var AttachmentVariantSizes = []struct {
+	Name    string
+	MaxSide int
+}{
+	{AttachmentSizeThumb, 256},
+	{AttachmentSizeMedium, 1024},
+}
+
+// Statuses of the thumbnails of an attachment.
+const (
+	// ThumbnailStatusNone is the status of the files that have no thumbnails, e.g. PDFs.
+	ThumbnailStatusNone    = "none"
+	ThumbnailStatusPending = "pending"
+	ThumbnailStatusReady   = "ready"
+	ThumbnailStatusFailed  = "failed"
+)
+
+// thumbnailContentTypes are the types of the images that thumbnails can be generated for.
+var thumbnailContentTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true}
+
+// attachmentContentTypes are the types of the files that can be attached, as detected from their content.
+var attachmentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+/*
+Attachment struct:
+
+1. EntryID
+
+2. UserID
+
+3. FileName
+
+4. ContentType
+
+5. Size
+
+6. SHA256
+
+7. StorageKey
+
+8. Width, Height
+
+9. ThumbnailStatus
+
+10. Variants
+
+The content of the file is stored in the blob store(storage.Blobs) at StorageKey, and each thumbnail at VariantKey.
+Attachments are deleted permanently, with their blobs.
+*/
+type Attachment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	EntryID     uint      `gorm:"not null;index" json:"entry_id"`
+	UserID      uint      `gorm:"not null;index" json:"-"`
+	FileName    string    `gorm:"size:255;not null" json:"file_name"`
+	ContentType string    `gorm:"size:127;not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	// SHA256 is the hex-encoded SHA-256 of the content, so that clients can verify their downloads.
+	SHA256     string `gorm:"size:64;not null" json:"sha256"`
+	StorageKey string `gorm:"size:255;not null;uniqueIndex" json:"-"`
+	// Width and Height are the size of an image as it is shown, after its EXIF orientation.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// ThumbnailStatus is ThumbnailStatusPending until the thumbnail worker has generated the thumbnails of an image.
+	ThumbnailStatus string `gorm:"size:16;not null;default:none;index" json:"thumbnail_status"`
+	// Variants are the sizes that have a thumbnail. Images smaller than a size have no thumbnail of it.
+	Variants             []string `gorm:"serializer:json" json:"variants"`
+	ThumbnailContentType string   `gorm:"size:127" json:"-"`
+}
+
+/*
+AttachmentMaxSize function:
+
+1. Returns the maximum size of an attachment in bytes, set by ATTACHMENT_MAX_SIZE.
+*/
+func AttachmentMaxSize() int64 {
+	maxSize, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64)
+	if err != nil || maxSize <= 0 {
+		return defaultAttachmentMaxSize
+	}
+	return maxSize
+}
+
+/*
+DetectAttachmentContentType function:
+
+1. Detects the type of the file from its first bytes(up to 512), ignoring the type the client claims.
+
+2. If the type cannot be attached, an error is returned.
+
+FYI: https://mimesniff.spec.whatwg.org/
+*/
+func DetectAttachmentContentType(head []byte) (string, error) {
+	detected := http.DetectContentType(head)
+	mediaType, _, err := mime.ParseMediaType(detected)
+	if err != nil || !attachmentContentTypes[mediaType] {
+		return "", fmt.Errorf("unsupported file type: %s (use JPEG, PNG, GIF, WebP, PDF or plain text)", detected)
+	}
+	return mediaType, nil
+}
+
+/*
+CanHaveThumbnails function:
+
+1. Returns whether thumbnails can be generated for the type of the file.
+WebP images are stored and served, but the standard library cannot decode them.
+*/
+func CanHaveThumbnails(contentType string) bool {
+	return thumbnailContentTypes[contentType]
+}
+
+/*
+NormalizeAttachmentFileName function:
+
+1. Keeps the base name of the file name the client sent, without the directories and the control characters.
+
+2. Truncates it to maxAttachmentFileNameLength characters. An empty name becomes "attachment".
+*/
+func NormalizeAttachmentFileName(fileName string) string {
+	base := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	base = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, base)
+	base = strings.TrimSpace(base)
+	if base == "" || base == "." || base == "/" {
+		return "attachment"
+	}
+	if runes := []rune(base); len(runes) > maxAttachmentFileNameLength {
+		base = string(runes[:maxAttachmentFileNameLength])
+	}
+	return base
+}
+
+/*
+NewAttachmentStorageKey function:
+
+1. Returns a random key of the blob of an attachment of the user, e.g. "attachments/1/5f2b...".
+*/
+func NewAttachmentStorageKey(userId uint) (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("attachments/%d/%s", userId, hex.EncodeToString(randomBytes)), nil
+}
+
+/*
+VariantKey function:
+
+1. Returns the key of the blob of the thumbnail of the size, next to the original.
+*/
+func (attachment *Attachment) VariantKey(size string) string {
+	return attachment.StorageKey + "." + size
+}
+
+/*
+HasVariant function:
+
+1. Returns whether the attachment has a thumbnail of the size.
+*/
+func (attachment *Attachment) HasVariant(size string) bool {
+	for _, variant := range attachment.Variants {
+		if variant == size {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+blobKeys function:
+
+1. Returns the keys of every blob the attachment may have: the original and every thumbnail.
+The thumbnails that may still be generated are included, because deleting a missing blob does nothing.
+*/
+func (attachment *Attachment) blobKeys() []string {
+	keys := []string{attachment.StorageKey}
+	if attachment.ThumbnailStatus != ThumbnailStatusNone {
+		for _, variant := range AttachmentVariantSizes {
+			keys = append(keys, attachment.VariantKey(variant.Name))
+		}
+	}
+	return keys
+}
+
+/*
+Save function:
+
+1. Inserts the attachment.
+*/
+func (attachment *Attachment) Save() (*Attachment, error) {
+	// INSERT INTO "attachments" (...) VALUES (...) RETURNING "id"
+	if err := database.Database.Create(attachment).Error; err != nil {
+		return &Attachment{}, err
+	}
+	return attachment, nil
+}
+
+/*
+FindAttachmentsByEntryId function:
+
+1. Queries the database to find the attachments of the entry, the oldest first.
+*/
+func FindAttachmentsByEntryId(entryId uint) ([]Attachment, error) {
+	attachments := []Attachment{}
+	// SELECT * FROM "attachments" WHERE entry_id = $1$ ORDER BY id
+	err := database.Database.Where("entry_id = ?", entryId).Order("id").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+/*
+FindAttachment function:
+
+1. Queries the database to find the attachment of the entry.
+
+2. If the attachment does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindAttachment(entryId uint, id uint) (Attachment, error) {
+	var attachment Attachment
+	// SELECT * FROM "attachments" WHERE id = $1$ AND entry_id = $2$ ORDER BY "attachments"."id" LIMIT 1
+	err := database.Database.Where("id = ? AND entry_id = ?", id, entryId).First(&attachment).Error
+	if err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+/*
+Delete function:
+
+1. Permanently deletes the attachment.
+
+2. Deletes its blobs. If that fails, the blobs are only left behind, because nothing refers to them anymore.
+
+While the thumbnail worker is generating the thumbnails, the row is locked, so the attachment is deleted after the thumbnails are stored.
+*/
+func (attachment *Attachment) Delete(ctx context.Context) error {
+	// DELETE FROM "attachments" WHERE "attachments"."id" = $1$
+	result := database.Database.Delete(&Attachment{}, attachment.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	deleteBlobs(ctx, attachment.blobKeys())
+	return nil
+}
+
+/*
+ProcessPendingThumbnails function:
+
+1. Locks the oldest attachment whose thumbnails are pending, skipping the ones other workers have locked.
+
+2. Executes the process, which generates the thumbnails and sets the variants.
+
+3. Sets the thumbnail status to ready, or to failed if the process fails, so that a broken image is not retried forever.
+
+4. Returns whether an attachment was found, and the error of the process.
+
+FYI: https://www.postgresql.org/docs/current/sql-select.html#SQL-FOR-UPDATE-SHARE
+*/
+func ProcessPendingThumbnails(process func(attachment *Attachment) error) (found bool, err error) {
+	var processErr error
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		var attachment Attachment
+		// SELECT * FROM "attachments" WHERE thumbnail_status = $1$ ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("thumbnail_status = ?", ThumbnailStatusPending).Order("id").Limit(1).Find(&attachment)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		found = true
+
+		// Executes the process.
+		attachment.ThumbnailStatus = ThumbnailStatusReady
+		if processErr = process(&attachment); processErr != nil {
+			attachment.ThumbnailStatus = ThumbnailStatusFailed
+		}
+		// UPDATE "attachments" SET "width"=$1$,"height"=$2$,"thumbnail_status"=$3$,"variants"=$4$,"thumbnail_content_type"=$5$ WHERE "id" = $6$
+		return tx.Model(&attachment).Select("width", "height", "thumbnail_status", "variants", "thumbnail_content_type").Updates(&attachment).Error
+	})
+	if err != nil {
+		return found, err
+	}
+	return found, processErr
+}
+
+/*
+deleteBlobs function:
+
+1. Deletes the blobs of the keys, logging the failures.
+*/
+func deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := storage.Blobs.Delete(ctx, key); err != nil {
+			fmt.Printf("Error deleting the blob [key: %s]: %v\n", key, err)
+		}
+	}
+}
+
+/*
+BackfillAttachmentThumbnails function:
+
+1. Queues the thumbnails of the images uploaded before thumbnails existed.
+*/
+func BackfillAttachmentThumbnails() error {
+	contentTypes := []string{}
+	for contentType := range thumbnailContentTypes {
+		contentTypes = append(contentTypes, contentType)
+	}
+	// UPDATE "attachments" SET "thumbnail_status"=$1$ WHERE thumbnail_status = $2$ AND content_type IN (...)
+	return database.Database.Model(&Attachment{}).
+		Where("thumbnail_status = ? AND content_type IN ?", ThumbnailStatusNone, contentTypes).
+		Update("thumbnail_status", ThumbnailStatusPending).Error
+}
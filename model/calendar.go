@@ -0,0 +1,72 @@
+package model
+
+import (
+	"diary_api/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+CalendarDay struct:
+
+1. Date
+
+2. Count
+
+3. EntryIDs
+*/
+type CalendarDay struct {
+	Date     Date   `json:"date"`
+	Count    int    `json:"count"`
+	EntryIDs []uint `json:"entry_ids"`
+}
+
+/*
+FindCalendarMonth function:
+
+1. Counts the entries of the user for each local date of the month with a single aggregate query.
+
+2. Returns every day of the month, including the days without entries.
+
+The entries themselves are never loaded, so that a month view does not download every entry.
+*/
+func FindCalendarMonth(userId uint, year int, month time.Month) ([]CalendarDay, error) {
+	first := NewDate(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))
+	last := NewDate(first.AddDate(0, 1, -1))
+
+	var rows []struct {
+		EntryDate Date
+		Count     int
+		EntryIds  string
+	}
+	// SELECT entry_date, count(*) AS count, string_agg(id::text, ',' ORDER BY id) AS entry_ids FROM "entries"
+	// WHERE entries.user_id = $1$ AND entries.entry_date >= $2$ AND entries.entry_date <= $3$ AND "entries"."deleted_at" IS NULL
+	// GROUP BY "entry_date"
+	filter := EntryFilter{UserID: userId, From: &first, To: &last}
+	err := filter.apply(database.Database.Model(&Entry{})).
+		Select("entry_date, count(*) AS count, string_agg(id::text, ',' ORDER BY id) AS entry_ids").
+		Group("entry_date").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Returns every day of the month, including the days without entries.
+	days := []CalendarDay{}
+	for date := first; !date.After(last.Time); date = date.AddDays(1) {
+		days = append(days, CalendarDay{Date: date, EntryIDs: []uint{}})
+	}
+	for _, row := range rows {
+		day := &days[row.EntryDate.Day()-1]
+		day.Count = row.Count
+		for _, value := range strings.Split(row.EntryIds, ",") {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			day.EntryIDs = append(day.EntryIDs, uint(id))
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,220 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrCommentParent is returned when a reply is posted to a comment that does not exist, is deleted or is on another entry.
+var ErrCommentParent = errors.New("the parent comment does not exist on this entry")
+
+/*
+Comment struct:
+
+1. EntryID
+
+2. UserID
+
+3. ParentID
+
+4. Content
+
+5. Username
+
+6. Deleted
+
+7. Replies
+
+Comments are soft-deleted(gorm.Model). A deleted comment that has replies is still listed, without its content, so that the thread is kept.
+*/
+type Comment struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	EntryID uint `gorm:"not null;index" json:"entry_id"`
+	UserID  uint `gorm:"not null;index" json:"user_id"`
+	// ParentID is the comment this comment replies to. Top-level comments have none.
+	ParentID *uint  `gorm:"index" json:"parent_id"`
+	Content  string `gorm:"type:text;not null" json:"content"`
+	// Username is the username of the author of the comment. It is only read, and is not a column.
+	Username string `gorm:"->;-:migration" json:"username"`
+	// Deleted and Replies are set when the comments are listed as threads.
+	Deleted bool      `gorm:"-" json:"deleted"`
+	Replies []Comment `gorm:"-" json:"replies"`
+}
+
+/*
+CommentInput struct:
+
+1. Content
+
+2. ParentID
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type CommentInput struct {
+	Content  string `json:"content" binding:"required,max=5000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+/*
+Save function:
+
+1. If the comment is a reply, checks that its parent is a comment on the same entry that is not deleted.
+
+2. Inserts the comment.
+*/
+func (comment *Comment) Save() (*Comment, error) {
+	if comment.ParentID != nil {
+		var count int64
+		// SELECT count(*) FROM "comments" WHERE id = $1$ AND entry_id = $2$ AND "comments"."deleted_at" IS NULL
+		if err := database.Database.Model(&Comment{}).Where("id = ? AND entry_id = ?", *comment.ParentID, comment.EntryID).Count(&count).Error; err != nil {
+			return &Comment{}, err
+		}
+		if count == 0 {
+			return &Comment{}, ErrCommentParent
+		}
+	}
+	// INSERT INTO "comments" (...) VALUES (...) RETURNING "id"
+	if err := database.Database.Create(comment).Error; err != nil {
+		return &Comment{}, err
+	}
+	comment.Replies = []Comment{}
+	return comment, nil
+}
+
+/*
+FindComment function:
+
+1. Queries the database to find the comment on the entry.
+
+2. If the comment does not exist or is deleted, gorm.ErrRecordNotFound is returned.
+*/
+func FindComment(entryId uint, id uint) (Comment, error) {
+	var comment Comment
+	// SELECT * FROM "comments" WHERE id = $1$ AND entry_id = $2$ AND "comments"."deleted_at" IS NULL ORDER BY "comments"."id" LIMIT 1
+	err := database.Database.Where("id = ? AND entry_id = ?", id, entryId).First(&comment).Error
+	if err != nil {
+		return Comment{}, err
+	}
+	return comment, nil
+}
+
+/*
+Delete function:
+
+1. Soft-deletes the comment. Its replies are kept.
+*/
+func (comment *Comment) Delete() error {
+	// UPDATE "comments" SET "deleted_at"=$1$ WHERE "comments"."id" = $2$ AND "comments"."deleted_at" IS NULL
+	return database.Database.Delete(comment).Error
+}
+
+/*
+FindCommentThreads function:
+
+1. Queries the database to find every comment on the entry, including the deleted ones, the oldest first.
+
+2. Nests the replies in their parents. Replies whose parent no longer exists are listed at the top level.
+
+3. Clears the content of the deleted comments, and leaves out the deleted comments that have no replies left.
+*/
+func FindCommentThreads(entryId uint) ([]Comment, error) {
+	var comments []Comment
+	// SELECT comments.*, users.username FROM "comments" LEFT JOIN users ON ... WHERE comments.entry_id = $1$ ORDER BY comments.id
+	err := database.Database.Unscoped().Select("comments.*, users.username").
+		Joins("LEFT JOIN users ON users.id = comments.user_id").
+		Where("comments.entry_id = ?", entryId).Order("comments.id").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Nests the replies in their parents.
+	children := map[uint][]int{}
+	roots := []int{}
+	exists := map[uint]bool{}
+	for _, comment := range comments {
+		exists[comment.ID] = true
+	}
+	for i, comment := range comments {
+		if comment.ParentID != nil && exists[*comment.ParentID] {
+			children[*comment.ParentID] = append(children[*comment.ParentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+	var build func(indexes []int) []Comment
+	build = func(indexes []int) []Comment {
+		threads := []Comment{}
+		for _, i := range indexes {
+			comment := comments[i]
+			comment.Replies = build(children[comment.ID])
+			if comment.DeletedAt.Valid {
+				// Leaves out the deleted comments that have no replies left, and clears the content of the others.
+				if len(comment.Replies) == 0 {
+					continue
+				}
+				comment.Deleted = true
+				comment.Content = ""
+			}
+			threads = append(threads, comment)
+		}
+		return threads
+	}
+	return build(roots), nil
+}
+
+/*
+fillEntryResponseCounts function:
+
+1. Counts the comments that are not deleted and the reactions of each entry, in one query each.
+
+2. Sets CommentCount and ReactionCounts of the entries.
+*/
+func fillEntryResponseCounts(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(entries))
+	for i := range entries {
+		ids[i] = entries[i].ID
+		entries[i].ReactionCounts = map[string]int{}
+	}
+	var commentCounts []struct {
+		EntryID uint
+		Count   int
+	}
+	// SELECT entry_id, count(*) AS count FROM "comments" WHERE entry_id IN (...) AND "comments"."deleted_at" IS NULL GROUP BY entry_id
+	if err := database.Database.Model(&Comment{}).Select("entry_id, count(*) AS count").
+		Where("entry_id IN ?", ids).Group("entry_id").Scan(&commentCounts).Error; err != nil {
+		return err
+	}
+	var reactionCounts []struct {
+		EntryID uint
+		Emoji   string
+		Count   int
+	}
+	// SELECT entry_id, emoji, count(*) AS count FROM "reactions" WHERE entry_id IN (...) GROUP BY entry_id, emoji
+	if err := database.Database.Model(&Reaction{}).Select("entry_id, emoji, count(*) AS count").
+		Where("entry_id IN ?", ids).Group("entry_id, emoji").Scan(&reactionCounts).Error; err != nil {
+		return err
+	}
+
+	byId := map[uint]*Entry{}
+	for i := range entries {
+		byId[entries[i].ID] = &entries[i]
+	}
+	for _, count := range commentCounts {
+		byId[count.EntryID].CommentCount = count.Count
+	}
+	for _, count := range reactionCounts {
+		byId[count.EntryID].ReactionCounts[count.Emoji] = count.Count
+	}
+	return nil
+}
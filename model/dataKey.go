@@ -0,0 +1,363 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"diary_api/database"
+	"diary_api/kms"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxCachedDataKeys is the number of unwrapped data keys kept in memory, so that the master key is not used for every entry.
+const maxCachedDataKeys = 10000
+
+// dataKeyBatchSize is the number of rows rotated or encrypted at once by RotateDataKeys and EncryptPlaintextContent.
+const dataKeyBatchSize = 500
+
+// ErrMasterKeyMissing is returned when encrypted content is read while no master key is configured.
+var ErrMasterKeyMissing = errors.New("the content is encrypted at rest, but KMS_MASTER_KEYS is not set")
+
+/*
+DataKey struct:
+
+1. UserID
+
+2. MasterKeyID
+
+3. WrappedKey
+
+The key that encrypts the content of the entries and the revisions of the user at rest(AES-256-GCM), wrapped by a master key(kms.Keys).
+*/
+type DataKey struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uint   `gorm:"not null;uniqueIndex"`
+	MasterKeyID string `gorm:"size:32;not null;index"`
+	WrappedKey  []byte `gorm:"not null"`
+}
+
+// dataKeys caches the unwrapped data keys by their id, and the id of the data key of each user.
+var dataKeys = struct {
+	sync.Mutex
+	byId   map[uint][]byte
+	byUser map[uint]uint
+}{byId: map[uint][]byte{}, byUser: map[uint]uint{}}
+
+/*
+EncryptContent function:
+
+1. Returns the content as it is and no data key id if no master key is configured(kms.Keys), or if the content is empty.
+
+2. Executes findOrCreateDataKey function, and encrypts the content with the data key of the user.
+Returns the base64 of the nonce, the ciphertext and the tag, and the id of the data key, which is stored in content_key_id.
+The table, the id of the row(rowId) and the id of the data key are the additional data(contentAAD), so that the ciphertext cannot be moved to another row.
+*/
+func EncryptContent(table string, rowId uint, userId uint, content string) (string, *uint, error) {
+	if kms.Keys == nil || content == "" {
+		return content, nil, nil
+	}
+	if userId == 0 || rowId == 0 {
+		return "", nil, errors.New("the content cannot be encrypted without its user and its row")
+	}
+	dataKeyId, dataKey, err := findOrCreateDataKey(userId)
+	if err != nil {
+		return "", nil, err
+	}
+	sealed, err := kms.Seal(dataKey, []byte(content), contentAAD(table, rowId, dataKeyId))
+	if err != nil {
+		return "", nil, err
+	}
+	return base64.RawStdEncoding.EncodeToString(sealed), &dataKeyId, nil
+}
+
+/*
+DecryptContent function:
+
+1. Returns the content as it is if it has no data key id, because it was written before a master key was configured.
+
+2. Executes findDataKey function with the id of the data key, and decrypts the content of the row(table, rowId) with it.
+*/
+func DecryptContent(table string, rowId uint, dataKeyId *uint, stored string) (string, error) {
+	if dataKeyId == nil {
+		return stored, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", fmt.Errorf("the content encrypted at rest is malformed [%s: %d]", table, rowId)
+	}
+	dataKey, err := findDataKey(*dataKeyId)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := kms.Open(dataKey, sealed, contentAAD(table, rowId, *dataKeyId))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the content with the data key %d [%s: %d]: %w", *dataKeyId, table, rowId, err)
+	}
+	return string(plaintext), nil
+}
+
+/*
+contentAAD function:
+
+1. Returns the additional data of the content of a row: the table, the id of the row and the id of the data key.
+*/
+func contentAAD(table string, rowId uint, dataKeyId uint) []byte {
+	return []byte(fmt.Sprintf("diary:v1:%s:%d:%d", table, rowId, dataKeyId))
+}
+
+/*
+sealContent function:
+
+1. Executes EncryptContent function in the hooks of the models encrypted at rest(Entry, EntryRevision).
+
+2. If the row has no id yet, takes the next id of its table first(nextval), so that the id is part of the additional data from the first insert.
+*/
+func sealContent(tx *gorm.DB, table string, rowId *uint, userId uint, content string) (string, *uint, error) {
+	if kms.Keys == nil || content == "" {
+		return content, nil, nil
+	}
+	if *rowId == 0 {
+		// SELECT nextval(pg_get_serial_sequence('entries', 'id'))
+		var next uint
+		if err := tx.Session(&gorm.Session{NewDB: true}).Raw("SELECT nextval(pg_get_serial_sequence(?, 'id'))", table).Scan(&next).Error; err != nil {
+			return "", nil, err
+		}
+		*rowId = next
+	}
+	return EncryptContent(table, *rowId, userId, content)
+}
+
+/*
+findOrCreateDataKey function:
+
+1. Returns the cached data key of the user, or loads it and unwraps it.
+
+2. If the user has no data key, generates a random one, wraps it with the current master key, and inserts it unless another request has inserted one first.
+Then loads the data key of the user again.
+*/
+func findOrCreateDataKey(userId uint) (uint, []byte, error) {
+	// Returns the cached data key of the user, or loads it and unwraps it.
+	dataKeys.Lock()
+	dataKeyId, cached := dataKeys.byUser[userId]
+	dataKey := dataKeys.byId[dataKeyId]
+	dataKeys.Unlock()
+	if cached && dataKey != nil {
+		return dataKeyId, dataKey, nil
+	}
+	var stored DataKey
+	// SELECT * FROM "data_keys" WHERE user_id = $1$
+	result := database.Database.Where("user_id = ?", userId).Limit(1).Find(&stored)
+	if result.Error != nil {
+		return 0, nil, result.Error
+	}
+
+	// If the user has no data key, generates a random one, wraps it with the current master key, and inserts it.
+	if result.RowsAffected == 0 {
+		plainKey := make([]byte, 32)
+		if _, err := rand.Read(plainKey); err != nil {
+			return 0, nil, err
+		}
+		masterKeyId, wrapped, err := kms.Keys.Wrap(context.Background(), plainKey)
+		if err != nil {
+			return 0, nil, err
+		}
+		// INSERT INTO "data_keys" (...) VALUES (...) ON CONFLICT ("user_id") DO NOTHING RETURNING "id"
+		newKey := DataKey{UserID: userId, MasterKeyID: masterKeyId, WrappedKey: wrapped}
+		err = database.Database.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).Create(&newKey).Error
+		if err != nil {
+			return 0, nil, err
+		}
+		// SELECT * FROM "data_keys" WHERE user_id = $1$ ORDER BY "data_keys"."id" LIMIT 1
+		if err := database.Database.Where("user_id = ?", userId).First(&stored).Error; err != nil {
+			return 0, nil, err
+		}
+	}
+	dataKey, err := unwrapDataKey(stored)
+	if err != nil {
+		return 0, nil, err
+	}
+	return stored.ID, dataKey, nil
+}
+
+/*
+findDataKey function:
+
+1. Returns the cached data key, or loads it by its id and unwraps it.
+*/
+func findDataKey(id uint) ([]byte, error) {
+	dataKeys.Lock()
+	dataKey := dataKeys.byId[id]
+	dataKeys.Unlock()
+	if dataKey != nil {
+		return dataKey, nil
+	}
+	if kms.Keys == nil {
+		return nil, ErrMasterKeyMissing
+	}
+	var stored DataKey
+	// SELECT * FROM "data_keys" WHERE "data_keys"."id" = $1$ ORDER BY "data_keys"."id" LIMIT 1
+	if err := database.Database.First(&stored, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load the data key %d: %w", id, err)
+	}
+	return unwrapDataKey(stored)
+}
+
+/*
+unwrapDataKey function:
+
+1. Unwraps the data key with its master key, and caches it. The cache is emptied when it is full.
+*/
+func unwrapDataKey(stored DataKey) ([]byte, error) {
+	dataKey, err := kms.Keys.Unwrap(context.Background(), stored.MasterKeyID, stored.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap the data key %d: %w", stored.ID, err)
+	}
+	dataKeys.Lock()
+	defer dataKeys.Unlock()
+	if len(dataKeys.byId) >= maxCachedDataKeys {
+		dataKeys.byId = map[uint][]byte{}
+		dataKeys.byUser = map[uint]uint{}
+	}
+	dataKeys.byId[stored.ID] = dataKey
+	dataKeys.byUser[stored.UserID] = stored.ID
+	return dataKey, nil
+}
+
+/*
+RotateDataKeys function:
+
+1. Queries the data keys wrapped with a master key other than the current one, in batches of dataKeyBatchSize.
+
+2. Unwraps each of them with its master key, wraps it again with the current master key, and updates it.
+The data key itself does not change, so the content encrypted with it does not need to be encrypted again.
+
+3. Returns the number of the data keys that were wrapped again. Once it is done, the old master keys can be removed from KMS_MASTER_KEYS.
+*/
+func RotateDataKeys(ctx context.Context) (int, error) {
+	if kms.Keys == nil {
+		return 0, errors.New("KMS_MASTER_KEYS is not set")
+	}
+	current := kms.Keys.CurrentKeyID()
+	rotated := 0
+	var lastId uint
+	for {
+		// Queries the data keys wrapped with a master key other than the current one.
+		var batch []DataKey
+		// SELECT * FROM "data_keys" WHERE master_key_id <> $1$ AND id > $2$ ORDER BY id LIMIT $3$
+		err := database.Database.Where("master_key_id <> ? AND id > ?", current, lastId).Order("id").Limit(dataKeyBatchSize).Find(&batch).Error
+		if err != nil {
+			return rotated, err
+		}
+		if len(batch) == 0 {
+			return rotated, nil
+		}
+
+		// Unwraps each of them with its master key, wraps it again with the current master key, and updates it.
+		for _, dataKey := range batch {
+			lastId = dataKey.ID
+			plainKey, err := kms.Keys.Unwrap(ctx, dataKey.MasterKeyID, dataKey.WrappedKey)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to unwrap the data key %d: %w", dataKey.ID, err)
+			}
+			masterKeyId, wrapped, err := kms.Keys.Wrap(ctx, plainKey)
+			if err != nil {
+				return rotated, err
+			}
+			// UPDATE "data_keys" SET "master_key_id"=$1$,"wrapped_key"=$2$,"updated_at"=$3$ WHERE id = $4$ AND master_key_id = $5$
+			err = database.Database.Model(&DataKey{}).Where("id = ? AND master_key_id = ?", dataKey.ID, dataKey.MasterKeyID).
+				Updates(map[string]interface{}{"master_key_id": masterKeyId, "wrapped_key": wrapped}).Error
+			if err != nil {
+				return rotated, err
+			}
+			rotated++
+		}
+	}
+}
+
+/*
+EncryptPlaintextContent function:
+
+1. Queries the rows of the table(entries or entry_revisions) whose content is still plaintext(content_key_id IS NULL),
+in batches of dataKeyBatchSize, including the deleted ones.
+
+2. Encrypts the content of each of them with the data key of its user, without changing its updated_at or its version.
+
+3. Returns the number of the rows that were encrypted. New and updated content is encrypted anyway, so this only has to run once.
+*/
+func EncryptPlaintextContent(table string) (int, error) {
+	if kms.Keys == nil {
+		return 0, errors.New("KMS_MASTER_KEYS is not set")
+	}
+	if table != "entries" && table != "entry_revisions" {
+		return 0, fmt.Errorf("unknown table: %s", table)
+	}
+	encrypted := 0
+	var lastId uint
+	for {
+		// Queries the rows whose content is still plaintext.
+		var batch []struct {
+			ID      uint
+			UserID  uint
+			Content string
+		}
+		// SELECT id, user_id, content FROM "entries" WHERE id > $1$ AND content_key_id IS NULL AND content <> '' ORDER BY id LIMIT $2$
+		err := database.Database.Table(table).Select("id", "user_id", "content").
+			Where("id > ? AND content_key_id IS NULL AND content <> ''", lastId).
+			Order("id").Limit(dataKeyBatchSize).Scan(&batch).Error
+		if err != nil {
+			return encrypted, err
+		}
+		if len(batch) == 0 {
+			return encrypted, nil
+		}
+
+		// Encrypts the content of each of them.
+		for _, row := range batch {
+			lastId = row.ID
+			content, dataKeyId, err := EncryptContent(table, row.ID, row.UserID, row.Content)
+			if err != nil {
+				return encrypted, err
+			}
+			// UPDATE "entries" SET "content"=$1$,"content_key_id"=$2$ WHERE id = $3$ AND content_key_id IS NULL AND content = $4$
+			result := database.Database.Table(table).Where("id = ? AND content_key_id IS NULL AND content = ?", row.ID, row.Content).
+				UpdateColumns(map[string]interface{}{"content": content, "content_key_id": dataKeyId})
+			if result.Error != nil {
+				return encrypted, result.Error
+			}
+			encrypted += int(result.RowsAffected)
+		}
+	}
+}
+
+/*
+deleteDataKey function:
+
+1. Deletes the data key of the user, unless revisions the user made on the entries of others are encrypted with it.
+Without the data key, the content of the user left in backups cannot be decrypted.
+*/
+func deleteDataKey(tx *gorm.DB, userId uint) error {
+	// DELETE FROM "data_keys" WHERE user_id = $1$ AND NOT EXISTS (SELECT 1 FROM entry_revisions WHERE user_id = $2$)
+	return tx.Where("user_id = ? AND NOT EXISTS (SELECT 1 FROM entry_revisions WHERE user_id = ?)", userId, userId).Delete(&DataKey{}).Error
+}
+
+/*
+evictDataKey function:
+
+1. Removes the data key of the user from the cache(dataKeys), so that it does not stay in memory once the user is deleted.
+*/
+func evictDataKey(userId uint) {
+	dataKeys.Lock()
+	defer dataKeys.Unlock()
+	if dataKeyId, cached := dataKeys.byUser[userId]; cached {
+		delete(dataKeys.byId, dataKeyId)
+		delete(dataKeys.byUser, userId)
+	}
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"bytes"
+	"diary_api/kms"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// useTestDataKey configures a master key and caches a data key(dataKeyId) of the user, so that no database is needed.
+func useTestDataKey(t *testing.T, userId uint, dataKeyId uint) {
+	t.Helper()
+	keys, err := kms.NewLocalKeyManager("test:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := kms.Keys
+	kms.Keys = keys
+	dataKeys.Lock()
+	dataKeys.byId[dataKeyId] = bytes.Repeat([]byte{byte(dataKeyId)}, 32)
+	dataKeys.byUser[userId] = dataKeyId
+	dataKeys.Unlock()
+	t.Cleanup(func() {
+		kms.Keys = previous
+		dataKeys.Lock()
+		delete(dataKeys.byId, dataKeyId)
+		delete(dataKeys.byUser, userId)
+		dataKeys.Unlock()
+	})
+}
+
+func TestEncryptContentWithoutMasterKey(t *testing.T) {
+	stored, dataKeyId, err := EncryptContent("entries", 1, 1, "dear diary")
+	if err != nil || stored != "dear diary" || dataKeyId != nil {
+		t.Fatalf("EncryptContent() = %q, %v, %v, want the plaintext", stored, dataKeyId, err)
+	}
+	if content, err := DecryptContent("entries", 1, nil, "dear diary"); err != nil || content != "dear diary" {
+		t.Errorf("DecryptContent() = %q, %v, want the plaintext", content, err)
+	}
+}
+
+func TestEncryptContentRoundTrip(t *testing.T) {
+	useTestDataKey(t, 7, 70)
+	stored, dataKeyId, err := EncryptContent("entries", 42, 7, "dear diary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataKeyId == nil || *dataKeyId != 70 || stored == "dear diary" {
+		t.Fatalf("EncryptContent() = %q, %v, want ciphertext with the data key 70", stored, dataKeyId)
+	}
+	content, err := DecryptContent("entries", 42, dataKeyId, stored)
+	if err != nil || content != "dear diary" {
+		t.Fatalf("DecryptContent() = %q, %v, want the plaintext", content, err)
+	}
+
+	// Empty content is not encrypted.
+	if empty, emptyKeyId, err := EncryptContent("entries", 42, 7, ""); err != nil || empty != "" || emptyKeyId != nil {
+		t.Errorf("EncryptContent() of empty content = %q, %v, %v", empty, emptyKeyId, err)
+	}
+	// The content cannot be encrypted without its row, which is part of the additional data.
+	if _, _, err := EncryptContent("entries", 0, 7, "dear diary"); err == nil {
+		t.Error("EncryptContent() without a row id succeeded")
+	}
+}
+
+func TestDecryptContentRejectsTampering(t *testing.T) {
+	useTestDataKey(t, 7, 70)
+	useTestDataKey(t, 8, 80)
+	stored, dataKeyId, err := EncryptContent("entries", 42, 7, "dear diary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := base64.RawStdEncoding.DecodeString(stored)
+	sealed[len(sealed)-1] ^= 1
+	otherKeyId := uint(80)
+
+	tests := []struct {
+		name      string
+		table     string
+		rowId     uint
+		dataKeyId *uint
+		stored    string
+	}{
+		{"moved to another entry", "entries", 43, dataKeyId, stored},
+		{"moved to a revision", "entry_revisions", 42, dataKeyId, stored},
+		{"relabeled with another data key", "entries", 42, &otherKeyId, stored},
+		{"modified", "entries", 42, dataKeyId, base64.RawStdEncoding.EncodeToString(sealed)},
+		{"not base64", "entries", 42, dataKeyId, "enc:v1:1:..."},
+	}
+	for _, test := range tests {
+		if content, err := DecryptContent(test.table, test.rowId, test.dataKeyId, test.stored); err == nil {
+			t.Errorf("DecryptContent() of content %s = %q, want an error", test.name, content)
+		}
+	}
+}
+
+func TestDecryptContentWithoutMasterKey(t *testing.T) {
+	previous := kms.Keys
+	kms.Keys = nil
+	t.Cleanup(func() { kms.Keys = previous })
+	dataKeyId := uint(99)
+	if _, err := DecryptContent("entries", 1, &dataKeyId, "AAAA"); !errors.Is(err, ErrMasterKeyMissing) {
+		t.Errorf("DecryptContent() = %v, want ErrMasterKeyMissing", err)
+	}
+}
+
+func TestEntryHooksEncryptContent(t *testing.T) {
+	useTestDataKey(t, 7, 70)
+	entry := Entry{UserID: 7, Content: "dear diary"}
+	entry.ID = 42
+	if err := entry.BeforeSave(nil); err != nil {
+		t.Fatal(err)
+	}
+	if entry.ContentKeyID == nil || entry.Content == "dear diary" {
+		t.Fatalf("BeforeSave() left %q, %v, want ciphertext", entry.Content, entry.ContentKeyID)
+	}
+	loaded := Entry{Content: entry.Content, ContentKeyID: entry.ContentKeyID}
+	loaded.ID = 42
+
+	// AfterSave sets the content back to the plaintext.
+	if err := entry.AfterSave(nil); err != nil || entry.Content != "dear diary" || entry.WordCount != 2 {
+		t.Errorf("AfterSave() left %q(%d words), %v", entry.Content, entry.WordCount, err)
+	}
+	if err := loaded.AfterFind(nil); err != nil || loaded.Content != "dear diary" {
+		t.Errorf("AfterFind() = %q, %v, want the plaintext", loaded.Content, err)
+	}
+}
+
+func TestEvictDataKey(t *testing.T) {
+	useTestDataKey(t, 1, 7)
+	useTestDataKey(t, 2, 8)
+
+	evictDataKey(1)
+	dataKeys.Lock()
+	_, userCached := dataKeys.byUser[1]
+	_, keyCached := dataKeys.byId[7]
+	_, otherCached := dataKeys.byId[8]
+	dataKeys.Unlock()
+	if userCached || keyCached {
+		t.Errorf("the data key of the deleted user is still cached")
+	}
+	if !otherCached {
+		t.Errorf("the data key of another user was evicted")
+	}
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the format of a Date in JSON and in query parameters.
+const DateLayout = "2006-01-02"
+
+/*
+Date struct:
+
+1. Time
+
+A Date is a calendar date without a time of day or a time zone, e.g. the local date an entry is about.
+It is stored in a "date" column and formatted as "2006-01-02" in JSON.
+*/
+type Date struct {
+	time.Time
+}
+
+/*
+NewDate function:
+
+1. Returns the calendar date of the time in its own time zone.
+*/
+func NewDate(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+/*
+ParseDate function:
+
+1. Parses a date in the format "2006-01-02".
+*/
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date: %s (expected YYYY-MM-DD)", value)
+	}
+	return Date{t}, nil
+}
+
+/*
+Today function:
+
+1. Returns the current calendar date in the time zone(location).
+*/
+func Today(location *time.Location) Date {
+	return NewDate(time.Now().In(location))
+}
+
+/*
+String function:
+
+1. Formats the date as "2006-01-02".
+*/
+func (date Date) String() string {
+	return date.Format(DateLayout)
+}
+
+/*
+AddDays function:
+
+1. Returns the date the number of days(days) later.
+*/
+func (date Date) AddDays(days int) Date {
+	return Date{date.Time.AddDate(0, 0, days)}
+}
+
+/*
+MarshalJSON function:
+
+1. Formats the date as "2006-01-02", or null if it is zero.
+
+FYI: https://pkg.go.dev/encoding/json#Marshaler
+*/
+func (date Date) MarshalJSON() ([]byte, error) {
+	if date.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(date.String())
+}
+
+/*
+UnmarshalJSON function:
+
+1. Parses a date in the format "2006-01-02".
+
+FYI: https://pkg.go.dev/encoding/json#Unmarshaler
+*/
+func (date *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*date = Date{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*date = parsed
+	return nil
+}
+
+/*
+Scan function:
+
+1. Reads the value of a "date" column.
+
+FYI: https://gorm.io/docs/data_types.html#Implements-Customized-Data-Type
+*/
+func (date *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*date = Date{}
+	case time.Time:
+		*date = NewDate(v)
+	case string:
+		parsed, err := ParseDate(v)
+		if err != nil {
+			return err
+		}
+		*date = parsed
+	case []byte:
+		parsed, err := ParseDate(string(v))
+		if err != nil {
+			return err
+		}
+		*date = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into Date", value)
+	}
+	return nil
+}
+
+/*
+Value function:
+
+1. Returns the date as "2006-01-02", or NULL if it is zero.
+
+FYI: https://gorm.io/docs/data_types.html#Implements-Customized-Data-Type
+*/
+func (date Date) Value() (driver.Value, error) {
+	if date.IsZero() {
+		return nil, nil
+	}
+	return date.String(), nil
+}
+
+/*
+GormDataType function:
+
+1. Returns the data type of the column.
+*/
+func (Date) GormDataType() string {
+	return "date"
+}
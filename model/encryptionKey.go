@@ -0,0 +1,325 @@
+package model
+
+import (
+	"bytes"
+	"diary_api/database"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Key derivation functions that turn the passphrase of the user into the key that wraps their data key.
+const (
+	KDFArgon2id     = "argon2id"
+	KDFPBKDF2SHA256 = "pbkdf2-sha256"
+)
+
+// Minimum costs of the key derivation functions, as recommended by OWASP.
+// FYI: https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html
+const (
+	minPBKDF2Iterations  = 600000
+	minArgon2Iterations  = 2
+	minArgon2MemoryKiB   = 19456
+	maxArgon2MemoryKiB   = 4 << 20
+	maxArgon2Parallelism = 16
+	minKDFSaltLength     = 16
+)
+
+// Lengths in bytes of an AES-256-GCM key, IV and tag.
+const (
+	encryptionKeyLength = 32
+	encryptionIVLength  = 12
+	encryptionTagLength = 16
+)
+
+// The version and the algorithm of the envelope(EncryptedContent) an encrypted entry holds.
+const (
+	EncryptedContentVersion = 1
+	EncryptedContentAlg     = "A256GCM"
+)
+
+var (
+	// ErrEncryptionKeyMissing is returned when an encrypted entry is written before the user has set up their key.
+	ErrEncryptionKeyMissing = errors.New("set up an encryption key with PUT /api/encryption/key before writing encrypted entries")
+	// ErrEncryptionKeyInUse is returned when the key of a user who still has encrypted entries is deleted.
+	ErrEncryptionKeyInUse = errors.New("the encryption key cannot be deleted while encrypted entries use it")
+	// ErrEncryptedJournalEntry is returned when an encrypted entry is written in a shared journal, whose members do not have the key.
+	ErrEncryptedJournalEntry = errors.New("encrypted entries cannot be written in a shared journal")
+	// ErrEncryptedEntry is returned by the features that need the content of an entry that is end-to-end encrypted.
+	ErrEncryptedEntry = errors.New("the entry is end-to-end encrypted, so the server cannot read its content")
+)
+
+/*
+EncryptionKey struct:
+
+1. UserID
+
+2. KDF, KDFSalt, KDFIterations, KDFMemory, KDFParallelism
+
+3. WrappedKey, WrapIV
+
+The data key of the user, which encrypts their entries in the client, wrapped(AES-256-GCM) with a key derived from their passphrase.
+The server only stores it for the devices of the user: it never sees the passphrase or the data key, so it cannot decrypt the entries.
+*/
+type EncryptionKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;uniqueIndex" json:"-"`
+	KDF       string    `gorm:"size:16;not null" json:"kdf"`
+	// KDFSalt, WrappedKey and WrapIV are base64-encoded.
+	KDFSalt       string `gorm:"size:255;not null" json:"kdf_salt"`
+	KDFIterations int    `gorm:"not null" json:"kdf_iterations"`
+	// KDFMemory(KiB) and KDFParallelism are only used by argon2id.
+	KDFMemory      int    `json:"kdf_memory,omitempty"`
+	KDFParallelism int    `json:"kdf_parallelism,omitempty"`
+	WrappedKey     string `gorm:"size:255;not null" json:"wrapped_key"`
+	WrapIV         string `gorm:"size:64;not null" json:"wrap_iv"`
+}
+
+/*
+EncryptionKeyInput struct:
+
+1. KDF, KDFSalt, KDFIterations, KDFMemory, KDFParallelism
+
+2. WrappedKey, WrapIV
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type EncryptionKeyInput struct {
+	KDF            string `json:"kdf" binding:"required,oneof=argon2id pbkdf2-sha256"`
+	KDFSalt        string `json:"kdf_salt" binding:"required,max=255"`
+	KDFIterations  int    `json:"kdf_iterations" binding:"required,min=1"`
+	KDFMemory      int    `json:"kdf_memory" binding:"omitempty,min=1"`
+	KDFParallelism int    `json:"kdf_parallelism" binding:"omitempty,min=1"`
+	WrappedKey     string `json:"wrapped_key" binding:"required,max=255"`
+	WrapIV         string `json:"wrap_iv" binding:"required,max=64"`
+}
+
+/*
+EncryptedContent struct:
+
+1. V
+
+2. Alg
+
+3. KID
+
+4. IV
+
+5. CT
+
+The envelope an encrypted entry stores as its content, e.g. {"v":1,"alg":"A256GCM","kid":3,"iv":"...","ct":"..."}.
+The content is encrypted with AES-256-GCM by the data key(kid) of the user. IV and CT(the ciphertext followed by the tag) are base64-encoded.
+*/
+type EncryptedContent struct {
+	V   int    `json:"v"`
+	Alg string `json:"alg"`
+	KID uint   `json:"kid"`
+	IV  string `json:"iv"`
+	CT  string `json:"ct"`
+}
+
+/*
+Apply function:
+
+1. Checks the costs of the key derivation function, so that a weak passphrase is not made weaker.
+
+2. Checks the lengths of the salt, the wrapped key(a 256-bit key and its tag) and the IV.
+
+3. Sets the key from the input.
+*/
+func (key *EncryptionKey) Apply(input EncryptionKeyInput) error {
+	// Checks the costs of the key derivation function.
+	switch input.KDF {
+	case KDFPBKDF2SHA256:
+		if input.KDFIterations < minPBKDF2Iterations {
+			return fmt.Errorf("kdf_iterations must be at least %d for %s", minPBKDF2Iterations, KDFPBKDF2SHA256)
+		}
+		input.KDFMemory, input.KDFParallelism = 0, 0
+	case KDFArgon2id:
+		if input.KDFIterations < minArgon2Iterations {
+			return fmt.Errorf("kdf_iterations must be at least %d for %s", minArgon2Iterations, KDFArgon2id)
+		}
+		if input.KDFMemory < minArgon2MemoryKiB || input.KDFMemory > maxArgon2MemoryKiB {
+			return fmt.Errorf("kdf_memory must be between %d and %d KiB for %s", minArgon2MemoryKiB, maxArgon2MemoryKiB, KDFArgon2id)
+		}
+		if input.KDFParallelism < 1 || input.KDFParallelism > maxArgon2Parallelism {
+			return fmt.Errorf("kdf_parallelism must be between 1 and %d for %s", maxArgon2Parallelism, KDFArgon2id)
+		}
+	}
+
+	// Checks the lengths of the salt, the wrapped key and the IV.
+	if err := checkBase64Length("kdf_salt", input.KDFSalt, minKDFSaltLength, 0); err != nil {
+		return err
+	}
+	if err := checkBase64Length("wrapped_key", input.WrappedKey, encryptionKeyLength+encryptionTagLength, encryptionKeyLength+encryptionTagLength); err != nil {
+		return err
+	}
+	if err := checkBase64Length("wrap_iv", input.WrapIV, encryptionIVLength, encryptionIVLength); err != nil {
+		return err
+	}
+
+	// Sets the key from the input.
+	key.KDF = input.KDF
+	key.KDFSalt = input.KDFSalt
+	key.KDFIterations = input.KDFIterations
+	key.KDFMemory = input.KDFMemory
+	key.KDFParallelism = input.KDFParallelism
+	key.WrappedKey = input.WrappedKey
+	key.WrapIV = input.WrapIV
+	return nil
+}
+
+/*
+Save function:
+
+1. Inserts the key, or updates it if it already exists, e.g. when the passphrase is changed and the same data key is wrapped again.
+*/
+func (key *EncryptionKey) Save() (*EncryptionKey, error) {
+	// INSERT INTO "encryption_keys" (...) VALUES (...) RETURNING "id", or UPDATE "encryption_keys" SET ... WHERE "id" = $1$
+	if err := database.Database.Save(key).Error; err != nil {
+		return &EncryptionKey{}, err
+	}
+	return key, nil
+}
+
+/*
+FindEncryptionKeyByUserId function:
+
+1. Queries the database to find the key of the user.
+
+2. If the user has not set up a key, gorm.ErrRecordNotFound is returned.
+*/
+func FindEncryptionKeyByUserId(userId uint) (EncryptionKey, error) {
+	var key EncryptionKey
+	// SELECT * FROM "encryption_keys" WHERE user_id = $1$ ORDER BY "encryption_keys"."id" LIMIT 1
+	err := database.Database.Where("user_id = ?", userId).First(&key).Error
+	if err != nil {
+		return EncryptionKey{}, err
+	}
+	return key, nil
+}
+
+/*
+DeleteEncryptionKey function:
+
+1. If the user still has encrypted entries, including deleted ones, ErrEncryptionKeyInUse is returned, because they could never be decrypted again.
+
+2. Deletes the key of the user. If the user has no key, gorm.ErrRecordNotFound is returned.
+*/
+func DeleteEncryptionKey(userId uint) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		// SELECT count(*) FROM "entries" WHERE user_id = $1$ AND encrypted
+		if err := tx.Unscoped().Model(&Entry{}).Where("user_id = ? AND encrypted", userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEncryptionKeyInUse
+		}
+		// DELETE FROM "encryption_keys" WHERE user_id = $1$
+		result := tx.Where("user_id = ?", userId).Delete(&EncryptionKey{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+/*
+ValidateEncryptedContent function:
+
+1. Decodes the envelope strictly: unknown keys are rejected, so that plaintext is not stored by mistake.
+
+2. Checks its version, its algorithm, that it is encrypted by the key(keyId), and the lengths of its IV and its ciphertext.
+
+The server cannot check that the ciphertext decrypts, only that it has the form of one.
+*/
+func ValidateEncryptedContent(content string, keyId uint) error {
+	// Decodes the envelope strictly.
+	var envelope EncryptedContent
+	decoder := json.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&envelope); err != nil || decoder.More() {
+		return errors.New("the content of an encrypted entry must be an envelope: {\"v\":1,\"alg\":\"A256GCM\",\"kid\":...,\"iv\":\"...\",\"ct\":\"...\"}")
+	}
+
+	// Checks its version, its algorithm, its key, and the lengths of its IV and its ciphertext.
+	if envelope.V != EncryptedContentVersion {
+		return fmt.Errorf("unsupported envelope version: %d", envelope.V)
+	}
+	if envelope.Alg != EncryptedContentAlg {
+		return fmt.Errorf("unsupported envelope algorithm: %q (use %q)", envelope.Alg, EncryptedContentAlg)
+	}
+	if envelope.KID != keyId {
+		return fmt.Errorf("the envelope is not encrypted with your current key: kid must be %d", keyId)
+	}
+	if err := checkBase64Length("iv", envelope.IV, encryptionIVLength, encryptionIVLength); err != nil {
+		return err
+	}
+	return checkBase64Length("ct", envelope.CT, encryptionTagLength, 0)
+}
+
+/*
+ValidateEncryption function:
+
+1. Does nothing for entries that are not encrypted.
+
+2. Rejects encrypted entries in shared journals. An empty draft needs no envelope yet.
+
+3. Executes FindEncryptionKeyByUserId function and ValidateEncryptedContent function with the key of the author.
+*/
+func (entry *Entry) ValidateEncryption() error {
+	if !entry.Encrypted {
+		return nil
+	}
+	if entry.JournalID != nil {
+		return ErrEncryptedJournalEntry
+	}
+	if entry.Content == "" && entry.Status == EntryStatusDraft {
+		return nil
+	}
+	key, err := FindEncryptionKeyByUserId(entry.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrEncryptionKeyMissing
+	}
+	if err != nil {
+		return err
+	}
+	return ValidateEncryptedContent(entry.Content, key.ID)
+}
+
+/*
+checkBase64Length function:
+
+1. Decodes the standard base64 value, with or without padding.
+
+2. Checks that it has at least min bytes, and at most max bytes unless max is 0.
+*/
+func checkBase64Length(name string, value string, min int, max int) error {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		if decoded, err = base64.RawStdEncoding.DecodeString(value); err != nil {
+			return fmt.Errorf("%s must be base64", name)
+		}
+	}
+	if len(decoded) < min || (max > 0 && len(decoded) > max) {
+		if min == max {
+			return fmt.Errorf("%s must be %d bytes", name, min)
+		}
+		return fmt.Errorf("%s must be at least %d bytes", name, min)
+	}
+	return nil
+}
@@ -2,9 +2,12 @@ package model
 
 import (
 	"diary_api/database"
+	"errors"
 	"fmt"
+	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 /*
@@ -13,21 +16,104 @@ Entry struct:
 1. Content
 
 2. UserID
+
+3. EntryDate
+
+4. TimeZone
+
+5. Tags
+
+6. Mood
+
+7. Fields
+
+8. Version
+
+9. Status
+
+10. Format
+
+11. Attachments
+
+12. JournalID
+
+13. CommentCount, ReactionCounts
+
+14. Encrypted
 */
 type Entry struct {
 	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
 	// FYI: https://gorm.io/docs/models.html#gorm-Model
 	gorm.Model
+	// Content is encrypted at rest with the data key of the user(DataKey) when a master key is configured.
 	Content string `gorm:"type:text" json:"content"`
-	UserID  uint
+	// ContentKeyID is the data key the content is encrypted with. It is null if the content is plaintext.
+	ContentKeyID *uint `gorm:"index" json:"-"`
+	// plaintext keeps the content while it is encrypted for a write(BeforeSave).
+	plaintext string
+	UserID    uint
+	// EntryDate is the local calendar date the entry is about. It can be earlier than CreatedAt(backdating).
+	EntryDate Date `gorm:"index" json:"entry_date"`
+	// TimeZone is the IANA time zone of the writer when the entry was written.
+	TimeZone string `gorm:"size:64" json:"time_zone"`
+	// Entry has and belongs to many Tags of the same user.
+	// FYI: https://gorm.io/docs/many_to_many.html
+	Tags []Tag `gorm:"many2many:entry_tags;" json:"tags"`
+	// Mood is optional, from 1(worst) to 5(best).
+	Mood *int `json:"mood"`
+	// Fields are the values of the custom fields the user has defined(FieldDefinition), by their key.
+	Fields map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"fields"`
+	// Version is incremented on every update. Clients send it back in If-Match(ETag) to detect concurrent edits.
+	Version int `gorm:"not null;default:1" json:"version"`
+	// Status is EntryStatusDraft while the entry is being written, and EntryStatusPublished after that.
+	Status string `gorm:"size:16;not null;default:published;index" json:"status"`
+	// Format is how the content is written, EntryFormatPlain or EntryFormatCommonMark. The content is always stored as written.
+	Format string `gorm:"size:16;not null;default:plain" json:"format"`
+	// WordCount and ReadingTimeMinutes are computed from the content whenever the entry is loaded or saved.
+	WordCount          int `gorm:"-" json:"word_count"`
+	ReadingTimeMinutes int `gorm:"-" json:"reading_time_minutes"`
+	// HTML is the sanitized HTML of the content. It is only set when the client asks for it(?render=html).
+	HTML string `gorm:"-" json:"html,omitempty"`
+	// Entry has many Attachments. Their content is stored in the blob store.
+	// FYI: https://gorm.io/docs/has_many.html
+	Attachments []Attachment `json:"attachments"`
+	// JournalID is the journal the entry belongs to. Entries without a journal are personal: only their author can see them.
+	// UserID stays the author, whose tags and custom fields the entry uses.
+	JournalID *uint `gorm:"index" json:"journal_id"`
+	// CommentCount and ReactionCounts(by emoji) are counted when the entry is loaded by FindEntryById or FindEntries.
+	CommentCount   int            `gorm:"-" json:"comment_count"`
+	ReactionCounts map[string]int `gorm:"-" json:"reaction_counts"`
+	// Encrypted entries hold an envelope(EncryptedContent) encrypted in the client, which the server cannot read.
+	// It is set when the entry is created and never changes. The entry date, the tags, the mood and the fields stay plaintext.
+	Encrypted bool `gorm:"not null;default:false" json:"encrypted"`
 }
 
+// Formats of the content of an entry.
+const (
+	EntryFormatPlain      = "plain"
+	EntryFormatCommonMark = "commonmark"
+)
+
+// wordsPerMinute is the reading speed used for ReadingTimeMinutes.
+const wordsPerMinute = 200
+
+// Statuses of an entry. Drafts are excluded from listings unless they are requested.
+const (
+	EntryStatusDraft     = "draft"
+	EntryStatusPublished = "published"
+)
+
+// ErrEntryVersionConflict is returned when an entry has been updated since it was loaded.
+var ErrEntryVersionConflict = errors.New("the entry has been updated by another request")
+
 /*
 Save function:
 
-1. Passes the address of the pointer variable(entry) to (*gorm.DB).Create function.
+1. Executes (*Entry).ValidateEncryption function, and passes the address of the pointer variable(entry) to (*gorm.DB).Create function.
+
+2. Creates the first revision of the entry in the same transaction, unless the entry is a draft.
 
-2. If (*gorm.DB).Create function is successfully executed, it returns the address of the pointer variable(entry) and nil.
+3. If (*gorm.DB).Create function is successfully executed, it returns the address of the pointer variable(entry) and nil.
 
 FYI: https://gorm.io/docs/create.html
 
@@ -38,19 +124,32 @@ If you want to change the state of the receiver in a method, manipulating the va
 FYI: https://go.dev/tour/methods/8
 */
 func (entry *Entry) Save() (*Entry, error) {
-	// Passes the address of the pointer variable(entry) to (*gorm.DB).Create function.
-	// INSERT INTO "entries" ("created_at","updated_at","deleted_at","content","user_id") VALUES ($1$,$2$,$3$,$4$,$5$) RETURNING "id"
-	result := database.Database.Create(&entry)
-	fmt.Printf("result: %#v\n", result)
-	// Returns inserted data's primary key.
-	id := entry.ID
-	fmt.Printf("id: %#v\n", id)
-	// Returns error.
-	err := result.Error
-	fmt.Printf("err: %#v\n", err)
-	// Returns inserted records count.
-	rowsAffected := result.RowsAffected
-	fmt.Printf("rowsAffected: %#v\n", rowsAffected)
+	if err := entry.ValidateEncryption(); err != nil {
+		return &Entry{}, err
+	}
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		// Passes the address of the pointer variable(entry) to (*gorm.DB).Create function.
+		// INSERT INTO "entries" ("created_at","updated_at","deleted_at","content","user_id") VALUES ($1$,$2$,$3$,$4$,$5$) RETURNING "id"
+		result := tx.Create(&entry)
+		fmt.Printf("result: %#v\n", result)
+		// Returns inserted data's primary key.
+		id := entry.ID
+		fmt.Printf("id: %#v\n", id)
+		// Returns error.
+		err := result.Error
+		fmt.Printf("err: %#v\n", err)
+		// Returns inserted records count.
+		rowsAffected := result.RowsAffected
+		fmt.Printf("rowsAffected: %#v\n", rowsAffected)
+		if err != nil {
+			return err
+		}
+		// Creates the first revision of the entry. Drafts get their first revision when they are published.
+		if entry.Status == EntryStatusDraft {
+			return nil
+		}
+		return createEntryRevision(tx, entry, entry.UserID)
+	})
 	if err != nil {
 		// If (*gorm.DB).Create function fails to execute,
 		// it returns the address of empty struct and an error.
@@ -61,23 +160,259 @@ func (entry *Entry) Save() (*Entry, error) {
 	return entry, nil
 }
 
+/*
+Update function:
+
+1. Executes (*Entry).ValidateEncryption function.
+Updates the content, the entry date, the time zone, the mood, the fields, the status and the format of the entry, and increments its version.
+If the entry has been updated since it was loaded, ErrEntryVersionConflict is returned.
+
+2. Replaces the tags of the entry with entry.Tags.
+
+3. Creates the next revision of the entry in the same transaction, unless the entry is still a draft.
+The revision records the user who made the change(editorId), who is not the author if the entry belongs to a journal.
+
+FYI: https://gorm.io/docs/update.html#Update-Selected-Fields
+*/
+func (entry *Entry) Update(editorId uint) (*Entry, error) {
+	if err := entry.ValidateEncryption(); err != nil {
+		return &Entry{}, err
+	}
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		// UPDATE "entries" SET "updated_at"=$1$,"content"=$2$,"entry_date"=$3$,"time_zone"=$4$,"mood"=$5$,"fields"=$6$,"version"=$7$,"status"=$8$,"format"=$9$
+		// WHERE version = $10$ AND "entries"."deleted_at" IS NULL AND "id" = $11$
+		loadedVersion := entry.Version
+		entry.Version++
+		result := tx.Model(entry).Where("version = ?", loadedVersion).
+			Select("content", "content_key_id", "entry_date", "time_zone", "mood", "fields", "version", "status", "format").Updates(entry)
+		if result.Error != nil {
+			entry.Version = loadedVersion
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// The entry has been updated since it was loaded.
+			entry.Version = loadedVersion
+			return ErrEntryVersionConflict
+		}
+		// FYI: https://gorm.io/docs/associations.html#Replace-Associations
+		if err := tx.Model(entry).Association("Tags").Replace(entry.Tags); err != nil {
+			return err
+		}
+		// Creates the next revision of the entry.
+		if entry.Status == EntryStatusDraft {
+			return nil
+		}
+		return createEntryRevision(tx, entry, editorId)
+	})
+	if err != nil {
+		return &Entry{}, err
+	}
+	return entry, nil
+}
+
+/*
+AfterFind function:
+
+1. Executes DecryptContent function if the content is encrypted at rest(ContentKeyID).
+
+2. Computes the word count and the reading time of the entry after it is loaded.
+
+FYI: https://gorm.io/docs/hooks.html#Querying-an-object
+*/
+func (entry *Entry) AfterFind(tx *gorm.DB) error {
+	content, err := DecryptContent("entries", entry.ID, entry.ContentKeyID, entry.Content)
+	if err != nil {
+		return err
+	}
+	entry.Content = content
+	entry.computeStatistics()
+	return nil
+}
+
+/*
+BeforeSave function:
+
+1. Encrypts the content with the data key of the user before it is created or updated(sealContent), and sets ContentKeyID.
+A new entry takes its id first, because the id is part of the additional data.
+
+FYI: https://gorm.io/docs/hooks.html#Creating-an-object
+*/
+func (entry *Entry) BeforeSave(tx *gorm.DB) error {
+	entry.plaintext = entry.Content
+	content, dataKeyId, err := sealContent(tx, "entries", &entry.ID, entry.UserID, entry.Content)
+	if err != nil {
+		return err
+	}
+	entry.Content, entry.ContentKeyID = content, dataKeyId
+	return nil
+}
+
+/*
+AfterSave function:
+
+1. Sets the content back to the plaintext, which BeforeSave function has encrypted.
+
+2. Computes the word count and the reading time of the entry after it is created or updated.
+
+FYI: https://gorm.io/docs/hooks.html#Creating-an-object
+*/
+func (entry *Entry) AfterSave(tx *gorm.DB) error {
+	entry.Content = entry.plaintext
+	entry.computeStatistics()
+	return nil
+}
+
+/*
+computeStatistics function:
+
+1. Counts the words of the content. Each Chinese or Japanese character counts as a word, because those languages are written without spaces.
+
+2. Sets the reading time in whole minutes, at wordsPerMinute.
+
+Encrypted entries have no statistics, because their content is ciphertext.
+*/
+func (entry *Entry) computeStatistics() {
+	if entry.Encrypted {
+		entry.WordCount, entry.ReadingTimeMinutes = 0, 0
+		return
+	}
+	words := 0
+	inWord := false
+	for _, r := range entry.Content {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			words++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case r == '\'' || r == '’' || r == '-':
+			// Apostrophes and hyphens do not split a word, e.g. "don't" and "well-known".
+		default:
+			inWord = false
+		}
+	}
+	entry.WordCount = words
+	entry.ReadingTimeMinutes = (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
 /*
 FindEntryById function:
 
 1. Queries the database to find the corresponding user.
 
-2. If (*gorm.DB).Find function is successfully executed, it returns the user struct and nil.
+2. Counts the comments and the reactions of the entry.
+
+3. If (*gorm.DB).Find function is successfully executed, it returns the user struct and nil.
 */
 func FindEntryById(id uint) (Entry, error) {
 	var entry Entry
 	// SELECT * FROM "entries" WHERE ID=$1$ AND "entries"."deleted_at" IS NULL
-	err := database.Database.Where("ID=?", id).Find(&entry).Error
+	err := database.Database.Preload("Tags").Preload("Attachments").Where("ID=?", id).Find(&entry).Error
 	if err != nil {
 		// If (*gorm.DB).Find function fails to execute,
 		// it Entry the empty struct and an error.
 		return Entry{}, err
 	}
+	if entry.ID != 0 {
+		// Counts the comments and the reactions of the entry.
+		entries := []Entry{entry}
+		if err := fillEntryResponseCounts(entries); err != nil {
+			return Entry{}, err
+		}
+		entry = entries[0]
+	}
 	// If (*gorm.DB).Find function is successfully executed,
 	// it returns the entry struct and nil.
 	return entry, nil
 }
+
+/*
+FindEntriesInBatches function:
+
+1. Queries every entry of the user, including soft-deleted ones, in batches of the size(batchSize).
+
+2. Executes the callback with each batch, so that all entries are never loaded into memory at once.
+
+FYI: https://gorm.io/docs/advanced_query.html#FindInBatches
+*/
+func FindEntriesInBatches(userId uint, batchSize int, callback func(entries []Entry) error) error {
+	var entries []Entry
+	// SELECT * FROM "entries" WHERE user_id=$1$ ORDER BY "entries"."id" LIMIT $2$
+	return database.Database.Unscoped().Preload("Tags").Where("user_id=?", userId).
+		FindInBatches(&entries, batchSize, func(tx *gorm.DB, batch int) error {
+			return callback(entries)
+		}).Error
+}
+
+/*
+BackfillEntryDates function:
+
+1. Sets the entry date of the entries written before entry dates existed to the UTC date of created_at.
+*/
+func BackfillEntryDates() error {
+	// UPDATE "entries" SET "entry_date"=(created_at AT TIME ZONE 'UTC')::date,"time_zone"='UTC' WHERE entry_date IS NULL
+	return database.Database.Unscoped().Model(&Entry{}).Where("entry_date IS NULL").UpdateColumns(map[string]interface{}{
+		"entry_date": gorm.Expr("(created_at AT TIME ZONE 'UTC')::date"),
+		"time_zone":  DefaultTimeZone,
+	}).Error
+}
+
+/*
+AutosaveDraft function:
+
+1. Updates the content of the draft of the user, and increments its version.
+If expectedVersion is set and the draft has been updated since, ErrEntryVersionConflict is returned.
+
+2. If the draft does not exist, gorm.ErrRecordNotFound is returned.
+If the draft is encrypted, the content is checked by (*Entry).ValidateEncryption function first.
+The content is encrypted at rest by EncryptContent function, because updates with a map do not go through BeforeSave function.
+
+Autosaves are frequent and small, so they only write one row and create no revisions.
+*/
+func AutosaveDraft(userId uint, id uint, content string, expectedVersion *int) (Entry, error) {
+	var draft Entry
+	// SELECT "id","user_id","status","journal_id","encrypted" FROM "entries" WHERE id = $1$ AND user_id = $2$ AND status = 'draft' AND "entries"."deleted_at" IS NULL
+	err := database.Database.Select("id", "user_id", "status", "journal_id", "encrypted").
+		Where("id = ? AND user_id = ? AND status = ?", id, userId, EntryStatusDraft).First(&draft).Error
+	if err != nil {
+		return Entry{}, err
+	}
+	draft.Content = content
+	if err := draft.ValidateEncryption(); err != nil {
+		return Entry{}, err
+	}
+
+	storedContent, dataKeyId, err := EncryptContent("entries", id, userId, content)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var entry Entry
+	// UPDATE "entries" SET "content"=$1$,"content_key_id"=$2$,"version"=version + 1,"updated_at"=$3$
+	// WHERE id = $4$ AND user_id = $5$ AND status = 'draft' AND "entries"."deleted_at" IS NULL RETURNING *
+	query := database.Database.Model(&entry).Clauses(clause.Returning{}).
+		Where("id = ? AND user_id = ? AND status = ?", id, userId, EntryStatusDraft)
+	if expectedVersion != nil {
+		query = query.Where("version = ?", *expectedVersion)
+	}
+	result := query.Updates(map[string]interface{}{"content": storedContent, "content_key_id": dataKeyId, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return Entry{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Tells a missing draft apart from a version conflict.
+		var count int64
+		database.Database.Model(&Entry{}).Where("id = ? AND user_id = ? AND status = ?", id, userId, EntryStatusDraft).Count(&count)
+		if count == 0 {
+			return Entry{}, gorm.ErrRecordNotFound
+		}
+		return Entry{}, ErrEntryVersionConflict
+	}
+	// RETURNING returns the stored content, which is encrypted if a master key is configured.
+	entry.Content = content
+	entry.computeStatistics()
+	return entry, nil
+}
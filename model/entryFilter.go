@@ -0,0 +1,202 @@
+package model
+
+import (
+	"diary_api/database"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+/*
+EntryFilter struct:
+
+1. UserID
+
+2. Date
+
+3. From
+
+4. To
+
+5. Tags
+
+6. TagMode
+
+7. Mood
+
+8. Fields
+
+9. Statuses
+
+10. JournalID
+
+11. AnyJournal
+
+The conditions of listing entries. The zero value of a condition means that it is not applied.
+*/
+type EntryFilter struct {
+	UserID uint
+	// Date matches the local calendar date(entry_date) of the entries, not their UTC created_at.
+	Date *Date
+	// From and To are an inclusive range of local dates. Either of them can be left open.
+	From *Date
+	To   *Date
+	// Tags are normalized tag names. TagMode decides whether an entry needs every tag(TagModeAll) or any of them(TagModeAny).
+	Tags    []string
+	TagMode string
+	Mood    *int
+	// Fields are normalized values of custom fields. An entry matches if it has every value.
+	Fields map[string]interface{}
+	// Statuses defaults to published entries only, so that drafts are excluded unless they are requested.
+	Statuses []string
+	// JournalID lists the entries of a journal, which the user must be a member of. Without it, only the personal entries of the user are listed.
+	JournalID *uint
+	// AnyJournal lists the entries the user wrote, personal or in any journal, e.g. their drafts.
+	AnyJournal bool
+}
+
+/*
+EntryDateGroup struct:
+
+1. Date
+
+2. Entries
+*/
+type EntryDateGroup struct {
+	Date    Date    `json:"date"`
+	Entries []Entry `json:"entries"`
+}
+
+/*
+apply function:
+
+1. Adds the conditions of the filter to the query.
+*/
+func (filter EntryFilter) apply(query *gorm.DB) *gorm.DB {
+	switch {
+	case filter.JournalID != nil:
+		// The drafts of a journal are only listed for their author.
+		query = query.Where("entries.journal_id = ?", *filter.JournalID).
+			Where("entries.status <> ? OR entries.user_id = ?", EntryStatusDraft, filter.UserID)
+	case filter.AnyJournal:
+		query = query.Where("entries.user_id = ?", filter.UserID)
+	default:
+		query = query.Where("entries.user_id = ? AND entries.journal_id IS NULL", filter.UserID)
+	}
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{EntryStatusPublished}
+	}
+	query = query.Where("entries.status IN ?", statuses)
+	if filter.Date != nil {
+		query = query.Where("entries.entry_date = ?", *filter.Date)
+	}
+	if filter.From != nil {
+		query = query.Where("entries.entry_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("entries.entry_date <= ?", *filter.To)
+	}
+	if len(filter.Tags) > 0 {
+		tagged := database.Database.Table("entry_tags").Select("entry_tags.entry_id").
+			Joins("JOIN tags ON tags.id = entry_tags.tag_id").
+			Where("tags.name IN ?", filter.Tags)
+		if filter.JournalID == nil {
+			// The entries of a journal have the tags of their authors.
+			tagged = tagged.Where("tags.user_id = ?", filter.UserID)
+		}
+		if filter.TagMode != TagModeAny {
+			tagged = tagged.Group("entry_tags.entry_id").Having("count(DISTINCT tags.id) = ?", len(filter.Tags))
+		}
+		query = query.Where("entries.id IN (?)", tagged)
+	}
+	if filter.Mood != nil {
+		query = query.Where("entries.mood = ?", *filter.Mood)
+	}
+	if len(filter.Fields) > 0 {
+		// The "@>" operator matches the entries whose fields contain every value, e.g. {"sleep":8,"gym":true}.
+		// FYI: https://www.postgresql.org/docs/current/datatype-json.html#JSON-CONTAINMENT
+		containment, err := json.Marshal(filter.Fields)
+		if err != nil {
+			query.AddError(err)
+		}
+		query = query.Where("entries.fields @> ?::jsonb", string(containment))
+	}
+	return query
+}
+
+/*
+FindEntries function:
+
+1. Queries the database to find the entries matching the filter, ordered by their local date.
+
+2. Counts the comments and the reactions of the entries.
+*/
+func FindEntries(filter EntryFilter) ([]Entry, error) {
+	var entries []Entry
+	// SELECT * FROM "entries" WHERE entries.user_id = $1$ AND "entries"."deleted_at" IS NULL ORDER BY entries.entry_date, entries.id
+	err := filter.apply(database.Database.Model(&Entry{})).Preload("Tags").Preload("Attachments").Order("entries.entry_date, entries.id").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	// Counts the comments and the reactions of the entries.
+	if err := fillEntryResponseCounts(entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+/*
+GroupEntriesByDate function:
+
+1. Groups the entries, which are ordered by their local date, by their local date.
+*/
+func GroupEntriesByDate(entries []Entry) []EntryDateGroup {
+	groups := []EntryDateGroup{}
+	for _, entry := range entries {
+		last := len(groups) - 1
+		if last < 0 || !groups[last].Date.Equal(entry.EntryDate.Time) {
+			groups = append(groups, EntryDateGroup{Date: entry.EntryDate, Entries: []Entry{}})
+			last++
+		}
+		groups[last].Entries = append(groups[last].Entries, entry)
+	}
+	return groups
+}
+
+/*
+FindEntriesInBatchesByFilter function:
+
+1. Queries the entries matching the filter in batches of the size(batchSize), ordered by their local date.
+
+2. Executes the callback with each batch, so that all entries are never loaded into memory at once.
+
+Each batch starts after the last entry of the previous one(keyset pagination), so that the order by date is kept,
+which (*gorm.DB).FindInBatches cannot do.
+*/
+func FindEntriesInBatchesByFilter(filter EntryFilter, batchSize int, callback func(entries []Entry) error) error {
+	var lastDate *Date
+	var lastId uint
+	for {
+		var entries []Entry
+		query := filter.apply(database.Database.Model(&Entry{})).Preload("Tags")
+		if lastDate != nil {
+			query = query.Where("(entries.entry_date, entries.id) > (?, ?)", *lastDate, lastId)
+		}
+		// SELECT * FROM "entries" WHERE ... AND (entries.entry_date, entries.id) > ($1$, $2$) ORDER BY entries.entry_date, entries.id LIMIT $3$
+		if err := query.Order("entries.entry_date, entries.id").Limit(batchSize).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		last := entries[len(entries)-1]
+		lastDate, lastId = &last.EntryDate, last.ID
+		if err := callback(entries); err != nil {
+			return err
+		}
+		if len(entries) < batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+EntryInput struct:
+
+1. Content
+
+2. EntryDate
+
+3. TimeZone
+
+4. Tags
+
+5. ExtractHashtags
+
+6. Mood
+
+7. Fields
+
+8. Format
+
+9. JournalID
+
+10. Encrypted
+
+EntryDate and TimeZone are optional. They default to today in the time zone of the user's profile.
+If ExtractHashtags is true, the #hashtags in the content are added to the tags.
+If JournalID is set, the entry is written in the journal, where the user must be an editor or the owner.
+If Encrypted is true, the content must be an envelope(EncryptedContent) encrypted with the key of the user.
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type EntryInput struct {
+	Content         string                 `json:"content" binding:"required"`
+	EntryDate       *Date                  `json:"entry_date"`
+	TimeZone        *string                `json:"time_zone"`
+	Tags            []string               `json:"tags" binding:"max=50"`
+	ExtractHashtags bool                   `json:"extract_hashtags"`
+	Mood            *int                   `json:"mood" binding:"omitempty,min=1,max=5"`
+	Fields          map[string]interface{} `json:"fields"`
+	Format          string                 `json:"format" binding:"omitempty,oneof=plain commonmark"`
+	JournalID       *uint                  `json:"journal_id"`
+	Encrypted       bool                   `json:"encrypted"`
+}
+
+/*
+EntryUpdateInput struct:
+
+1. Content
+
+2. EntryDate
+
+3. TimeZone
+
+4. Tags
+
+5. ExtractHashtags
+
+6. Mood
+
+7. Fields
+
+8. Format
+
+Every field is optional, and only the fields that are sent are updated.
+Tags replace the tags of the entry, and an empty list removes them.
+A mood of 0 removes the mood. Fields replace the fields of the entry, and an empty object removes them.
+*/
+type EntryUpdateInput struct {
+	Content         *string                 `json:"content" binding:"omitempty,min=1"`
+	EntryDate       *Date                   `json:"entry_date"`
+	TimeZone        *string                 `json:"time_zone"`
+	Tags            *[]string               `json:"tags" binding:"omitempty,max=50"`
+	ExtractHashtags bool                    `json:"extract_hashtags"`
+	Mood            *int                    `json:"mood" binding:"omitempty,min=0,max=5"`
+	Fields          *map[string]interface{} `json:"fields"`
+	Format          *string                 `json:"format" binding:"omitempty,oneof=plain commonmark"`
+}
+
+/*
+DraftInput struct:
+
+1. Content
+
+2. EntryDate
+
+3. TimeZone
+
+4. Format
+
+5. JournalID
+
+6. Encrypted
+
+A draft can be started without content. EntryDate, TimeZone, JournalID and Encrypted are set as in EntryInput.
+*/
+type DraftInput struct {
+	Content   string  `json:"content"`
+	EntryDate *Date   `json:"entry_date"`
+	TimeZone  *string `json:"time_zone"`
+	Format    string  `json:"format" binding:"omitempty,oneof=plain commonmark"`
+	JournalID *uint   `json:"journal_id"`
+	Encrypted bool    `json:"encrypted"`
+}
+
+/*
+DraftAutosaveInput struct:
+
+1. Content
+*/
+type DraftAutosaveInput struct {
+	Content *string `json:"content" binding:"required"`
+}
+
+/*
+ApplyEntryDate function:
+
+1. Sets the time zone of the entry from the input, or from the default time zone(defaultTimeZone) if it is not set yet.
+
+2. Sets the entry date from the input, or to today in the time zone of the entry if it is not set yet.
+
+3. Rejects entry dates in the future. Entry dates in the past(backdating) are allowed.
+*/
+func (entry *Entry) ApplyEntryDate(entryDate *Date, timeZone *string, defaultTimeZone string) error {
+	// Sets the time zone of the entry.
+	if timeZone != nil {
+		if _, err := time.LoadLocation(*timeZone); err != nil || *timeZone == "" || *timeZone == "Local" {
+			return fmt.Errorf("unknown time zone: %s", *timeZone)
+		}
+		entry.TimeZone = *timeZone
+	}
+	if entry.TimeZone == "" {
+		entry.TimeZone = defaultTimeZone
+	}
+	location, err := time.LoadLocation(entry.TimeZone)
+	if err != nil {
+		return err
+	}
+
+	// Sets the entry date.
+	today := Today(location)
+	if entryDate != nil && !entryDate.IsZero() {
+		// Rejects entry dates in the future.
+		if entryDate.After(today.Time) {
+			return fmt.Errorf("entry_date cannot be in the future: %s", entryDate)
+		}
+		entry.EntryDate = *entryDate
+	}
+	if entry.EntryDate.IsZero() {
+		entry.EntryDate = today
+	}
+	return nil
+}
+
+/*
+ApplyTags function:
+
+1. Normalizes the tag names. If extractHashtags is true, the #hashtags in the content are added to them.
+Hashtags cannot be extracted from an encrypted entry, so ErrEncryptedEntry is returned.
+
+2. Executes FindOrCreateTags function, and sets the tags of the entry.
+*/
+func (entry *Entry) ApplyTags(names []string, extractHashtags bool) error {
+	// Normalizes the tag names.
+	if extractHashtags && entry.Encrypted {
+		return ErrEncryptedEntry
+	}
+	if extractHashtags {
+		names = append(append([]string{}, names...), ExtractHashtags(entry.Content)...)
+	}
+	normalized, err := NormalizeTagNames(names)
+	if err != nil {
+		return err
+	}
+
+	// Executes FindOrCreateTags function, and sets the tags of the entry.
+	tags, err := FindOrCreateTags(entry.UserID, normalized)
+	if err != nil {
+		return err
+	}
+	entry.Tags = tags
+	return nil
+}
+
+/*
+ApplyFields function:
+
+1. Executes ValidateEntryFields function, which rejects the values that do not match the fields the user has defined.
+
+2. Sets the fields of the entry.
+*/
+func (entry *Entry) ApplyFields(fields map[string]interface{}) error {
+	normalized, err := ValidateEntryFields(entry.UserID, fields)
+	if err != nil {
+		return err
+	}
+	entry.Fields = normalized
+	return nil
+}
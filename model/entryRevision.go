@@ -0,0 +1,197 @@
+package model
+
+import (
+	"diary_api/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+EntryRevision struct:
+
+1. EntryID
+
+2. Revision
+
+3. UserID
+
+4. Content
+
+5. EntryDate
+
+6. TimeZone
+
+7. Mood
+
+8. Fields
+
+9. Tags
+
+10. Format
+
+A revision is an immutable snapshot of an entry. One is created every time the entry is created or changed.
+*/
+type EntryRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	EntryID   uint      `gorm:"not null;uniqueIndex:idx_entry_revisions_entry_id_revision" json:"entry_id"`
+	// Revision is the number of the revision of the entry, starting from 1.
+	Revision int `gorm:"not null;uniqueIndex:idx_entry_revisions_entry_id_revision" json:"revision"`
+	// UserID is the user who made the change.
+	UserID  uint   `gorm:"not null;index" json:"user_id"`
+	Content string `gorm:"type:text" json:"content"`
+	// ContentKeyID is the data key the content is encrypted with. It is null if the content is plaintext.
+	ContentKeyID *uint `gorm:"index" json:"-"`
+	// plaintext keeps the content while it is encrypted for the insert(BeforeCreate).
+	plaintext string
+	EntryDate Date                   `json:"entry_date"`
+	TimeZone  string                 `gorm:"size:64" json:"time_zone"`
+	Mood      *int                   `json:"mood"`
+	Fields    map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"fields"`
+	// Tags are the names of the tags of the entry at the time of the revision.
+	Tags   []string `gorm:"serializer:json" json:"tags"`
+	Format string   `gorm:"size:16;not null;default:plain" json:"format"`
+}
+
+/*
+createEntryRevision function:
+
+1. Snapshots the entry as its next revision.
+
+It must be called in the transaction that changes the entry, after the entry has been written,
+so that the row lock of the entry orders concurrent revisions.
+*/
+func createEntryRevision(tx *gorm.DB, entry *Entry, userId uint) error {
+	var latest int
+	// SELECT COALESCE(MAX(revision), 0) FROM "entry_revisions" WHERE entry_id = $1$
+	if err := tx.Model(&EntryRevision{}).Select("COALESCE(MAX(revision), 0)").Where("entry_id = ?", entry.ID).Scan(&latest).Error; err != nil {
+		return err
+	}
+	tags := []string{}
+	for _, tag := range entry.Tags {
+		tags = append(tags, tag.Name)
+	}
+	revision := EntryRevision{
+		EntryID:   entry.ID,
+		Revision:  latest + 1,
+		UserID:    userId,
+		Content:   entry.Content,
+		EntryDate: entry.EntryDate,
+		TimeZone:  entry.TimeZone,
+		Mood:      entry.Mood,
+		Fields:    entry.Fields,
+		Tags:      tags,
+		Format:    entry.Format,
+	}
+	// INSERT INTO "entry_revisions" (...) VALUES (...) RETURNING "id"
+	return tx.Create(&revision).Error
+}
+
+/*
+BeforeCreate function:
+
+1. Encrypts the content with the data key of the user who made the change(sealContent), and sets ContentKeyID.
+A revision is never updated, so it is only encrypted when it is created.
+*/
+func (revision *EntryRevision) BeforeCreate(tx *gorm.DB) error {
+	revision.plaintext = revision.Content
+	content, dataKeyId, err := sealContent(tx, "entry_revisions", &revision.ID, revision.UserID, revision.Content)
+	if err != nil {
+		return err
+	}
+	revision.Content, revision.ContentKeyID = content, dataKeyId
+	return nil
+}
+
+/*
+AfterCreate function:
+
+1. Sets the content back to the plaintext, which BeforeCreate function has encrypted.
+*/
+func (revision *EntryRevision) AfterCreate(tx *gorm.DB) error {
+	revision.Content = revision.plaintext
+	return nil
+}
+
+/*
+AfterFind function:
+
+1. Executes DecryptContent function if the content is encrypted at rest(ContentKeyID).
+*/
+func (revision *EntryRevision) AfterFind(tx *gorm.DB) error {
+	content, err := DecryptContent("entry_revisions", revision.ID, revision.ContentKeyID, revision.Content)
+	if err != nil {
+		return err
+	}
+	revision.Content = content
+	return nil
+}
+
+/*
+FindEntryRevisions function:
+
+1. Queries the database to find the revisions of the entry, the newest first.
+*/
+func FindEntryRevisions(entryId uint) ([]EntryRevision, error) {
+	revisions := []EntryRevision{}
+	// SELECT * FROM "entry_revisions" WHERE entry_id = $1$ ORDER BY revision DESC
+	err := database.Database.Where("entry_id = ?", entryId).Order("revision DESC").Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+/*
+FindEntryRevision function:
+
+1. Queries the database to find the revision of the entry by its number.
+
+2. If the revision does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindEntryRevision(entryId uint, revision int) (EntryRevision, error) {
+	var entryRevision EntryRevision
+	// SELECT * FROM "entry_revisions" WHERE entry_id = $1$ AND revision = $2$ ORDER BY "entry_revisions"."id" LIMIT 1
+	err := database.Database.Where("entry_id = ? AND revision = ?", entryId, revision).First(&entryRevision).Error
+	if err != nil {
+		return EntryRevision{}, err
+	}
+	return entryRevision, nil
+}
+
+/*
+Restore function:
+
+1. Sets the content, the entry date, the time zone, the mood, the fields, the tags and the format of the entry to those of the revision.
+Tags that have been deleted since the revision are created again.
+
+The entry must be saved with (*Entry).Update function, which creates a new revision.
+*/
+func (revision *EntryRevision) Restore(entry *Entry) error {
+	tags, err := FindOrCreateTags(entry.UserID, revision.Tags)
+	if err != nil {
+		return err
+	}
+	entry.Content = revision.Content
+	entry.EntryDate = revision.EntryDate
+	entry.TimeZone = revision.TimeZone
+	entry.Mood = revision.Mood
+	entry.Fields = revision.Fields
+	entry.Tags = tags
+	entry.Format = revision.Format
+	return nil
+}
+
+/*
+BackfillEntryRevisions function:
+
+1. Creates the first revision of the entries written before revisions existed. Drafts get theirs when they are published.
+Only plaintext entries are copied(content_key_id IS NULL), because the content encrypted at rest is bound to its entry.
+*/
+func BackfillEntryRevisions() error {
+	return database.Database.Exec(`INSERT INTO entry_revisions (created_at, entry_id, revision, user_id, content, entry_date, time_zone, mood, fields, format, tags)
+SELECT entries.updated_at, entries.id, 1, entries.user_id, entries.content, entries.entry_date, entries.time_zone, entries.mood, entries.fields, entries.format,
+	COALESCE((SELECT json_agg(tags.name ORDER BY tags.name) FROM entry_tags JOIN tags ON tags.id = entry_tags.tag_id WHERE entry_tags.entry_id = entries.id), '[]')::text
+FROM entries WHERE entries.status <> ? AND entries.content_key_id IS NULL AND NOT EXISTS (SELECT 1 FROM entry_revisions WHERE entry_revisions.entry_id = entries.id)`, EntryStatusDraft).Error
+}
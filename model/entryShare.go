@@ -0,0 +1,305 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// sharePasswordFreeAttempts is the number of wrong passwords that lock the password of a share link for a minute.
+// Each wrong password after that locks it for twice as long, up to an hour.
+const sharePasswordFreeAttempts = 5
+
+// ErrEntryShareUnavailable is returned when a share link has been revoked, has expired or has no views left.
+var ErrEntryShareUnavailable = errors.New("the share link has expired or has been revoked")
+
+/*
+EntryShare struct:
+
+1. EntryID
+
+2. UserID
+
+3. Prefix
+
+4. TokenHash
+
+5. PasswordHash
+
+6. ExpiresAt
+
+7. MaxViews
+
+8. ViewCount
+
+9. RevokedAt
+
+10. FailedPasswordAttempts, PasswordLockedUntil
+
+A share is a public link(/s/:token) to a single entry. Revoked shares are kept, so that their views can still be seen.
+*/
+type EntryShare struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	EntryID uint `gorm:"not null;index" json:"entry_id"`
+	UserID  uint `gorm:"not null;index" json:"-"`
+	// Prefix is the first few characters of the token, so that the user can tell the links apart.
+	Prefix string `gorm:"size:32;not null" json:"prefix"`
+	// json:"-": Only the SHA-256 hash of the token is stored, and it is never returned in the JSON response.
+	TokenHash string `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	// PasswordHash is the bcrypt hash of the optional password of the link.
+	PasswordHash string `gorm:"size:255" json:"-"`
+	// PasswordProtected is computed from PasswordHash.
+	PasswordProtected bool       `gorm:"-" json:"password_protected"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	// MaxViews is optional. The link stops working after that many views.
+	MaxViews  *int       `json:"max_views"`
+	ViewCount int        `gorm:"not null;default:0" json:"view_count"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	// FailedPasswordAttempts counts the wrong passwords since the last right one.
+	// PasswordLockedUntil is set once there are sharePasswordFreeAttempts of them.
+	FailedPasswordAttempts int        `gorm:"not null;default:0" json:"-"`
+	PasswordLockedUntil    *time.Time `json:"-"`
+}
+
+/*
+EntryShareInput struct:
+
+1. ExpiresAt
+
+2. Password
+
+3. MaxViews
+
+Every field is optional. Without them, the link works until it is revoked.
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type EntryShareInput struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Password is limited to 72 bytes, the maximum bcrypt supports.
+	Password string `json:"password" binding:"omitempty,min=4,max=72"`
+	MaxViews *int   `json:"max_views" binding:"omitempty,min=1"`
+}
+
+/*
+SharedEntry struct:
+
+1. Content
+
+2. Format
+
+3. HTML
+
+4. EntryDate
+
+5. WordCount
+
+6. ReadingTimeMinutes
+
+SharedEntry is what the public link shows. It has no IDs of the entry or the user, no tags, no custom fields and no attachments.
+*/
+type SharedEntry struct {
+	Content            string `json:"content"`
+	Format             string `json:"format"`
+	HTML               string `json:"html"`
+	EntryDate          Date   `json:"entry_date"`
+	WordCount          int    `json:"word_count"`
+	ReadingTimeMinutes int    `json:"reading_time_minutes"`
+}
+
+/*
+AfterFind function:
+
+1. Sets PasswordProtected after the share is loaded.
+*/
+func (share *EntryShare) AfterFind(tx *gorm.DB) error {
+	share.PasswordProtected = share.PasswordHash != ""
+	return nil
+}
+
+/*
+SetPassword function:
+
+1. Executes hashPassword function, and sets the hash of the password of the link.
+*/
+func (share *EntryShare) SetPassword(password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	share.PasswordHash = passwordHash
+	share.PasswordProtected = true
+	return nil
+}
+
+/*
+ValidatePassword function:
+
+1. If the link has a password, compares it with the password that is sent.
+*/
+func (share *EntryShare) ValidatePassword(password string) error {
+	if share.PasswordHash == "" {
+		return nil
+	}
+	return bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password))
+}
+
+/*
+PasswordLockedFor function:
+
+1. Returns how long the password of the link is still locked after too many wrong passwords, or 0.
+*/
+func (share *EntryShare) PasswordLockedFor(now time.Time) time.Duration {
+	if share.PasswordLockedUntil == nil || !share.PasswordLockedUntil.After(now) {
+		return 0
+	}
+	return share.PasswordLockedUntil.Sub(now)
+}
+
+/*
+RecordFailedPassword function:
+
+1. Increments the number of wrong passwords of the link.
+
+2. Once it reaches sharePasswordFreeAttempts, locks the password for one minute, twice as long for each wrong password after that, up to an hour.
+Both are computed by the UPDATE itself, so that concurrent attempts on other instances of the app are counted too.
+*/
+func (share *EntryShare) RecordFailedPassword(now time.Time) error {
+	// UPDATE "entry_shares" SET "failed_password_attempts"=failed_password_attempts + 1,"password_locked_until"=CASE ... END WHERE id = $1$
+	return database.Database.Model(&EntryShare{}).Where("id = ?", share.ID).UpdateColumns(map[string]interface{}{
+		"failed_password_attempts": gorm.Expr("failed_password_attempts + 1"),
+		"password_locked_until": gorm.Expr(`CASE WHEN failed_password_attempts + 1 >= ?
+	THEN ?::timestamptz + LEAST(power(2, failed_password_attempts + 1 - ?) * interval '1 minute', interval '1 hour')
+	ELSE password_locked_until END`, sharePasswordFreeAttempts, now, sharePasswordFreeAttempts),
+	}).Error
+}
+
+/*
+ResetFailedPasswords function:
+
+1. Clears the wrong passwords of the link after the right one is sent.
+*/
+func (share *EntryShare) ResetFailedPasswords() error {
+	if share.FailedPasswordAttempts == 0 && share.PasswordLockedUntil == nil {
+		return nil
+	}
+	// UPDATE "entry_shares" SET "failed_password_attempts"=0,"password_locked_until"=NULL WHERE id = $1$
+	return database.Database.Model(&EntryShare{}).Where("id = ?", share.ID).UpdateColumns(map[string]interface{}{
+		"failed_password_attempts": 0,
+		"password_locked_until":    nil,
+	}).Error
+}
+
+/*
+IsAvailable function:
+
+1. Returns false if the link has been revoked, has expired or has no views left.
+*/
+func (share *EntryShare) IsAvailable() bool {
+	if share.RevokedAt != nil || (share.ExpiresAt != nil && !share.ExpiresAt.After(time.Now())) {
+		return false
+	}
+	return share.MaxViews == nil || share.ViewCount < *share.MaxViews
+}
+
+/*
+Save function:
+
+1. Inserts the share.
+*/
+func (share *EntryShare) Save() (*EntryShare, error) {
+	// INSERT INTO "entry_shares" (...) VALUES (...) RETURNING "id"
+	if err := database.Database.Create(share).Error; err != nil {
+		return &EntryShare{}, err
+	}
+	return share, nil
+}
+
+/*
+FindEntrySharesByEntryId function:
+
+1. Queries the database to find the shares of the entry, the newest first.
+*/
+func FindEntrySharesByEntryId(entryId uint) ([]EntryShare, error) {
+	shares := []EntryShare{}
+	// SELECT * FROM "entry_shares" WHERE entry_id = $1$ AND "entry_shares"."deleted_at" IS NULL ORDER BY id DESC
+	err := database.Database.Where("entry_id = ?", entryId).Order("id DESC").Find(&shares).Error
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+/*
+FindEntryShareByTokenHash function:
+
+1. Queries the database to find the share by the hash of its token.
+
+2. If the share does not exist, or its entry or its user no longer does(deleted, disabled or a draft again), gorm.ErrRecordNotFound is returned.
+*/
+func FindEntryShareByTokenHash(tokenHash string) (EntryShare, error) {
+	var share EntryShare
+	// SELECT entry_shares.* FROM "entry_shares" JOIN entries ... JOIN users ... WHERE entry_shares.token_hash = $1$ AND ... LIMIT 1
+	err := database.Database.
+		Joins("JOIN entries ON entries.id = entry_shares.entry_id AND entries.deleted_at IS NULL AND entries.status = ?", EntryStatusPublished).
+		Joins("JOIN users ON users.id = entry_shares.user_id AND users.deleted_at IS NULL AND users.disabled_at IS NULL").
+		Where("entry_shares.token_hash = ?", tokenHash).
+		First(&share).Error
+	if err != nil {
+		return EntryShare{}, err
+	}
+	return share, nil
+}
+
+/*
+RecordView function:
+
+1. Increments the view count, only if the link is still available.
+The condition is checked by the UPDATE itself, so that concurrent views cannot exceed MaxViews.
+
+2. If the link is no longer available, ErrEntryShareUnavailable is returned.
+*/
+func (share *EntryShare) RecordView() error {
+	// UPDATE "entry_shares" SET "view_count"=view_count + 1 WHERE id = $1$ AND revoked_at IS NULL AND ... AND "entry_shares"."deleted_at" IS NULL
+	result := database.Database.Model(&EntryShare{}).
+		Where("id = ? AND revoked_at IS NULL", share.ID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("max_views IS NULL OR view_count < max_views").
+		UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEntryShareUnavailable
+	}
+	share.ViewCount++
+	return nil
+}
+
+/*
+RevokeEntryShare function:
+
+1. Sets the revocation time of the share of the entry. Revoking a revoked share does nothing.
+
+2. If the share does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func RevokeEntryShare(entryId uint, id uint) error {
+	var share EntryShare
+	if err := database.Database.Where("id = ? AND entry_id = ?", id, entryId).First(&share).Error; err != nil {
+		return err
+	}
+	if share.RevokedAt != nil {
+		return nil
+	}
+	// UPDATE "entry_shares" SET "revoked_at"=$1$,"updated_at"=$2$ WHERE "id" = $3$
+	return database.Database.Model(&share).Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,290 @@
+package model
+
+import (
+	"diary_api/database"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Types of a custom field.
+const (
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeEnum    = "enum"
+	// FieldTypeDuration values are stored as whole seconds. They can be sent as seconds or as e.g. "1h30m".
+	FieldTypeDuration = "duration"
+)
+
+// fieldKeyPattern matches the key of a custom field, e.g. "sleep_hours".
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+/*
+FieldDefinition struct:
+
+1. UserID
+
+2. Key
+
+3. Label
+
+4. Type
+
+5. Options
+
+Each user defines their own custom fields. The values of the fields are stored in Entry.Fields by their key.
+Field definitions are deleted permanently, so that a deleted key can be used again.
+*/
+type FieldDefinition struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID uint   `gorm:"not null;uniqueIndex:idx_field_definitions_user_id_key" json:"user_id"`
+	Key    string `gorm:"size:32;not null;uniqueIndex:idx_field_definitions_user_id_key" json:"key"`
+	Label  string `gorm:"size:255;not null" json:"label"`
+	Type   string `gorm:"size:16;not null" json:"type"`
+	// Options are the allowed values of an enum field.
+	Options []string `gorm:"serializer:json" json:"options"`
+}
+
+/*
+FieldDefinitionInput struct:
+
+1. Key
+
+2. Label
+
+3. Type
+
+4. Options
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type FieldDefinitionInput struct {
+	Key     string   `json:"key" binding:"required"`
+	Label   string   `json:"label" binding:"required,max=255"`
+	Type    string   `json:"type" binding:"required,oneof=number boolean enum duration"`
+	Options []string `json:"options" binding:"max=50,dive,required,max=64"`
+}
+
+/*
+FieldDefinitionUpdateInput struct:
+
+1. Label
+
+2. Options
+
+The key and the type cannot be changed, because entries already have values of them.
+*/
+type FieldDefinitionUpdateInput struct {
+	Label   *string   `json:"label" binding:"omitempty,min=1,max=255"`
+	Options *[]string `json:"options" binding:"omitempty,max=50,dive,required,max=64"`
+}
+
+/*
+Validate function:
+
+1. Validates the key of the field.
+
+2. Requires options for enum fields, and rejects them for other fields.
+*/
+func (definition *FieldDefinition) Validate() error {
+	if !fieldKeyPattern.MatchString(definition.Key) {
+		return fmt.Errorf("invalid key: %q (use up to 32 lowercase letters, numbers or \"_\", starting with a letter)", definition.Key)
+	}
+	if definition.Type == FieldTypeEnum && len(definition.Options) == 0 {
+		return fmt.Errorf("enum field %q requires options", definition.Key)
+	}
+	if definition.Type != FieldTypeEnum && len(definition.Options) > 0 {
+		return fmt.Errorf("only enum fields can have options")
+	}
+	return nil
+}
+
+/*
+Save function:
+
+1. Executes (*FieldDefinition).Validate function.
+
+2. Inserts or updates the field definition.
+*/
+func (definition *FieldDefinition) Save() (*FieldDefinition, error) {
+	if err := definition.Validate(); err != nil {
+		return &FieldDefinition{}, err
+	}
+	// INSERT INTO "field_definitions" (...) VALUES (...) RETURNING "id"
+	if err := database.Database.Save(definition).Error; err != nil {
+		return &FieldDefinition{}, err
+	}
+	return definition, nil
+}
+
+/*
+NormalizeValue function:
+
+1. Validates the value against the type of the field.
+
+2. Returns the value as it is stored: a number, a boolean, one of the options or whole seconds.
+*/
+func (definition *FieldDefinition) NormalizeValue(value interface{}) (interface{}, error) {
+	switch definition.Type {
+	case FieldTypeNumber:
+		if number, ok := value.(float64); ok && !math.IsInf(number, 0) && !math.IsNaN(number) {
+			return number, nil
+		}
+	case FieldTypeBoolean:
+		if boolean, ok := value.(bool); ok {
+			return boolean, nil
+		}
+	case FieldTypeEnum:
+		if option, ok := value.(string); ok {
+			for _, allowed := range definition.Options {
+				if option == allowed {
+					return option, nil
+				}
+			}
+		}
+	case FieldTypeDuration:
+		switch v := value.(type) {
+		case float64:
+			if v >= 0 && v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		case string:
+			if duration, err := time.ParseDuration(v); err == nil && duration >= 0 {
+				return int64(duration / time.Second), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid value of %s field %q: %v", definition.Type, definition.Key, value)
+}
+
+/*
+ParseValue function:
+
+1. Parses a value sent as a string, e.g. in a query parameter.
+
+2. Executes (*FieldDefinition).NormalizeValue function.
+*/
+func (definition *FieldDefinition) ParseValue(value string) (interface{}, error) {
+	var parsed interface{} = value
+	switch definition.Type {
+	case FieldTypeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s field %q: %s", definition.Type, definition.Key, value)
+		}
+		parsed = number
+	case FieldTypeBoolean:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s field %q: %s", definition.Type, definition.Key, value)
+		}
+		parsed = boolean
+	case FieldTypeDuration:
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			parsed = seconds
+		}
+	}
+	return definition.NormalizeValue(parsed)
+}
+
+/*
+FindFieldDefinitionsByUserId function:
+
+1. Queries the database to find the field definitions of the user, ordered by key.
+*/
+func FindFieldDefinitionsByUserId(userId uint) ([]FieldDefinition, error) {
+	definitions := []FieldDefinition{}
+	// SELECT * FROM "field_definitions" WHERE user_id = $1$ AND "field_definitions"."deleted_at" IS NULL ORDER BY key
+	err := database.Database.Where("user_id = ?", userId).Order("key").Find(&definitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+/*
+FindFieldDefinitionById function:
+
+1. Queries the database to find the field definition of the user.
+
+2. If the field definition does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindFieldDefinitionById(userId uint, id uint) (FieldDefinition, error) {
+	var definition FieldDefinition
+	// SELECT * FROM "field_definitions" WHERE id = $1$ AND user_id = $2$ AND "field_definitions"."deleted_at" IS NULL ORDER BY "field_definitions"."id" LIMIT 1
+	err := database.Database.Where("id = ? AND user_id = ?", id, userId).First(&definition).Error
+	if err != nil {
+		return FieldDefinition{}, err
+	}
+	return definition, nil
+}
+
+/*
+DeleteFieldDefinition function:
+
+1. Permanently deletes the field definition of the user.
+The values already stored in entries are kept, but they are no longer validated or filterable.
+
+2. If the field definition does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func DeleteFieldDefinition(userId uint, id uint) error {
+	// DELETE FROM "field_definitions" WHERE id = $1$ AND user_id = $2$
+	result := database.Database.Unscoped().Where("id = ? AND user_id = ?", id, userId).Delete(&FieldDefinition{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+ValidateEntryFields function:
+
+1. Rejects the keys that the user has not defined.
+
+2. Executes (*FieldDefinition).NormalizeValue function on every value. A null value removes the field.
+
+3. Returns the fields as they are stored.
+*/
+func ValidateEntryFields(userId uint, fields map[string]interface{}) (map[string]interface{}, error) {
+	normalized := map[string]interface{}{}
+	if len(fields) == 0 {
+		return normalized, nil
+	}
+	definitions, err := FindFieldDefinitionsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	definitionsByKey := map[string]FieldDefinition{}
+	for _, definition := range definitions {
+		definitionsByKey[definition.Key] = definition
+	}
+
+	for key, value := range fields {
+		// Rejects the keys that the user has not defined.
+		definition, ok := definitionsByKey[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown field: %q", key)
+		}
+		if value == nil {
+			continue
+		}
+		// Executes (*FieldDefinition).NormalizeValue function on every value.
+		if normalized[key], err = definition.NormalizeValue(value); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,286 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"diary_api/database"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Formats of the files that can be imported.
+const (
+	// ImportFormatDayOne is the JSON export of Day One, alone or in a ZIP with its photos.
+	ImportFormatDayOne = "dayone"
+	// ImportFormatMarkdown is a ZIP of Markdown files with front matter, e.g. GET /api/entry/export?format=md.
+	ImportFormatMarkdown = "markdown"
+	// ImportFormatJSON is the JSON export of this app, GET /api/entry/export?format=json or GET /api/account/export.
+	ImportFormatJSON = "json"
+)
+
+// Statuses of an import job.
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// defaultImportMaxSize is the maximum size of an imported file in bytes if IMPORT_MAX_SIZE is not set.
+const defaultImportMaxSize = 512 << 20
+
+// maxImportItemErrors is the number of item errors kept on a job. Failed still counts every failed item.
+const maxImportItemErrors = 1000
+
+// importStaleAfter is how long a running job can go without progress before another worker takes it over, e.g. after a crash.
+// Taking over is safe, because the entries that were already imported are skipped as duplicates.
+const importStaleAfter = 15 * time.Minute
+
+/*
+ImportItemError struct:
+
+1. Item
+
+2. Error
+*/
+type ImportItemError struct {
+	// Item names the item in the file, e.g. the file name in a ZIP or the index of an entry in a JSON file.
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
+/*
+ImportJob struct:
+
+1. UserID
+
+2. JournalID
+
+3. Format
+
+4. FileName
+
+5. StorageKey
+
+6. Status
+
+7. Total, Processed, Imported, Skipped, Failed
+
+8. Errors
+
+9. Error
+
+10. StartedAt, FinishedAt
+
+The uploaded file is stored in the blob store(storage.Blobs) at StorageKey until the job is finished.
+*/
+type ImportJob struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID uint `gorm:"not null;index" json:"-"`
+	// JournalID is the journal the entries are imported into. Without it, they become personal entries.
+	JournalID  *uint  `json:"journal_id"`
+	Format     string `gorm:"size:16;not null" json:"format"`
+	FileName   string `gorm:"size:255;not null" json:"file_name"`
+	StorageKey string `gorm:"size:255;not null" json:"-"`
+	Status     string `gorm:"size:16;not null;default:pending;index" json:"status"`
+	// Total is the number of items in the file, known once the file has been read.
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Imported  int `json:"imported"`
+	// Skipped are the duplicates of existing entries, and the deleted entries of an account export.
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Errors  []ImportItemError `gorm:"type:jsonb;serializer:json" json:"errors"`
+	// Error is why the whole job failed, e.g. the file is not in the format.
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+/*
+ImportMaxSize function:
+
+1. Returns the maximum size of an imported file in bytes, set by IMPORT_MAX_SIZE.
+*/
+func ImportMaxSize() int64 {
+	maxSize, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_SIZE"), 10, 64)
+	if err != nil || maxSize <= 0 {
+		return defaultImportMaxSize
+	}
+	return maxSize
+}
+
+/*
+NewImportStorageKey function:
+
+1. Returns a random key of the blob of an imported file of the user, e.g. "imports/1/5f2b...".
+*/
+func NewImportStorageKey(userId uint) (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("imports/%d/%s", userId, hex.EncodeToString(randomBytes)), nil
+}
+
+/*
+Save function:
+
+1. Inserts the job as pending.
+*/
+func (job *ImportJob) Save() (*ImportJob, error) {
+	job.Status = ImportStatusPending
+	job.Errors = []ImportItemError{}
+	// INSERT INTO "import_jobs" (...) VALUES (...) RETURNING "id"
+	if err := database.Database.Create(job).Error; err != nil {
+		return &ImportJob{}, err
+	}
+	return job, nil
+}
+
+/*
+FindImportJobsByUserId function:
+
+1. Queries the database to find the import jobs of the user, the newest first.
+*/
+func FindImportJobsByUserId(userId uint) ([]ImportJob, error) {
+	jobs := []ImportJob{}
+	// SELECT * FROM "import_jobs" WHERE user_id = $1$ AND "import_jobs"."deleted_at" IS NULL ORDER BY id DESC
+	err := database.Database.Where("user_id = ?", userId).Order("id DESC").Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+/*
+FindImportJob function:
+
+1. Queries the database to find the import job of the user.
+
+2. If the job does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindImportJob(userId uint, id uint) (ImportJob, error) {
+	var job ImportJob
+	// SELECT * FROM "import_jobs" WHERE id = $1$ AND user_id = $2$ AND "import_jobs"."deleted_at" IS NULL ORDER BY "import_jobs"."id" LIMIT 1
+	err := database.Database.Where("id = ? AND user_id = ?", id, userId).First(&job).Error
+	if err != nil {
+		return ImportJob{}, err
+	}
+	return job, nil
+}
+
+/*
+ClaimImportJob function:
+
+1. Marks the oldest pending job as running, skipping the ones other workers are claiming, and returns it.
+A running job that has made no progress for importStaleAfter is claimed again.
+
+2. Resets the progress of the job, because it is counted again from the start of the file.
+
+3. Returns whether a job was claimed.
+
+The job is not locked while it runs, so that its progress can be saved and polled.
+*/
+func ClaimImportJob() (ImportJob, bool, error) {
+	var jobs []ImportJob
+	now := time.Now()
+	// UPDATE import_jobs SET status = 'running', ... WHERE id = (SELECT id FROM import_jobs WHERE ... ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *
+	err := database.Database.Raw(`UPDATE import_jobs SET status = ?, started_at = ?, updated_at = ?,
+total = 0, processed = 0, imported = 0, skipped = 0, failed = 0, errors = '[]', error = ''
+WHERE id = (SELECT id FROM import_jobs WHERE deleted_at IS NULL AND (status = ? OR (status = ? AND updated_at < ?))
+ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *`,
+		ImportStatusRunning, now, now, ImportStatusPending, ImportStatusRunning, now.Add(-importStaleAfter)).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return ImportJob{}, false, err
+	}
+	return jobs[0], true, nil
+}
+
+/*
+AddError function:
+
+1. Counts the item as failed, and keeps its error unless maxImportItemErrors errors are kept already.
+*/
+func (job *ImportJob) AddError(item string, err error) {
+	job.Failed++
+	if len(job.Errors) < maxImportItemErrors {
+		job.Errors = append(job.Errors, ImportItemError{Item: item, Error: err.Error()})
+	}
+}
+
+/*
+SaveProgress function:
+
+1. Updates the counts and the errors of the job. It also shows other workers that the job is still running.
+*/
+func (job *ImportJob) SaveProgress() error {
+	// UPDATE "import_jobs" SET "updated_at"=$1$,"total"=$2$,"processed"=$3$,"imported"=$4$,"skipped"=$5$,"failed"=$6$,"errors"=$7$ WHERE "id" = $8$
+	return database.Database.Model(job).Select("total", "processed", "imported", "skipped", "failed", "errors").Updates(job).Error
+}
+
+/*
+Finish function:
+
+1. Sets the status of the job to completed, or to failed with the error(err) that stopped it.
+
+2. Updates the job with its final counts.
+*/
+func (job *ImportJob) Finish(err error) error {
+	now := time.Now()
+	job.Status = ImportStatusCompleted
+	if err != nil {
+		job.Status = ImportStatusFailed
+		job.Error = err.Error()
+	}
+	job.FinishedAt = &now
+	// UPDATE "import_jobs" SET "updated_at"=$1$,"status"=$2$,...,"finished_at"=$10$ WHERE "id" = $11$
+	return database.Database.Model(job).
+		Select("status", "total", "processed", "imported", "skipped", "failed", "errors", "error", "finished_at").Updates(job).Error
+}
+
+/*
+ImportContentHash function:
+
+1. Returns the hex-encoded SHA-256 of the content, ignoring the line endings and the spaces around it,
+so that an entry imported twice, or already written in this app, is recognized.
+*/
+func ImportContentHash(content string) string {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+FindEntryContentHashes function:
+
+1. Queries the database to find the content of the entries the user wrote on the date, in the journal or among their personal entries.
+
+2. Returns the set of their content hashes(ImportContentHash). The hashes are computed here, so that only the entries of one date are read at once.
+*/
+func FindEntryContentHashes(userId uint, journalId *uint, date Date) (map[string]bool, error) {
+	var entries []Entry
+	query := database.Database.Model(&Entry{}).Where("user_id = ? AND entry_date = ?", userId, date)
+	if journalId != nil {
+		query = query.Where("journal_id = ?", *journalId)
+	} else {
+		query = query.Where("journal_id IS NULL")
+	}
+	// SELECT id, content, content_key_id FROM "entries" WHERE user_id = $1$ AND entry_date = $2$ AND journal_id IS NULL AND "entries"."deleted_at" IS NULL
+	if err := query.Select("id", "content", "content_key_id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	hashes := map[string]bool{}
+	for _, entry := range entries {
+		hashes[ImportContentHash(entry.Content)] = true
+	}
+	return hashes, nil
+}
@@ -0,0 +1,414 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Roles of a member of a journal, from the most to the least powerful.
+const (
+	// JournalRoleOwner can do everything an editor can, manage the members, share the entries publicly and delete the journal.
+	JournalRoleOwner = "owner"
+	// JournalRoleEditor can read and write the entries of the journal.
+	JournalRoleEditor = "editor"
+	// JournalRoleViewer can only read the entries of the journal.
+	JournalRoleViewer = "viewer"
+)
+
+// journalRoleRanks orders the roles, so that a role can be compared with the role an action requires.
+var journalRoleRanks = map[string]int{JournalRoleViewer: 1, JournalRoleEditor: 2, JournalRoleOwner: 3}
+
+var (
+	// ErrJournalMemberExists is returned when the invited user is already a member of the journal, or has already been invited.
+	ErrJournalMemberExists = errors.New("the user is already a member of the journal or has already been invited")
+	// ErrJournalOwner is returned when the owner of a journal would be removed or demoted. The owner can delete the journal instead.
+	ErrJournalOwner = errors.New("the owner of the journal cannot be removed or change role")
+)
+
+/*
+Journal struct:
+
+1. Name
+
+2. Role
+
+A journal is shared by its members(JournalMember). The entries whose JournalID is set belong to the journal,
+and the other entries are personal: only their author can see them.
+*/
+type Journal struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	Name string `gorm:"size:255;not null" json:"name"`
+	// Role is the role of the current user in the journal. It is only read, and is not a column.
+	// FYI: https://gorm.io/docs/models.html#Field-Level-Permission
+	Role string `gorm:"->;-:migration" json:"role,omitempty"`
+}
+
+/*
+JournalMember struct:
+
+1. JournalID
+
+2. UserID
+
+3. Role
+
+4. InvitedByID
+
+5. AcceptedAt
+
+6. Username
+
+A member is invited by the username, and can see the journal once they have accepted the invitation(AcceptedAt).
+*/
+type JournalMember struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	JournalID uint      `gorm:"not null;uniqueIndex:idx_journal_members_journal_id_user_id" json:"journal_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_journal_members_journal_id_user_id;index" json:"user_id"`
+	Role      string    `gorm:"size:16;not null" json:"role"`
+	// InvitedByID is the member who invited the user. It is 0 for the owner, who created the journal.
+	InvitedByID uint       `json:"invited_by_id"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	// Username is the username of the member. It is only read, and is not a column.
+	Username string `gorm:"->;-:migration" json:"username"`
+	// JournalName is the name of the journal, for the invitations of a user. It is only read, and is not a column.
+	JournalName string `gorm:"->;-:migration" json:"journal_name,omitempty"`
+}
+
+/*
+HasJournalRole function:
+
+1. Returns whether the role(role) allows what the required role(required) allows, e.g. an owner can do what an editor can.
+
+2. An empty role, i.e. no access at all, allows nothing.
+*/
+func HasJournalRole(role string, required string) bool {
+	return role != "" && journalRoleRanks[role] >= journalRoleRanks[required]
+}
+
+/*
+Create function:
+
+1. Inserts the journal, and the user(ownerId) as its owner, in one transaction.
+*/
+func (journal *Journal) Create(ownerId uint) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		// INSERT INTO "journals" (...) VALUES (...) RETURNING "id"
+		if err := tx.Create(journal).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		owner := JournalMember{JournalID: journal.ID, UserID: ownerId, Role: JournalRoleOwner, AcceptedAt: &now}
+		// INSERT INTO "journal_members" (...) VALUES (...) RETURNING "id"
+		if err := tx.Create(&owner).Error; err != nil {
+			return err
+		}
+		journal.Role = JournalRoleOwner
+		return nil
+	})
+}
+
+/*
+Rename function:
+
+1. Updates the name of the journal.
+*/
+func (journal *Journal) Rename(name string) error {
+	// UPDATE "journals" SET "name"=$1$,"updated_at"=$2$ WHERE "journals"."deleted_at" IS NULL AND "id" = $3$
+	return database.Database.Model(journal).Update("name", name).Error
+}
+
+/*
+FindJournalsByUserId function:
+
+1. Queries the database to find the journals the user is a member of, with the role of the user. Invitations that are not accepted are excluded.
+*/
+func FindJournalsByUserId(userId uint) ([]Journal, error) {
+	journals := []Journal{}
+	// SELECT journals.*, journal_members.role FROM "journals" JOIN journal_members ON ... WHERE ... ORDER BY journals.id
+	err := database.Database.Select("journals.*, journal_members.role").
+		Joins("JOIN journal_members ON journal_members.journal_id = journals.id").
+		Where("journal_members.user_id = ? AND journal_members.accepted_at IS NOT NULL", userId).
+		Order("journals.id").Find(&journals).Error
+	if err != nil {
+		return nil, err
+	}
+	return journals, nil
+}
+
+/*
+FindJournal function:
+
+1. Queries the database to find the journal with the role of the user.
+
+2. If the journal does not exist, or the user is not a member of it, gorm.ErrRecordNotFound is returned.
+*/
+func FindJournal(id uint, userId uint) (Journal, error) {
+	var journal Journal
+	// SELECT journals.*, journal_members.role FROM "journals" JOIN journal_members ON ... WHERE journals.id = $1$ AND ... LIMIT 1
+	err := database.Database.Select("journals.*, journal_members.role").
+		Joins("JOIN journal_members ON journal_members.journal_id = journals.id").
+		Where("journals.id = ? AND journal_members.user_id = ? AND journal_members.accepted_at IS NOT NULL", id, userId).
+		First(&journal).Error
+	if err != nil {
+		return Journal{}, err
+	}
+	return journal, nil
+}
+
+/*
+FindJournalRole function:
+
+1. Returns the role of the user in the journal, or an empty string if the user is not a member or has not accepted the invitation.
+*/
+func FindJournalRole(journalId uint, userId uint) (string, error) {
+	var roles []string
+	// SELECT journal_members.role FROM "journal_members" JOIN journals ON ... WHERE ... LIMIT 1
+	err := database.Database.Model(&JournalMember{}).
+		Joins("JOIN journals ON journals.id = journal_members.journal_id AND journals.deleted_at IS NULL").
+		Where("journal_members.journal_id = ? AND journal_members.user_id = ? AND journal_members.accepted_at IS NOT NULL", journalId, userId).
+		Limit(1).Pluck("journal_members.role", &roles).Error
+	if err != nil || len(roles) == 0 {
+		return "", err
+	}
+	return roles[0], nil
+}
+
+/*
+FindEntryRole function:
+
+1. Returns the role of the user for the entry, or an empty string if the user cannot see it.
+
+2. The author of a personal entry is its owner. The role for an entry of a journal is the role of the user in the journal.
+
+3. Drafts are only seen by their author, even in a journal.
+*/
+func FindEntryRole(entry *Entry, userId uint) (string, error) {
+	if entry.Status == EntryStatusDraft && entry.UserID != userId {
+		return "", nil
+	}
+	if entry.JournalID == nil {
+		if entry.UserID == userId {
+			return JournalRoleOwner, nil
+		}
+		return "", nil
+	}
+	return FindJournalRole(*entry.JournalID, userId)
+}
+
+/*
+FindJournalMembers function:
+
+1. Queries the database to find the members of the journal with their usernames, including the invited users who have not accepted yet.
+*/
+func FindJournalMembers(journalId uint) ([]JournalMember, error) {
+	members := []JournalMember{}
+	// SELECT journal_members.*, users.username FROM "journal_members" JOIN users ON ... WHERE journal_members.journal_id = $1$ ORDER BY journal_members.id
+	err := database.Database.Select("journal_members.*, users.username").
+		Joins("JOIN users ON users.id = journal_members.user_id").
+		Where("journal_members.journal_id = ?", journalId).
+		Order("journal_members.id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+/*
+InviteJournalMember function:
+
+1. Queries the database to find the user by the username.
+If the user does not exist, gorm.ErrRecordNotFound is returned.
+
+2. Inserts the invitation. If the user is already a member or has already been invited, ErrJournalMemberExists is returned.
+*/
+func InviteJournalMember(journalId uint, invitedById uint, username string, role string) (JournalMember, error) {
+	user, err := FindUserByUsername(username)
+	if err != nil {
+		return JournalMember{}, err
+	}
+	if user.ID == 0 {
+		return JournalMember{}, gorm.ErrRecordNotFound
+	}
+	member := JournalMember{JournalID: journalId, UserID: user.ID, Role: role, InvitedByID: invitedById, Username: user.Username}
+	// INSERT INTO "journal_members" (...) VALUES (...) ON CONFLICT DO NOTHING RETURNING "id"
+	result := database.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(&member)
+	if result.Error != nil {
+		return JournalMember{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return JournalMember{}, ErrJournalMemberExists
+	}
+	return member, nil
+}
+
+/*
+FindJournalInvitations function:
+
+1. Queries the database to find the invitations of the user that have not been accepted, with the names of the journals.
+*/
+func FindJournalInvitations(userId uint) ([]JournalMember, error) {
+	invitations := []JournalMember{}
+	// SELECT journal_members.*, journals.name AS journal_name FROM "journal_members" JOIN journals ON ... WHERE ... ORDER BY journal_members.id
+	err := database.Database.Select("journal_members.*, journals.name AS journal_name").
+		Joins("JOIN journals ON journals.id = journal_members.journal_id AND journals.deleted_at IS NULL").
+		Where("journal_members.user_id = ? AND journal_members.accepted_at IS NULL", userId).
+		Order("journal_members.id").Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+/*
+AcceptJournalInvitation function:
+
+1. Sets the time the user accepted the invitation to the journal.
+
+2. If the user has not been invited, or has already accepted, gorm.ErrRecordNotFound is returned.
+*/
+func AcceptJournalInvitation(journalId uint, userId uint) error {
+	// UPDATE "journal_members" SET "accepted_at"=$1$,"updated_at"=$2$ WHERE journal_id = $3$ AND user_id = $4$ AND accepted_at IS NULL
+	result := database.Database.Model(&JournalMember{}).
+		Where("journal_id = ? AND user_id = ? AND accepted_at IS NULL", journalId, userId).
+		Update("accepted_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+UpdateJournalMemberRole function:
+
+1. Changes the role of the member(userId) of the journal. The owner cannot change role, ErrJournalOwner is returned.
+
+2. If the user is not a member, gorm.ErrRecordNotFound is returned.
+*/
+func UpdateJournalMemberRole(journalId uint, userId uint, role string) (JournalMember, error) {
+	var member JournalMember
+	if err := database.Database.Where("journal_id = ? AND user_id = ?", journalId, userId).First(&member).Error; err != nil {
+		return JournalMember{}, err
+	}
+	if member.Role == JournalRoleOwner {
+		return JournalMember{}, ErrJournalOwner
+	}
+	// UPDATE "journal_members" SET "role"=$1$,"updated_at"=$2$ WHERE "id" = $3$
+	if err := database.Database.Model(&member).Update("role", role).Error; err != nil {
+		return JournalMember{}, err
+	}
+	return member, nil
+}
+
+/*
+RemoveJournalMember function:
+
+1. Deletes the membership or the invitation of the user(userId). Members leave and invitations are declined the same way.
+The owner cannot be removed, ErrJournalOwner is returned.
+
+2. The entries the member wrote stay in the journal.
+
+3. If the user is not a member, gorm.ErrRecordNotFound is returned.
+*/
+func RemoveJournalMember(journalId uint, userId uint) error {
+	// DELETE FROM "journal_members" WHERE journal_id = $1$ AND user_id = $2$ AND role <> 'owner'
+	result := database.Database.Where("journal_id = ? AND user_id = ? AND role <> ?", journalId, userId, JournalRoleOwner).Delete(&JournalMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		database.Database.Model(&JournalMember{}).Where("journal_id = ? AND user_id = ?", journalId, userId).Count(&count)
+		if count > 0 {
+			return ErrJournalOwner
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+DeleteJournal function:
+
+1. Gives the entries of the journal back to their authors as personal entries, and revokes their public links.
+
+2. Deletes the members and the journal in the same transaction.
+*/
+func DeleteJournal(id uint) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		return deleteJournal(tx, id)
+	})
+}
+
+/*
+deleteJournal function:
+
+1. Executes DeleteJournal function in the transaction(tx).
+*/
+func deleteJournal(tx *gorm.DB, id uint) error {
+	journalEntries := tx.Unscoped().Model(&Entry{}).Select("id").Where("journal_id = ?", id)
+	// UPDATE "entry_shares" SET "revoked_at"=$1$ WHERE entry_id IN (SELECT id FROM "entries" WHERE journal_id = $2$) AND revoked_at IS NULL
+	if err := tx.Model(&EntryShare{}).Where("entry_id IN (?) AND revoked_at IS NULL", journalEntries).
+		UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	// UPDATE "entries" SET "journal_id"=NULL WHERE journal_id = $1$
+	if err := tx.Unscoped().Model(&Entry{}).Where("journal_id = ?", id).UpdateColumn("journal_id", nil).Error; err != nil {
+		return err
+	}
+	// DELETE FROM "journal_members" WHERE journal_id = $1$
+	if err := tx.Where("journal_id = ?", id).Delete(&JournalMember{}).Error; err != nil {
+		return err
+	}
+	// DELETE FROM "journals" WHERE "journals"."id" = $1$
+	return tx.Unscoped().Delete(&Journal{}, id).Error
+}
+
+/*
+handOverJournals function:
+
+1. For each journal the user owns, makes the member who has been there the longest the owner, editors before viewers.
+
+2. Deletes the journals that have no other member, as DeleteJournal function does.
+
+3. Deletes the memberships and the invitations of the user.
+
+It is called in the transaction that hard deletes the user, so that the shared journals outlive the account of their owner.
+*/
+func handOverJournals(tx *gorm.DB, userId uint) error {
+	var journalIds []uint
+	// SELECT journal_id FROM "journal_members" WHERE user_id = $1$ AND role = 'owner'
+	if err := tx.Model(&JournalMember{}).Where("user_id = ? AND role = ?", userId, JournalRoleOwner).Pluck("journal_id", &journalIds).Error; err != nil {
+		return err
+	}
+	for _, journalId := range journalIds {
+		var successor JournalMember
+		// SELECT * FROM "journal_members" WHERE journal_id = $1$ AND user_id <> $2$ AND accepted_at IS NOT NULL ORDER BY CASE role WHEN 'editor' THEN 0 ELSE 1 END, accepted_at, id LIMIT 1
+		result := tx.Where("journal_id = ? AND user_id <> ? AND accepted_at IS NOT NULL", journalId, userId).
+			Order("CASE role WHEN '" + JournalRoleEditor + "' THEN 0 ELSE 1 END, accepted_at, id").Limit(1).Find(&successor)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := deleteJournal(tx, journalId); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Model(&successor).Update("role", JournalRoleOwner).Error; err != nil {
+			return err
+		}
+	}
+	// DELETE FROM "journal_members" WHERE user_id = $1$
+	return tx.Where("user_id = ?", userId).Delete(&JournalMember{}).Error
+}
@@ -0,0 +1,39 @@
+package model
+
+/*
+JournalInput struct:
+
+1. Name
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type JournalInput struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+/*
+JournalMemberInput struct:
+
+1. Username
+
+2. Role
+
+A journal has a single owner, so members are invited as editors or viewers.
+*/
+type JournalMemberInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+/*
+JournalMemberRoleInput struct:
+
+1. Role
+*/
+type JournalMemberRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=editor viewer"`
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"time"
+)
+
+/*
+OIDCLoginState struct:
+
+1. State
+
+2. Provider
+
+3. Nonce
+
+4. CodeVerifier
+
+5. CookieMode
+
+6. LinkUserID
+
+7. BrowserBindingHash
+
+8. ExpiresAt
+
+A login state is stored by /auth/oidc/:provider/start(or POST /api/identities/:provider) and consumed once by /auth/oidc/:provider/callback.
+*/
+type OIDCLoginState struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	State     string `gorm:"size:64;not null;uniqueIndex"`
+	Provider  string `gorm:"size:64;not null"`
+	Nonce     string `gorm:"size:64;not null"`
+	// CodeVerifier is the PKCE code verifier.
+	// FYI: https://www.rfc-editor.org/rfc/rfc7636
+	CodeVerifier string `gorm:"size:128;not null"`
+	// CookieMode is true if the JWT is set in a cookie after the callback.
+	CookieMode bool
+	// LinkUserID is set when a logged-in user links the identity to their account, instead of logging in with it.
+	LinkUserID *uint
+	// BrowserBindingHash is the SHA-256 hash of the cookie set in the browser that started the flow.
+	BrowserBindingHash string    `gorm:"size:64;not null"`
+	ExpiresAt          time.Time `gorm:"not null;index"`
+}
+
+/*
+TableName function:
+
+1. Overrides the table name, which GORM would otherwise derive as "o_id_c_login_states".
+
+FYI: https://gorm.io/docs/conventions.html#TableName
+*/
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+/*
+Save function:
+
+1. Passes the address of the pointer variable(loginState) to (*gorm.DB).Create function.
+
+2. If (*gorm.DB).Create function is successfully executed, it returns the address of the pointer variable(loginState) and nil.
+*/
+func (loginState *OIDCLoginState) Save() (*OIDCLoginState, error) {
+	// INSERT INTO "oidc_login_states" ("created_at","state","provider","nonce","code_verifier","cookie_mode","link_user_id","browser_binding_hash","expires_at") VALUES (...) RETURNING "id"
+	err := database.Database.Create(loginState).Error
+	if err != nil {
+		return &OIDCLoginState{}, err
+	}
+	return loginState, nil
+}
+
+/*
+ConsumeOIDCLoginState function:
+
+1. Deletes the login state with the state and the provider, and returns the deleted row.
+
+2. If no unexpired login state matches, an error is returned.
+
+Deleting it first makes sure a state can only be used once.
+*/
+func ConsumeOIDCLoginState(state string, provider string) (OIDCLoginState, error) {
+	var loginStates []OIDCLoginState
+	// DELETE FROM "oidc_login_states" WHERE state=$1$ AND provider=$2$ RETURNING *
+	err := database.Database.Raw(
+		`DELETE FROM oidc_login_states WHERE state = ? AND provider = ? RETURNING *`,
+		state, provider,
+	).Scan(&loginStates).Error
+	if err != nil {
+		return OIDCLoginState{}, err
+	}
+	if len(loginStates) == 0 || loginStates[0].ExpiresAt.Before(time.Now()) {
+		return OIDCLoginState{}, errors.New("login state is invalid or has expired")
+	}
+	return loginStates[0], nil
+}
+
+/*
+DeleteExpiredOIDCLoginStates function:
+
+1. Deletes the login states that have expired without being used.
+*/
+func DeleteExpiredOIDCLoginStates() error {
+	// DELETE FROM "oidc_login_states" WHERE expires_at < $1$
+	return database.Database.Where("expires_at < ?", time.Now()).Delete(&OIDCLoginState{}).Error
+}
@@ -0,0 +1,22 @@
+package model
+
+/*
+PasswordResetInput struct:
+
+1. Username
+
+2. Password
+
+3. NewPassword
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type PasswordResetInput struct {
+	Username    string `json:"username" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes that can be granted to a personal access token.
+const (
+	ScopeEntriesRead  = "entries:read"
+	ScopeEntriesWrite = "entries:write"
+)
+
+// PersonalAccessTokenScopes is the list of scopes that can be granted to a personal access token.
+var PersonalAccessTokenScopes = []string{
+	ScopeEntriesRead,
+	ScopeEntriesWrite,
+}
+
+/*
+PersonalAccessToken struct:
+
+1. UserID
+
+2. Name
+
+3. Prefix
+
+4. TokenHash
+
+5. Scopes
+
+6. ExpiresAt
+
+7. LastUsedAt
+*/
+type PersonalAccessToken struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID uint   `gorm:"not null;index" json:"user_id"`
+	Name   string `gorm:"size:255;not null" json:"name"`
+	// Prefix is the first few characters of the token, so that the user can tell the tokens apart.
+	Prefix string `gorm:"size:32;not null" json:"prefix"`
+	// json:"-": Only the SHA-256 hash of the token is stored, and it is never returned in the JSON response.
+	TokenHash string `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	// The scopes are stored as a JSON array.
+	// FYI: https://gorm.io/docs/serializer.html
+	Scopes     []string   `gorm:"serializer:json;not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+/*
+Save function:
+
+1. Passes the address of the pointer variable(token) to (*gorm.DB).Create function.
+
+2. If (*gorm.DB).Create function is successfully executed, it returns the address of the pointer variable(token) and nil.
+
+FYI: https://gorm.io/docs/create.html
+*/
+func (token *PersonalAccessToken) Save() (*PersonalAccessToken, error) {
+	// Passes the address of the pointer variable(token) to (*gorm.DB).Create function.
+	// INSERT INTO "personal_access_tokens" ("created_at","updated_at","deleted_at","user_id","name","prefix","token_hash","scopes","expires_at","last_used_at") VALUES (...) RETURNING "id"
+	err := database.Database.Create(token).Error
+	if err != nil {
+		// If (*gorm.DB).Create function fails to execute,
+		// it returns the address of empty struct and an error.
+		return &PersonalAccessToken{}, err
+	}
+	// If (*gorm.DB).Create function is successfully executed,
+	// it returns the address of the pointer variable(token) and nil.
+	return token, nil
+}
+
+/*
+HasScope function:
+
+1. Returns true if the token has been granted the scope.
+*/
+func (token *PersonalAccessToken) HasScope(scope string) bool {
+	for _, granted := range token.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+IsExpired function:
+
+1. Returns true if the expiry of the token has passed.
+*/
+func (token *PersonalAccessToken) IsExpired() bool {
+	return token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now())
+}
+
+/*
+Touch function:
+
+1. Updates last_used_at at most once a minute, so that every request does not cause a write.
+*/
+func (token *PersonalAccessToken) Touch() error {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < time.Minute {
+		return nil
+	}
+	token.LastUsedAt = &now
+	// UPDATE "personal_access_tokens" SET "last_used_at"=$1$ WHERE "id" = $2$
+	return database.Database.Model(token).UpdateColumn("last_used_at", now).Error
+}
+
+/*
+ValidatePersonalAccessTokenScopes function:
+
+1. Returns an error if the scopes are empty or include an unknown scope.
+*/
+func ValidatePersonalAccessTokenScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, candidate := range PersonalAccessTokenScopes {
+			if scope == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+/*
+FindPersonalAccessTokenByHash function:
+
+1. Queries the database to find the token with the matching hash.
+
+2. If no token matches, an error is returned.
+*/
+func FindPersonalAccessTokenByHash(tokenHash string) (PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	// SELECT * FROM "personal_access_tokens" WHERE token_hash=$1$ AND "personal_access_tokens"."deleted_at" IS NULL
+	err := database.Database.Where("token_hash=?", tokenHash).Find(&token).Error
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+	if token.ID == 0 {
+		return PersonalAccessToken{}, errors.New("invalid personal access token")
+	}
+	return token, nil
+}
+
+/*
+FindPersonalAccessTokensByUserId function:
+
+1. Queries the database to find the tokens of the user.
+*/
+func FindPersonalAccessTokensByUserId(userId uint) ([]PersonalAccessToken, error) {
+	var tokens []PersonalAccessToken
+	// SELECT * FROM "personal_access_tokens" WHERE user_id=$1$ AND "personal_access_tokens"."deleted_at" IS NULL ORDER BY id
+	err := database.Database.Where("user_id=?", userId).Order("id").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+/*
+DeletePersonalAccessToken function:
+
+1. Deletes the token of the user.
+
+2. If the token does not exist or belongs to another user, gorm.ErrRecordNotFound is returned.
+*/
+func DeletePersonalAccessToken(userId uint, id uint) error {
+	// UPDATE "personal_access_tokens" SET "deleted_at"=$1$ WHERE (id=$2$ AND user_id=$3$) AND "personal_access_tokens"."deleted_at" IS NULL
+	result := database.Database.Where("id=? AND user_id=?", id, userId).Delete(&PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+/*
+PersonalAccessTokenInput struct:
+
+1. Name
+
+2. Scopes
+
+3. ExpiresAt
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type PersonalAccessTokenInput struct {
+	Name   string   `json:"name" binding:"required,max=255"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// ExpiresAt is optional. If it is omitted, the token does not expire.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Visibilities of an entry. Private entries are only visible to their author.
+const (
+	EntryVisibilityPrivate = "private"
+	EntryVisibilityShared  = "shared"
+)
+
+// Days a week can start on.
+const (
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+)
+
+// Default preferences of a user who has not set them.
+const (
+	DefaultTimeZone = "UTC"
+	DefaultLocale   = "en"
+)
+
+// localePattern matches a BCP 47 language tag, e.g. "en", "ja-JP" or "zh-Hant-TW".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+/*
+Profile struct:
+
+1. UserID
+
+2. DisplayName
+
+3. TimeZone
+
+4. Locale
+
+5. DefaultEntryVisibility
+
+6. WeekStart
+
+7. KeepPhotoMetadata
+
+The profile holds the preferences of a user. It is kept apart from User, so that it can be loaded without the entries.
+*/
+type Profile struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID      uint   `gorm:"not null;uniqueIndex" json:"user_id"`
+	DisplayName string `gorm:"size:255" json:"display_name"`
+	// TimeZone is an IANA time zone name, e.g. "Asia/Tokyo".
+	TimeZone               string `gorm:"size:64;not null;default:UTC" json:"time_zone"`
+	Locale                 string `gorm:"size:35;not null;default:en" json:"locale"`
+	DefaultEntryVisibility string `gorm:"size:16;not null;default:private" json:"default_entry_visibility"`
+	WeekStart              string `gorm:"size:16;not null;default:monday" json:"week_start"`
+	// KeepPhotoMetadata keeps the EXIF metadata(e.g. GPS location, camera) of uploaded photos. By default it is stripped.
+	KeepPhotoMetadata bool `gorm:"not null;default:false" json:"keep_photo_metadata"`
+}
+
+/*
+ProfileInput struct:
+
+1. DisplayName
+
+2. TimeZone
+
+3. Locale
+
+4. DefaultEntryVisibility
+
+5. WeekStart
+
+6. KeepPhotoMetadata
+
+Every field is optional, and only the fields that are sent are updated.
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type ProfileInput struct {
+	DisplayName            *string `json:"display_name" binding:"omitempty,max=255"`
+	TimeZone               *string `json:"time_zone"`
+	Locale                 *string `json:"locale"`
+	DefaultEntryVisibility *string `json:"default_entry_visibility" binding:"omitempty,oneof=private shared"`
+	WeekStart              *string `json:"week_start" binding:"omitempty,oneof=monday sunday saturday"`
+	KeepPhotoMetadata      *bool   `json:"keep_photo_metadata"`
+}
+
+/*
+Location function:
+
+1. Returns the time zone of the profile.
+
+2. If the time zone cannot be loaded, UTC is returned.
+*/
+func (profile *Profile) Location() *time.Location {
+	location, err := time.LoadLocation(profile.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+/*
+Apply function:
+
+1. Validates the time zone and the locale of the input.
+
+2. Sets the fields that are sent in the input.
+*/
+func (profile *Profile) Apply(input ProfileInput) error {
+	// Validates the time zone and the locale of the input.
+	if input.TimeZone != nil {
+		if _, err := time.LoadLocation(*input.TimeZone); err != nil || *input.TimeZone == "" || *input.TimeZone == "Local" {
+			return fmt.Errorf("unknown time zone: %s", *input.TimeZone)
+		}
+	}
+	if input.Locale != nil && !localePattern.MatchString(*input.Locale) {
+		return fmt.Errorf("invalid locale: %s", *input.Locale)
+	}
+
+	// Sets the fields that are sent in the input.
+	if input.DisplayName != nil {
+		profile.DisplayName = *input.DisplayName
+	}
+	if input.TimeZone != nil {
+		profile.TimeZone = *input.TimeZone
+	}
+	if input.Locale != nil {
+		profile.Locale = *input.Locale
+	}
+	if input.DefaultEntryVisibility != nil {
+		profile.DefaultEntryVisibility = *input.DefaultEntryVisibility
+	}
+	if input.WeekStart != nil {
+		profile.WeekStart = *input.WeekStart
+	}
+	if input.KeepPhotoMetadata != nil {
+		profile.KeepPhotoMetadata = *input.KeepPhotoMetadata
+	}
+	return nil
+}
+
+/*
+Save function:
+
+1. If the profile has been loaded from the database, updates it.
+
+2. Otherwise, inserts the profile, or updates it if the user already has one.
+
+FYI: https://gorm.io/docs/create.html#Upsert-On-Conflict
+*/
+func (profile *Profile) Save() (*Profile, error) {
+	// If the profile has been loaded from the database, updates it.
+	if profile.ID != 0 {
+		// UPDATE "profiles" SET ... WHERE "id" = $1$
+		if err := database.Database.Save(profile).Error; err != nil {
+			return &Profile{}, err
+		}
+		return profile, nil
+	}
+	// INSERT INTO "profiles" (...) VALUES (...) ON CONFLICT ("user_id") DO UPDATE SET ... RETURNING "id"
+	err := database.Database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "display_name", "time_zone", "locale", "default_entry_visibility", "week_start", "keep_photo_metadata"}),
+	}).Create(profile).Error
+	if err != nil {
+		return &Profile{}, err
+	}
+	return profile, nil
+}
+
+/*
+FindProfileByUserId function:
+
+1. Queries the database to find the profile of the user.
+
+2. If the user has no profile yet, a profile with the default preferences is returned.
+
+Other features that need the preferences of the user, e.g. their time zone, use this function.
+*/
+func FindProfileByUserId(userId uint) (Profile, error) {
+	var profile Profile
+	// SELECT * FROM "profiles" WHERE user_id=$1$ AND "profiles"."deleted_at" IS NULL ORDER BY "profiles"."id" LIMIT 1
+	err := database.Database.Where("user_id=?", userId).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// If the user has no profile yet, a profile with the default preferences is returned.
+		return Profile{
+			UserID:                 userId,
+			TimeZone:               DefaultTimeZone,
+			Locale:                 DefaultLocale,
+			DefaultEntryVisibility: EntryVisibilityPrivate,
+			WeekStart:              WeekStartMonday,
+		}, nil
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+/*
+FindUserLocation function:
+
+1. Executes FindProfileByUserId function.
+
+2. Returns the time zone of the user.
+*/
+func FindUserLocation(userId uint) (*time.Location, error) {
+	profile, err := FindProfileByUserId(userId)
+	if err != nil {
+		return time.UTC, err
+	}
+	return profile.Location(), nil
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxReactionEmojiLength is the maximum length of a reaction in runes, enough for emoji sequences such as family or flag emoji.
+const maxReactionEmojiLength = 16
+
+// ErrInvalidEmoji is returned when a reaction is not a single emoji.
+var ErrInvalidEmoji = errors.New("a reaction must be an emoji")
+
+/*
+Reaction struct:
+
+1. EntryID
+
+2. UserID
+
+3. Emoji
+
+Each user can react to an entry once with each emoji. Reactions are deleted permanently, so that the same emoji can be added again.
+*/
+type Reaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	EntryID   uint      `gorm:"not null;uniqueIndex:idx_reactions_entry_id_user_id_emoji" json:"entry_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_reactions_entry_id_user_id_emoji;index" json:"user_id"`
+	Emoji     string    `gorm:"size:64;not null;uniqueIndex:idx_reactions_entry_id_user_id_emoji" json:"emoji"`
+}
+
+/*
+ReactionInput struct:
+
+1. Emoji
+*/
+type ReactionInput struct {
+	Emoji string `json:"emoji" binding:"required,max=64"`
+}
+
+/*
+ReactionSummary struct:
+
+1. Emoji
+
+2. Count
+
+3. Reacted
+
+4. Usernames
+*/
+type ReactionSummary struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+	// Reacted is whether the current user has reacted with the emoji.
+	Reacted   bool     `json:"reacted"`
+	Usernames []string `json:"usernames"`
+}
+
+/*
+NormalizeEmoji function:
+
+1. Trims the spaces around the emoji.
+
+2. Rejects anything but an emoji: every character must be a symbol(So) or a part of an emoji sequence,
+e.g. a zero-width joiner, a variation selector, a skin tone modifier or a tag.
+*/
+func NormalizeEmoji(emoji string) (string, error) {
+	emoji = strings.TrimSpace(emoji)
+	runes := []rune(emoji)
+	if len(runes) == 0 || len(runes) > maxReactionEmojiLength {
+		return "", ErrInvalidEmoji
+	}
+	symbols := 0
+	for _, r := range runes {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbols++
+		case r == '\u200d', r == '\ufe0e', r == '\ufe0f', r == '\u20e3':
+			// Zero-width joiner, variation selectors and the combining keycap.
+		case r >= 0x1F3FB && r <= 0x1F3FF:
+			// Skin tone modifiers.
+		case r >= 0xE0020 && r <= 0xE007F:
+			// Tags of subdivision flags.
+		default:
+			return "", ErrInvalidEmoji
+		}
+	}
+	if symbols == 0 {
+		return "", ErrInvalidEmoji
+	}
+	return emoji, nil
+}
+
+/*
+AddReaction function:
+
+1. Inserts the reaction of the user. Adding a reaction the user has already added does nothing.
+
+2. Returns whether the reaction was added.
+*/
+func AddReaction(entryId uint, userId uint, emoji string) (bool, error) {
+	reaction := Reaction{EntryID: entryId, UserID: userId, Emoji: emoji}
+	// INSERT INTO "reactions" (...) VALUES (...) ON CONFLICT DO NOTHING RETURNING "id"
+	result := database.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+/*
+RemoveReaction function:
+
+1. Deletes the reaction of the user.
+
+2. If the user has not reacted with the emoji, gorm.ErrRecordNotFound is returned.
+*/
+func RemoveReaction(entryId uint, userId uint, emoji string) error {
+	// DELETE FROM "reactions" WHERE entry_id = $1$ AND user_id = $2$ AND emoji = $3$
+	result := database.Database.Where("entry_id = ? AND user_id = ? AND emoji = ?", entryId, userId, emoji).Delete(&Reaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+FindReactionSummaries function:
+
+1. Queries the database to find the reactions to the entry with the usernames, the oldest first.
+
+2. Groups them by emoji, in the order each emoji was first used, and marks the emoji the current user(userId) has reacted with.
+*/
+func FindReactionSummaries(entryId uint, userId uint) ([]ReactionSummary, error) {
+	var reactions []struct {
+		Reaction
+		Username string
+	}
+	// SELECT reactions.*, users.username FROM "reactions" JOIN users ON ... WHERE reactions.entry_id = $1$ ORDER BY reactions.id
+	err := database.Database.Model(&Reaction{}).Select("reactions.*, users.username").
+		Joins("JOIN users ON users.id = reactions.user_id").
+		Where("reactions.entry_id = ?", entryId).Order("reactions.id").Scan(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+	summaries := []ReactionSummary{}
+	indexes := map[string]int{}
+	for _, reaction := range reactions {
+		i, ok := indexes[reaction.Emoji]
+		if !ok {
+			i = len(summaries)
+			indexes[reaction.Emoji] = i
+			summaries = append(summaries, ReactionSummary{Emoji: reaction.Emoji, Usernames: []string{}})
+		}
+		summaries[i].Count++
+		summaries[i].Usernames = append(summaries[i].Usernames, reaction.Username)
+		if reaction.UserID == userId {
+			summaries[i].Reacted = true
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,23 @@
+package model
+
+/*
+RegistrationInput struct:
+
+1. Username
+
+2. Password
+
+3. Email
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type RegistrationInput struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	// Email is optional.
+	Email string `json:"email" binding:"omitempty,email"`
+}
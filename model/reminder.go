@@ -0,0 +1,371 @@
+package model
+
+import (
+	"diary_api/database"
+	"diary_api/notifier"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reminderTimeLayout is the layout of the local time of a reminder, e.g. "21:30".
+const reminderTimeLayout = "15:04"
+
+// maxRemindersPerUser is the number of reminders a user can have.
+const maxRemindersPerUser = 20
+
+// ErrTooManyReminders is returned when a user who already has maxRemindersPerUser reminders creates another one.
+var ErrTooManyReminders = fmt.Errorf("a user can have at most %d reminders", maxRemindersPerUser)
+
+/*
+Reminder struct:
+
+1. UserID
+
+2. Weekdays
+
+3. LocalTime
+
+4. Channel, WebhookURL
+
+5. Message
+
+6. Enabled
+
+7. NextRunAt
+
+8. LastSentAt, LastSkippedAt, LastError
+
+The reminder fires at the local time on the days of the week in the time zone of the user's profile,
+unless the user has already written an entry that day.
+*/
+type Reminder struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index" json:"-"`
+	// Weekdays are the days of the week the reminder fires on, from 0(Sunday) to 6(Saturday).
+	Weekdays []int `gorm:"type:jsonb;serializer:json" json:"weekdays"`
+	// LocalTime is the time of day in the format "15:04".
+	LocalTime string `gorm:"size:5;not null" json:"local_time"`
+	// Channel is how the reminder is sent: notifier.ChannelEmail(to the email of the user), notifier.ChannelWebhook(to WebhookURL) or notifier.ChannelLog.
+	Channel    string `gorm:"size:16;not null" json:"channel"`
+	WebhookURL string `gorm:"size:2048" json:"webhook_url,omitempty"`
+	// Message is the text of the reminder. A default text is sent if it is empty.
+	Message string `gorm:"size:500" json:"message"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+	// NextRunAt is when the reminder fires next. It is nil while the reminder is disabled.
+	NextRunAt     *time.Time `gorm:"index" json:"next_run_at"`
+	LastSentAt    *time.Time `json:"last_sent_at"`
+	LastSkippedAt *time.Time `json:"last_skipped_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+/*
+ReminderInput struct:
+
+1. Weekdays
+
+2. LocalTime
+
+3. Channel, WebhookURL
+
+4. Message
+
+5. Enabled
+
+Every field is required to create a reminder except Message and Enabled, which defaults to true.
+To update a reminder, only the fields that are sent are updated.
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type ReminderInput struct {
+	Weekdays   *[]int  `json:"weekdays" binding:"omitempty,min=1,max=7,dive,min=0,max=6"`
+	LocalTime  *string `json:"local_time"`
+	Channel    *string `json:"channel" binding:"omitempty,oneof=email webhook log"`
+	WebhookURL *string `json:"webhook_url" binding:"omitempty,max=2048"`
+	Message    *string `json:"message" binding:"omitempty,max=500"`
+	Enabled    *bool   `json:"enabled"`
+}
+
+/*
+Apply function:
+
+1. Sets the fields that are sent in the input. The weekdays are sorted and deduplicated.
+
+2. Validates the reminder: the local time must be "15:04", the channel must be configured, the email channel needs the email of the user(email),
+and the webhook channel needs an http(s) URL.
+
+3. Schedules the next run in the time zone(location) if the reminder is enabled.
+*/
+func (reminder *Reminder) Apply(input ReminderInput, email *string, location *time.Location) error {
+	// Sets the fields that are sent in the input.
+	if input.Weekdays != nil {
+		seen := map[int]bool{}
+		weekdays := []int{}
+		for _, weekday := range *input.Weekdays {
+			if !seen[weekday] {
+				seen[weekday] = true
+				weekdays = append(weekdays, weekday)
+			}
+		}
+		sort.Ints(weekdays)
+		reminder.Weekdays = weekdays
+	}
+	if input.LocalTime != nil {
+		reminder.LocalTime = *input.LocalTime
+	}
+	if input.Channel != nil {
+		reminder.Channel = *input.Channel
+	}
+	if input.WebhookURL != nil {
+		reminder.WebhookURL = *input.WebhookURL
+	}
+	if input.Message != nil {
+		reminder.Message = *input.Message
+	}
+	if input.Enabled != nil {
+		reminder.Enabled = *input.Enabled
+	}
+
+	// Validates the reminder.
+	if len(reminder.Weekdays) == 0 {
+		return errors.New("weekdays must have at least one day of the week, from 0(Sunday) to 6(Saturday)")
+	}
+	if _, err := time.Parse(reminderTimeLayout, reminder.LocalTime); err != nil {
+		return fmt.Errorf("local_time must be in the format \"15:04\": %q", reminder.LocalTime)
+	}
+	if !notifier.Available(reminder.Channel) {
+		return fmt.Errorf("the channel is not available on this server: %q", reminder.Channel)
+	}
+	switch reminder.Channel {
+	case notifier.ChannelEmail:
+		if email == nil || *email == "" {
+			return errors.New("the email channel needs the email of the account, which is set when registering(/auth/register)")
+		}
+		reminder.WebhookURL = ""
+	case notifier.ChannelWebhook:
+		parsed, err := url.Parse(reminder.WebhookURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return errors.New("webhook_url must be an http(s) URL for the webhook channel")
+		}
+	default:
+		reminder.WebhookURL = ""
+	}
+
+	// Schedules the next run if the reminder is enabled.
+	reminder.Reschedule(time.Now(), location)
+	return nil
+}
+
+/*
+Reschedule function:
+
+1. Sets NextRunAt to the first local time on one of the weekdays after the time(after) in the time zone(location), or to nil if the reminder is disabled.
+On days when the clocks change, a local time that does not exist is moved by time.Date function.
+*/
+func (reminder *Reminder) Reschedule(after time.Time, location *time.Location) {
+	reminder.NextRunAt = nil
+	if !reminder.Enabled {
+		return
+	}
+	localTime, err := time.Parse(reminderTimeLayout, reminder.LocalTime)
+	if err != nil {
+		return
+	}
+	weekdays := map[time.Weekday]bool{}
+	for _, weekday := range reminder.Weekdays {
+		weekdays[time.Weekday(weekday)] = true
+	}
+	local := after.In(location)
+	for day := 0; day <= 7; day++ {
+		candidate := time.Date(local.Year(), local.Month(), local.Day()+day, localTime.Hour(), localTime.Minute(), 0, 0, location)
+		if weekdays[candidate.Weekday()] && candidate.After(after) {
+			next := candidate.UTC()
+			reminder.NextRunAt = &next
+			return
+		}
+	}
+}
+
+/*
+Save function:
+
+1. Inserts the reminder, or updates it if it already exists.
+
+2. A user cannot create more than maxRemindersPerUser reminders, so ErrTooManyReminders is returned.
+*/
+func (reminder *Reminder) Save() (*Reminder, error) {
+	if reminder.ID == 0 {
+		var count int64
+		// SELECT count(*) FROM "reminders" WHERE user_id = $1$
+		if err := database.Database.Model(&Reminder{}).Where("user_id = ?", reminder.UserID).Count(&count).Error; err != nil {
+			return &Reminder{}, err
+		}
+		if count >= maxRemindersPerUser {
+			return &Reminder{}, ErrTooManyReminders
+		}
+	}
+	// INSERT INTO "reminders" (...) VALUES (...) RETURNING "id", or UPDATE "reminders" SET ... WHERE "id" = $1$
+	if err := database.Database.Save(reminder).Error; err != nil {
+		return &Reminder{}, err
+	}
+	return reminder, nil
+}
+
+/*
+FindRemindersByUserId function:
+
+1. Queries the database to find the reminders of the user, the oldest first.
+*/
+func FindRemindersByUserId(userId uint) ([]Reminder, error) {
+	reminders := []Reminder{}
+	// SELECT * FROM "reminders" WHERE user_id = $1$ ORDER BY id
+	err := database.Database.Where("user_id = ?", userId).Order("id").Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+/*
+FindReminder function:
+
+1. Queries the database to find the reminder of the user.
+
+2. If the reminder does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindReminder(userId uint, id uint) (Reminder, error) {
+	var reminder Reminder
+	// SELECT * FROM "reminders" WHERE id = $1$ AND user_id = $2$ ORDER BY "reminders"."id" LIMIT 1
+	err := database.Database.Where("id = ? AND user_id = ?", id, userId).First(&reminder).Error
+	if err != nil {
+		return Reminder{}, err
+	}
+	return reminder, nil
+}
+
+/*
+DeleteReminder function:
+
+1. Deletes the reminder of the user.
+
+2. If the reminder does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func DeleteReminder(userId uint, id uint) error {
+	// DELETE FROM "reminders" WHERE id = $1$ AND user_id = $2$
+	result := database.Database.Where("id = ? AND user_id = ?", id, userId).Delete(&Reminder{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+RescheduleReminders function:
+
+1. Executes (*Reminder).Reschedule function on every reminder of the user in the time zone(location), e.g. after the user has changed their time zone.
+*/
+func RescheduleReminders(userId uint, location *time.Location) error {
+	reminders, err := FindRemindersByUserId(userId)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, reminder := range reminders {
+		reminder.Reschedule(now, location)
+		// UPDATE "reminders" SET "next_run_at"=$1$,"updated_at"=$2$ WHERE "id" = $3$
+		if err := database.Database.Model(&reminder).Select("next_run_at").Updates(&reminder).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+FindDueReminders function:
+
+1. Queries the database to find the enabled reminders whose next run has come, the earliest first, at most limit of them.
+
+2. The reminders of users who are deleted or disabled, including those whose deletion is scheduled, are not returned.
+*/
+func FindDueReminders(now time.Time, limit int) ([]Reminder, error) {
+	reminders := []Reminder{}
+	err := dueRemindersQuery(now, limit).Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+/*
+dueRemindersQuery function:
+
+1. Returns a query selecting the enabled reminders whose next run has come, of the users who are neither deleted nor disabled.
+*/
+func dueRemindersQuery(now time.Time, limit int) *gorm.DB {
+	// SELECT "reminders"."id", ... FROM "reminders" JOIN users ON users.id = reminders.user_id AND users.deleted_at IS NULL AND users.disabled_at IS NULL
+	// WHERE reminders.enabled AND reminders.next_run_at <= $1$ ORDER BY reminders.next_run_at LIMIT $2$
+	return database.Database.Model(&Reminder{}).
+		Joins("JOIN users ON users.id = reminders.user_id AND users.deleted_at IS NULL AND users.disabled_at IS NULL").
+		Where("reminders.enabled AND reminders.next_run_at <= ?", now).
+		Order("reminders.next_run_at").Limit(limit)
+}
+
+/*
+Claim function:
+
+1. Moves the next run of the reminder to its next occurrence after now in the time zone(location), if it still runs at the time it was loaded with.
+
+2. Returns whether the reminder was claimed. A reminder that has been updated since it was loaded is not claimed, so that it is never sent twice.
+*/
+func (reminder *Reminder) Claim(now time.Time, location *time.Location) (bool, error) {
+	scheduled := reminder.NextRunAt
+	reminder.Reschedule(now, location)
+	// UPDATE "reminders" SET "next_run_at"=$1$ WHERE id = $2$ AND next_run_at = $3$
+	result := database.Database.Model(&Reminder{}).Where("id = ? AND next_run_at = ?", reminder.ID, scheduled).
+		UpdateColumn("next_run_at", reminder.NextRunAt)
+	return result.RowsAffected == 1, result.Error
+}
+
+/*
+RecordRun function:
+
+1. Records that the reminder was sent, skipped because the user had already written, or failed with the error(err).
+*/
+func (reminder *Reminder) RecordRun(now time.Time, skipped bool, err error) error {
+	columns := map[string]interface{}{"last_error": ""}
+	switch {
+	case err != nil:
+		columns["last_error"] = err.Error()
+	case skipped:
+		columns["last_skipped_at"] = now
+	default:
+		columns["last_sent_at"] = now
+	}
+	// UPDATE "reminders" SET "last_sent_at"=$1$,"last_error"=$2$ WHERE "id" = $3$
+	return database.Database.Model(&Reminder{}).Where("id = ?", reminder.ID).UpdateColumns(columns).Error
+}
+
+/*
+HasEntryOnDate function:
+
+1. Returns whether the user has written a published entry on the local date, personal or in a journal.
+*/
+func HasEntryOnDate(userId uint, date Date) (bool, error) {
+	var count int64
+	// SELECT count(*) FROM "entries" WHERE user_id = $1$ AND entry_date = $2$ AND status = 'published' AND "entries"."deleted_at" IS NULL
+	err := database.Database.Model(&Entry{}).Where("user_id = ? AND entry_date = ? AND status = ?", userId, date, EntryStatusPublished).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"diary_api/database"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// useDryRunDatabase replaces the database with one that builds the SQL of the queries without running them.
+func useDryRunDatabase(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=diary_test"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.Database
+	database.Database = db
+	t.Cleanup(func() { database.Database = previous })
+}
+
+func TestDueRemindersSkipDisabledAndDeletedUsers(t *testing.T) {
+	useDryRunDatabase(t)
+
+	statement := dueRemindersQuery(time.Now(), 10).Find(&[]Reminder{}).Statement
+	sql := statement.SQL.String()
+	for _, condition := range []string{
+		"JOIN users ON users.id = reminders.user_id",
+		"users.deleted_at IS NULL",
+		"users.disabled_at IS NULL",
+		"reminders.enabled AND reminders.next_run_at <= $1",
+	} {
+		if !strings.Contains(sql, condition) {
+			t.Errorf("the query does not contain %q: %s", condition, sql)
+		}
+	}
+	// Only the columns of the reminders are selected, so that those of the users do not overwrite them.
+	if !strings.HasPrefix(sql, `SELECT "reminders"."id"`) {
+		t.Errorf("the query selects the columns of the users too: %s", sql)
+	}
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+Session struct:
+
+1. UserID
+
+2. UserAgent
+
+3. IPAddress
+
+4. LastSeenAt
+
+5. ExpiresAt
+
+6. RevokedAt
+
+A session is recorded for every JWT issued by /auth/login, and the JWT carries its id(sid).
+*/
+type Session struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`
+	IPAddress  string     `gorm:"size:64" json:"ip_address"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// Current is true for the session of the request. It is not stored.
+	Current bool `gorm:"-" json:"current"`
+}
+
+/*
+Save function:
+
+1. Passes the address of the pointer variable(session) to (*gorm.DB).Create function.
+
+2. If (*gorm.DB).Create function is successfully executed, it returns the address of the pointer variable(session) and nil.
+
+FYI: https://gorm.io/docs/create.html
+*/
+func (session *Session) Save() (*Session, error) {
+	// INSERT INTO "sessions" ("created_at","updated_at","deleted_at","user_id","user_agent","ip_address","last_seen_at","expires_at","revoked_at") VALUES (...) RETURNING "id"
+	err := database.Database.Create(session).Error
+	if err != nil {
+		// If (*gorm.DB).Create function fails to execute,
+		// it returns the address of empty struct and an error.
+		return &Session{}, err
+	}
+	// If (*gorm.DB).Create function is successfully executed,
+	// it returns the address of the pointer variable(session) and nil.
+	return session, nil
+}
+
+/*
+IsActive function:
+
+1. Returns true if the session has neither been revoked nor expired.
+*/
+func (session *Session) IsActive() bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(time.Now())
+}
+
+/*
+Touch function:
+
+1. Updates last_seen_at at most once a minute, so that every request does not cause a write.
+*/
+func (session *Session) Touch() error {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < time.Minute {
+		return nil
+	}
+	session.LastSeenAt = now
+	// UPDATE "sessions" SET "last_seen_at"=$1$ WHERE "id" = $2$
+	return database.Database.Model(session).UpdateColumn("last_seen_at", now).Error
+}
+
+/*
+FindSessionById function:
+
+1. Queries the database to find the corresponding session.
+
+2. If no session matches, an error is returned.
+*/
+func FindSessionById(id uint) (Session, error) {
+	var session Session
+	// SELECT * FROM "sessions" WHERE ID=$1$ AND "sessions"."deleted_at" IS NULL
+	err := database.Database.Where("ID=?", id).Find(&session).Error
+	if err != nil {
+		return Session{}, err
+	}
+	if session.ID == 0 {
+		return Session{}, errors.New("session does not exist")
+	}
+	return session, nil
+}
+
+/*
+FindActiveSessionsByUserId function:
+
+1. Queries the database to find the sessions of the user that have neither been revoked nor expired.
+*/
+func FindActiveSessionsByUserId(userId uint) ([]Session, error) {
+	var sessions []Session
+	// SELECT * FROM "sessions" WHERE (user_id=$1$ AND revoked_at IS NULL AND expires_at > $2$) AND "sessions"."deleted_at" IS NULL ORDER BY last_seen_at DESC
+	err := database.Database.
+		Where("user_id=? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+/*
+RevokeSession function:
+
+1. Sets revoked_at on the session of the user.
+
+2. If the session does not exist, belongs to another user or has already been revoked, gorm.ErrRecordNotFound is returned.
+*/
+func RevokeSession(userId uint, id uint) error {
+	// UPDATE "sessions" SET "revoked_at"=$1$ WHERE id=$2$ AND user_id=$3$ AND revoked_at IS NULL AND "sessions"."deleted_at" IS NULL
+	result := database.Database.Model(&Session{}).
+		Where("id=? AND user_id=? AND revoked_at IS NULL", id, userId).
+		UpdateColumn("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+RevokeAllSessions function:
+
+1. Sets revoked_at on every active session of the user, except the session with the id(exceptId).
+
+Passing 0 as exceptId revokes every session.
+*/
+func RevokeAllSessions(userId uint, exceptId uint) (int64, error) {
+	// UPDATE "sessions" SET "revoked_at"=$1$ WHERE user_id=$2$ AND revoked_at IS NULL AND id <> $3$ AND "sessions"."deleted_at" IS NULL
+	result := database.Database.Model(&Session{}).
+		Where("user_id=? AND revoked_at IS NULL AND id <> ?", userId, exceptId).
+		UpdateColumn("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,280 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Modes of filtering entries by several tags.
+const (
+	// TagModeAll matches the entries that have every tag.
+	TagModeAll = "all"
+	// TagModeAny matches the entries that have at least one of the tags.
+	TagModeAny = "any"
+)
+
+// maxTagNameLength is the maximum length of a tag name in characters.
+const maxTagNameLength = 64
+
+// tagNamePattern matches a normalized tag name: letters, numbers, "_" and "-".
+var tagNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// hashtagPattern matches a #hashtag that starts a word, so that "C#" and "a#b" are not hashtags.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_-]+)`)
+
+/*
+Tag struct:
+
+1. UserID
+
+2. Name
+
+Tags belong to a user, and the name of a tag is unique per user.
+Tags are deleted permanently, so that a deleted name can be used again.
+*/
+type Tag struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID uint   `gorm:"not null;uniqueIndex:idx_tags_user_id_name" json:"user_id"`
+	Name   string `gorm:"size:64;not null;uniqueIndex:idx_tags_user_id_name" json:"name"`
+}
+
+/*
+TagSummary struct:
+
+1. Tag
+
+2. EntryCount
+*/
+type TagSummary struct {
+	Tag
+	EntryCount int64 `json:"entry_count"`
+}
+
+/*
+TagInput struct:
+
+1. Name
+
+Model binding and validation:
+
+To bind a request body into a type, use model binding.
+
+FYI: https://gin-gonic.com/docs/examples/binding-and-validation/
+*/
+type TagInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+/*
+NormalizeTagName function:
+
+1. Trims the spaces and the leading "#", and lowercases the name, so that "#Travel" and "travel" are the same tag.
+
+2. Validates the name.
+*/
+func NormalizeTagName(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if normalized == "" || len([]rune(normalized)) > maxTagNameLength || !tagNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid tag: %q (use up to %d letters, numbers, \"_\" or \"-\")", name, maxTagNameLength)
+	}
+	return normalized, nil
+}
+
+/*
+NormalizeTagNames function:
+
+1. Executes NormalizeTagName function on every name.
+
+2. Removes the duplicates, keeping the order of the names.
+*/
+func NormalizeTagNames(names []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		value, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[value] {
+			seen[value] = true
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized, nil
+}
+
+/*
+ExtractHashtags function:
+
+1. Returns the #hashtags in the content, without the "#".
+
+The hashtags that are too long to be tags are skipped.
+*/
+func ExtractHashtags(content string) []string {
+	hashtags := []string{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		if len([]rune(match[1])) <= maxTagNameLength {
+			hashtags = append(hashtags, match[1])
+		}
+	}
+	return hashtags
+}
+
+/*
+FindOrCreateTags function:
+
+1. Inserts the tags of the user that do not exist yet.
+
+2. Queries the database to find the tags of the user with the names, which must be normalized.
+
+FYI: https://gorm.io/docs/create.html#Upsert-On-Conflict
+*/
+func FindOrCreateTags(userId uint, names []string) ([]Tag, error) {
+	tags := []Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	newTags := make([]Tag, len(names))
+	for i, name := range names {
+		newTags[i] = Tag{UserID: userId, Name: name}
+	}
+	// INSERT INTO "tags" (...) VALUES (...) ON CONFLICT DO NOTHING
+	if err := database.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(&newTags).Error; err != nil {
+		return nil, err
+	}
+	// SELECT * FROM "tags" WHERE user_id = $1$ AND name IN (...) AND "tags"."deleted_at" IS NULL
+	if err := database.Database.Where("user_id = ? AND name IN ?", userId, names).Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+/*
+FindTagSummariesByUserId function:
+
+1. Queries the database to find the tags of the user with the number of their entries, ordered by name.
+*/
+func FindTagSummariesByUserId(userId uint) ([]TagSummary, error) {
+	summaries := []TagSummary{}
+	// SELECT tags.*, count(entries.id) AS entry_count FROM "tags"
+	// LEFT JOIN entry_tags ON entry_tags.tag_id = tags.id LEFT JOIN entries ON entries.id = entry_tags.entry_id AND entries.deleted_at IS NULL
+	// WHERE tags.user_id = $1$ AND "tags"."deleted_at" IS NULL GROUP BY "tags"."id" ORDER BY tags.name
+	err := database.Database.Model(&Tag{}).
+		Select("tags.*, count(entries.id) AS entry_count").
+		Joins("LEFT JOIN entry_tags ON entry_tags.tag_id = tags.id").
+		Joins("LEFT JOIN entries ON entries.id = entry_tags.entry_id AND entries.deleted_at IS NULL").
+		Where("tags.user_id = ?", userId).
+		Group("tags.id").
+		Order("tags.name").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+/*
+FindTagById function:
+
+1. Queries the database to find the tag of the user.
+
+2. If the tag does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindTagById(userId uint, id uint) (Tag, error) {
+	var tag Tag
+	// SELECT * FROM "tags" WHERE id = $1$ AND user_id = $2$ AND "tags"."deleted_at" IS NULL ORDER BY "tags"."id" LIMIT 1
+	err := database.Database.Where("id = ? AND user_id = ?", id, userId).First(&tag).Error
+	if err != nil {
+		return Tag{}, err
+	}
+	return tag, nil
+}
+
+/*
+RenameTag function:
+
+1. Executes FindTagById function.
+
+2. If the user already has a tag with the new name, merges the tag into it:
+the entries of the tag are moved to the other tag, and the tag is deleted.
+
+3. Otherwise, renames the tag.
+
+4. Returns the renamed tag, or the tag it was merged into, and whether it was merged.
+*/
+func RenameTag(userId uint, id uint, name string) (tag Tag, merged bool, err error) {
+	err = database.Database.Transaction(func(tx *gorm.DB) error {
+		// Executes FindTagById function.
+		if err := tx.Where("id = ? AND user_id = ?", id, userId).First(&tag).Error; err != nil {
+			return err
+		}
+		if tag.Name == name {
+			return nil
+		}
+
+		var target Tag
+		err := tx.Where("user_id = ? AND name = ?", userId, name).First(&target).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Otherwise, renames the tag.
+			// UPDATE "tags" SET "name"=$1$,"updated_at"=$2$ WHERE "id" = $3$
+			return tx.Model(&tag).Update("name", name).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// Merges the tag into the other tag.
+		// INSERT INTO entry_tags (entry_id, tag_id) SELECT entry_id, $1$ FROM entry_tags WHERE tag_id = $2$ ON CONFLICT DO NOTHING
+		if err := tx.Exec("INSERT INTO entry_tags (entry_id, tag_id) SELECT entry_id, ? FROM entry_tags WHERE tag_id = ? ON CONFLICT DO NOTHING", target.ID, tag.ID).Error; err != nil {
+			return err
+		}
+		if err := deleteTag(tx, tag.ID); err != nil {
+			return err
+		}
+		tag, merged = target, true
+		return nil
+	})
+	if err != nil {
+		return Tag{}, false, err
+	}
+	return tag, merged, nil
+}
+
+/*
+DeleteTag function:
+
+1. Removes the tag from every entry of the user, and permanently deletes it.
+
+2. If the tag does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func DeleteTag(userId uint, id uint) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		var tag Tag
+		if err := tx.Where("id = ? AND user_id = ?", id, userId).First(&tag).Error; err != nil {
+			return err
+		}
+		return deleteTag(tx, tag.ID)
+	})
+}
+
+/*
+deleteTag function:
+
+1. Deletes the rows of the join table(entry_tags) and the tag.
+*/
+func deleteTag(tx *gorm.DB, id uint) error {
+	// DELETE FROM entry_tags WHERE tag_id = $1$
+	if err := tx.Exec("DELETE FROM entry_tags WHERE tag_id = ?", id).Error; err != nil {
+		return err
+	}
+	// DELETE FROM "tags" WHERE "tags"."id" = $1$
+	return tx.Unscoped().Delete(&Tag{}, id).Error
+}
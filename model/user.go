@@ -1,15 +1,35 @@
 package model
 
 import (
+	"context"
 	"diary_api/database"
+	"errors"
 	"fmt"
 	"html"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Roles of a user. The role is carried in the JWT(role).
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
+// Roles is the list of valid roles.
+var Roles = []string{RoleUser, RoleAdmin, RoleSupport}
+
+// ErrUserDisabled is returned when a disabled user tries to log in.
+var ErrUserDisabled = errors.New("the account has been disabled")
+
+// ErrUserDeletionCancelled is returned when a user is no longer due for deletion, e.g. because an admin has enabled them again.
+var ErrUserDeletionCancelled = errors.New("the deletion of the account has been cancelled")
+
 /*
 User struct:
 
@@ -17,7 +37,17 @@ User struct:
 
 2. Password
 
-3. Entries
+3. Email, EmailVerifiedAt
+
+4. Role
+
+5. DisabledAt
+
+6. PasswordResetRequired
+
+7. DeletionScheduledAt
+
+8. Entries
 */
 type User struct {
 	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
@@ -26,6 +56,18 @@ type User struct {
 	Username string `gorm:"size:255;not null;unique" json:"username"`
 	// json:"-": This ensures that the user’s password is not returned in the JSON response.
 	Password string `gorm:"size:255;not null;" json:"-"`
+	// Email is optional. It is used to link logins with external identity providers(OIDC) to the user.
+	Email *string `gorm:"size:255;uniqueIndex" json:"email"`
+	// EmailVerifiedAt is when the app verified that the email belongs to the user, e.g. through an identity provider that verified it.
+	// An email given at registration is not verified, so logins with external identity providers are never linked to it automatically.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            string     `gorm:"size:32;not null;default:user" json:"role"`
+	// A disabled user cannot log in, and their tokens are rejected.
+	DisabledAt *time.Time `json:"disabled_at"`
+	// A user whose password reset is required must set a new password before logging in.
+	PasswordResetRequired bool `gorm:"not null;default:false" json:"password_reset_required"`
+	// DeletionScheduledAt is the time at which the user and their data are hard deleted.
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at"`
 	// User has many Entries.
 	// FYI: https://gorm.io/docs/has_many.html#Has-Many
 	Entries []Entry
@@ -87,25 +129,82 @@ If you want to change the state of the receiver in a method, manipulating the va
 FYI: https://go.dev/tour/methods/8
 */
 func (user *User) BeforeSave(*gorm.DB) error {
-	// Sets the password.
-	pass := []byte(user.Password)
-	// Sets the cost.
-	cost := bcrypt.DefaultCost
-	// Returns the bcrypt hash of the password at the given cost.
-	passwordHash, err := bcrypt.GenerateFromPassword(pass, cost)
+	// Returns the bcrypt hash of the password.
+	passwordHash, err := hashPassword(user.Password)
 	if err != nil {
-		// If bcrypt.GenerateFromPassword function fails to execute,
+		// If hashPassword function fails to execute,
 		// it returns an error.
 		return err
 	}
 	// Change the state of the receiver.
-	(*user).Password = string(passwordHash)
+	(*user).Password = passwordHash
+	if user.Role == "" {
+		(*user).Role = RoleUser
+	}
 	(*user).Username = html.EscapeString(strings.TrimSpace(user.Username))
+	if user.Email != nil {
+		// Emails are compared case-insensitively, so they are stored in lower case.
+		email := strings.ToLower(strings.TrimSpace(*user.Email))
+		(*user).Email = &email
+	}
 	// If bcrypt.GenerateFromPassword function is successfully executed,
 	// it returns nil.
 	return nil
 }
 
+/*
+hashPassword function:
+
+1. Sets the password.
+
+2. Sets the cost.
+
+3. Returns the bcrypt hash of the password at the given cost.
+*/
+func hashPassword(password string) (string, error) {
+	// Sets the password.
+	pass := []byte(password)
+	// Sets the cost.
+	cost := bcrypt.DefaultCost
+	// Returns the bcrypt hash of the password at the given cost.
+	passwordHash, err := bcrypt.GenerateFromPassword(pass, cost)
+	if err != nil {
+		return "", err
+	}
+	return string(passwordHash), nil
+}
+
+/*
+IsDisabled function:
+
+1. Returns true if the user has been disabled.
+*/
+func (user *User) IsDisabled() bool {
+	return user.DisabledAt != nil
+}
+
+/*
+UpdatePassword function:
+
+1. Executes hashPassword function.
+
+2. Updates the password and clears password_reset_required.
+
+UpdateColumns is used, because BeforeSave would hash the stored hash again.
+*/
+func (user *User) UpdatePassword(password string) error {
+	// Executes hashPassword function.
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	// UPDATE "users" SET "password"=$1$,"password_reset_required"=false WHERE "id" = $2$
+	return database.Database.Model(user).UpdateColumns(map[string]interface{}{
+		"password":                passwordHash,
+		"password_reset_required": false,
+	}).Error
+}
+
 /*
 ValidatePassword function:
 
@@ -153,6 +252,26 @@ func FindUserByUsername(username string) (User, error) {
 	return user, nil
 }
 
+/*
+FindUserByEmail function:
+
+1. Queries the database to find the user with the email.
+
+2. If no user matches, gorm.ErrRecordNotFound is returned.
+*/
+func FindUserByEmail(email string) (User, error) {
+	var user User
+	// SELECT * FROM "users" WHERE email=$1$ AND "users"."deleted_at" IS NULL
+	err := database.Database.Where("email=?", strings.ToLower(strings.TrimSpace(email))).Find(&user).Error
+	if err != nil {
+		return User{}, err
+	}
+	if user.ID == 0 {
+		return User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
 /*
 FindUserByIdPreloadEntries function:
 
@@ -195,3 +314,359 @@ func FindUserById(id uint) (User, error) {
 	// it returns the user struct and nil.
 	return user, nil
 }
+
+/*
+ValidateRole function:
+
+1. Returns an error if the role is not one of Roles.
+*/
+func ValidateRole(role string) error {
+	for _, candidate := range Roles {
+		if role == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown role: %s", role)
+}
+
+/*
+UserSummary struct:
+
+A user as seen by the admin API. Entries are only counted, so that their content is never exposed.
+*/
+type UserSummary struct {
+	ID                    uint       `json:"id"`
+	CreatedAt             time.Time  `json:"created_at"`
+	Username              string     `json:"username"`
+	Email                 *string    `json:"email"`
+	Role                  string     `json:"role"`
+	DisabledAt            *time.Time `json:"disabled_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	EntryCount            int64      `json:"entry_count"`
+}
+
+/*
+userSummaryQuery function:
+
+1. Returns a query selecting the users with the number of their entries.
+*/
+func userSummaryQuery() *gorm.DB {
+	// SELECT users.id, ..., (SELECT count(*) FROM entries WHERE entries.user_id = users.id AND entries.deleted_at IS NULL) AS entry_count FROM "users" WHERE "users"."deleted_at" IS NULL
+	return database.Database.Model(&User{}).Select(
+		"users.id, users.created_at, users.username, users.email, users.role, users.disabled_at, users.password_reset_required, " +
+			"(SELECT count(*) FROM entries WHERE entries.user_id = users.id AND entries.deleted_at IS NULL) AS entry_count",
+	)
+}
+
+/*
+SearchUserSummaries function:
+
+1. Executes userSummarySearchQuery function.
+
+2. Returns a page of users with the number of their entries, and the total number of matching users.
+*/
+func SearchUserSummaries(q string, limit int, offset int) ([]UserSummary, int64, error) {
+	query := userSummarySearchQuery(q)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var summaries []UserSummary
+	err := query.Order("users.id").Limit(limit).Offset(offset).Scan(&summaries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return summaries, total, nil
+}
+
+/*
+userSummarySearchQuery function:
+
+1. Returns userSummaryQuery filtered by the search query(q), which matches a part of the username or the email, ignoring the case.
+The wildcards of LIKE in the search query match literally.
+*/
+func userSummarySearchQuery(q string) *gorm.DB {
+	query := userSummaryQuery()
+	if q != "" {
+		pattern := "%" + escapeLikePattern(strings.ToLower(q)) + "%"
+		// ... WHERE (lower(users.username) LIKE $1$ ESCAPE '\' OR lower(users.email) LIKE $2$ ESCAPE '\') AND "users"."deleted_at" IS NULL
+		query = query.Where(`lower(users.username) LIKE ? ESCAPE '\' OR lower(users.email) LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+	return query
+}
+
+// likePatternEscaper escapes the characters that LIKE treats as wildcards, and the escape character(\) itself.
+var likePatternEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+/*
+escapeLikePattern function:
+
+1. Returns the text(value) with "%", "_" and "\" escaped, so that it matches literally in LIKE ... ESCAPE '\'.
+
+FYI: https://www.postgresql.org/docs/current/functions-matching.html#FUNCTIONS-LIKE
+*/
+func escapeLikePattern(value string) string {
+	return likePatternEscaper.Replace(value)
+}
+
+/*
+FindUserSummaryById function:
+
+1. Returns the user with the number of their entries.
+
+2. If the user does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func FindUserSummaryById(id uint) (UserSummary, error) {
+	var summaries []UserSummary
+	err := userSummaryQuery().Where("users.id = ?", id).Scan(&summaries).Error
+	if err != nil {
+		return UserSummary{}, err
+	}
+	if len(summaries) == 0 {
+		return UserSummary{}, gorm.ErrRecordNotFound
+	}
+	return summaries[0], nil
+}
+
+/*
+updateUserColumns function:
+
+1. Updates the columns of the user without running BeforeSave.
+
+2. If the user does not exist, gorm.ErrRecordNotFound is returned.
+*/
+func updateUserColumns(id uint, columns map[string]interface{}) error {
+	// UPDATE "users" SET ... WHERE id = $1$ AND "users"."deleted_at" IS NULL
+	result := database.Database.Model(&User{}).Where("id = ?", id).UpdateColumns(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+MarkEmailVerified function:
+
+1. Sets email_verified_at on the user if their email is the verified email(email).
+*/
+func MarkEmailVerified(id uint, email string) error {
+	// UPDATE "users" SET "email_verified_at"=$1$ WHERE id = $2$ AND email = $3$ AND "users"."deleted_at" IS NULL
+	return database.Database.Model(&User{}).Where("id = ? AND email = ?", id, strings.ToLower(strings.TrimSpace(email))).
+		UpdateColumn("email_verified_at", time.Now()).Error
+}
+
+/*
+DisableUser function:
+
+1. Sets disabled_at on the user.
+
+2. Revokes every session of the user.
+*/
+func DisableUser(id uint) error {
+	if err := updateUserColumns(id, map[string]interface{}{"disabled_at": time.Now()}); err != nil {
+		return err
+	}
+	_, err := RevokeAllSessions(id, 0)
+	return err
+}
+
+/*
+EnableUser function:
+
+1. Clears disabled_at on the user.
+
+2. Clears deletion_scheduled_at too, so that an account an admin restores during its grace period is not hard deleted.
+*/
+func EnableUser(id uint) error {
+	return updateUserColumns(id, map[string]interface{}{"disabled_at": nil, "deletion_scheduled_at": nil})
+}
+
+/*
+RequirePasswordReset function:
+
+1. Sets password_reset_required on the user.
+
+2. Revokes every session of the user, so that they have to log in with a new password.
+*/
+func RequirePasswordReset(id uint) error {
+	if err := updateUserColumns(id, map[string]interface{}{"password_reset_required": true}); err != nil {
+		return err
+	}
+	_, err := RevokeAllSessions(id, 0)
+	return err
+}
+
+/*
+SetUserRole function:
+
+1. Executes ValidateRole function.
+
+2. Updates the role of the user.
+
+3. Revokes every session of the user, because their JWTs carry the old role.
+*/
+func SetUserRole(id uint, role string) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+	if err := updateUserColumns(id, map[string]interface{}{"role": role}); err != nil {
+		return err
+	}
+	_, err := RevokeAllSessions(id, 0)
+	return err
+}
+
+/*
+PromoteAdmins function:
+
+1. Sets the admin role on the users with the usernames.
+
+It is used to bootstrap the first admin from the ADMIN_USERNAMES environment variable.
+*/
+func PromoteAdmins(usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	// UPDATE "users" SET "role"='admin' WHERE username IN (...) AND "users"."deleted_at" IS NULL
+	return database.Database.Model(&User{}).Where("username IN ?", usernames).UpdateColumn("role", RoleAdmin).Error
+}
+
+/*
+ScheduleUserDeletion function:
+
+1. Sets deletion_scheduled_at and disables the user, so that they cannot log in during the grace period.
+
+2. Revokes every session and deletes every personal access token of the user.
+*/
+func ScheduleUserDeletion(id uint, deleteAt time.Time) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// UPDATE "users" SET "deletion_scheduled_at"=$1$,"disabled_at"=$2$ WHERE id = $3$
+		if err := tx.Model(&User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"deletion_scheduled_at": deleteAt,
+			"disabled_at":           now,
+		}).Error; err != nil {
+			return err
+		}
+		// UPDATE "sessions" SET "revoked_at"=$1$ WHERE user_id = $2$ AND revoked_at IS NULL
+		if err := tx.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", id).UpdateColumn("revoked_at", now).Error; err != nil {
+			return err
+		}
+		// UPDATE "personal_access_tokens" SET "deleted_at"=$1$ WHERE user_id = $2$
+		return tx.Where("user_id = ?", id).Delete(&PersonalAccessToken{}).Error
+	})
+}
+
+/*
+CancelUserDeletion function:
+
+1. Clears deletion_scheduled_at and disabled_at on the user during the grace period of their deletion.
+
+2. If the deletion of the user is not scheduled, or its grace period has passed, gorm.ErrRecordNotFound is returned.
+*/
+func CancelUserDeletion(id uint) error {
+	// UPDATE "users" SET "deletion_scheduled_at"=NULL,"disabled_at"=NULL WHERE id = $1$ AND deletion_scheduled_at > $2$ AND "users"."deleted_at" IS NULL
+	result := database.Database.Model(&User{}).Where("id = ? AND deletion_scheduled_at > ?", id, time.Now()).
+		UpdateColumns(map[string]interface{}{"deletion_scheduled_at": nil, "disabled_at": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*
+FindUserIdsDueForDeletion function:
+
+1. Returns the ids of the users whose deletion grace period has passed, and who are still disabled.
+*/
+func FindUserIdsDueForDeletion() ([]uint, error) {
+	var ids []uint
+	// SELECT id FROM "users" WHERE deletion_scheduled_at <= $1$ AND disabled_at IS NOT NULL
+	err := database.Database.Unscoped().Model(&User{}).Where("deletion_scheduled_at <= ? AND disabled_at IS NOT NULL", time.Now()).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// entriesOfUserOrUser matches the rows of a user(user_id), and the rows of their entries.
+const entriesOfUserOrUser = "user_id = ? OR entry_id IN (SELECT id FROM entries WHERE user_id = ?)"
+
+/*
+HardDeleteUser function:
+
+1. Permanently deletes the data of the user, including soft-deleted rows, their data key and the user in one transaction.
+The user is locked and checked first: if their deletion has been cancelled since, or they are no longer disabled, ErrUserDeletionCancelled is returned.
+
+2. Evicts the data key of the user from the cache, and deletes the blobs of the attachments of the user and of their entries once the transaction is committed.
+
+FYI: https://gorm.io/docs/delete.html#Delete-permanently
+*/
+func HardDeleteUser(id uint) error {
+	var attachments []Attachment
+	var importKeys []string
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped()
+		// SELECT "id" FROM "users" WHERE id = $1$ AND deletion_scheduled_at <= $2$ AND disabled_at IS NOT NULL FOR UPDATE
+		var due []uint
+		if err := tx.Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deletion_scheduled_at <= ? AND disabled_at IS NOT NULL", id, time.Now()).Pluck("id", &due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return ErrUserDeletionCancelled
+		}
+		// SELECT storage_key, thumbnail_status FROM "attachments" WHERE user_id = $1$ OR entry_id IN (SELECT id FROM entries WHERE user_id = $1$)
+		// The attachments other members of a journal have uploaded to the entries of the user are deleted with the entries.
+		if err := tx.Select("storage_key", "thumbnail_status").Where(entriesOfUserOrUser, id, id).Find(&attachments).Error; err != nil {
+			return err
+		}
+		// DELETE FROM "attachments" WHERE user_id = $1$ OR entry_id IN (SELECT id FROM entries WHERE user_id = $1$)
+		if err := tx.Where(entriesOfUserOrUser, id, id).Delete(&Attachment{}).Error; err != nil {
+			return err
+		}
+		// DELETE FROM "entry_tags" WHERE entry_id IN (SELECT id FROM entries WHERE user_id = $1$), and the same for the other tables of the entries,
+		// whoever wrote their rows
+		for _, table := range []string{"entry_tags", "entry_revisions", "comments", "reactions", "entry_shares"} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE entry_id IN (SELECT id FROM entries WHERE user_id = ?)", id).Error; err != nil {
+				return err
+			}
+		}
+		// Hands the journals of the user over to another member, and deletes the memberships of the user.
+		if err := handOverJournals(tx, id); err != nil {
+			return err
+		}
+		// SELECT storage_key FROM "import_jobs" WHERE user_id = $1$
+		if err := tx.Model(&ImportJob{}).Where("user_id = ?", id).Pluck("storage_key", &importKeys).Error; err != nil {
+			return err
+		}
+		// DELETE FROM "entry_shares" WHERE user_id = $1$, and the same for "entries" and the other tables
+		for _, dependent := range []interface{}{&EntryShare{}, &Comment{}, &Reaction{}, &Entry{}, &Tag{}, &FieldDefinition{}, &Session{}, &PersonalAccessToken{}, &UserIdentity{}, &ImportJob{}, &EncryptionKey{}, &Reminder{}, &Profile{}} {
+			if err := tx.Where("user_id = ?", id).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		// Deletes the data key of the user, so that their content left in backups cannot be decrypted.
+		if err := deleteDataKey(tx, id); err != nil {
+			return err
+		}
+		// DELETE FROM "users" WHERE id = $1$
+		return tx.Where("id = ?", id).Delete(&User{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	// Evicts the data key of the user from the cache once the transaction is committed.
+	evictDataKey(id)
+	// Deletes the blobs once the transaction is committed, because they cannot be restored if it is rolled back.
+	for _, attachment := range attachments {
+		deleteBlobs(context.Background(), attachment.blobKeys())
+	}
+	deleteBlobs(context.Background(), importKeys)
+	return nil
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"diary_api/database"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+/*
+UserIdentity struct:
+
+1. UserID
+
+2. Provider
+
+3. Subject
+
+4. Email
+
+A user identity links an account at an external identity provider(OIDC) to the user.
+*/
+type UserIdentity struct {
+	// GORM defined a gorm.Model struct, which includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	// FYI: https://gorm.io/docs/models.html#gorm-Model
+	gorm.Model
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	// The subject(sub) is unique within the provider.
+	Provider string `gorm:"size:64;not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject  string `gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject" json:"subject"`
+	Email    string `gorm:"size:255" json:"email"`
+}
+
+/*
+Save function:
+
+1. Passes the address of the pointer variable(identity) to (*gorm.DB).Create function.
+
+2. If (*gorm.DB).Create function is successfully executed, it returns the address of the pointer variable(identity) and nil.
+
+FYI: https://gorm.io/docs/create.html
+*/
+func (identity *UserIdentity) Save() (*UserIdentity, error) {
+	// INSERT INTO "user_identities" ("created_at","updated_at","deleted_at","user_id","provider","subject","email") VALUES (...) RETURNING "id"
+	err := database.Database.Create(identity).Error
+	if err != nil {
+		return &UserIdentity{}, err
+	}
+	return identity, nil
+}
+
+/*
+FindUserIdentity function:
+
+1. Queries the database to find the identity with the provider and the subject.
+
+2. If no identity matches, gorm.ErrRecordNotFound is returned.
+*/
+func FindUserIdentity(provider string, subject string) (UserIdentity, error) {
+	var identity UserIdentity
+	// SELECT * FROM "user_identities" WHERE provider=$1$ AND subject=$2$ AND "user_identities"."deleted_at" IS NULL
+	err := database.Database.Where("provider=? AND subject=?", provider, subject).Find(&identity).Error
+	if err != nil {
+		return UserIdentity{}, err
+	}
+	if identity.ID == 0 {
+		return UserIdentity{}, gorm.ErrRecordNotFound
+	}
+	return identity, nil
+}
+
+// ErrIdentityLinkedToAnotherUser is returned when an identity that is already linked to a user is linked to another one.
+var ErrIdentityLinkedToAnotherUser = errors.New("the identity is already linked to another account")
+
+/*
+LinkUserIdentity function:
+
+1. Links the identity to the user, unless it is already linked.
+
+2. If the identity is linked to another user, ErrIdentityLinkedToAnotherUser is returned.
+*/
+func LinkUserIdentity(identity *UserIdentity) error {
+	linked, err := FindUserIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		if linked.UserID != identity.UserID {
+			return ErrIdentityLinkedToAnotherUser
+		}
+		*identity = linked
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	_, err = identity.Save()
+	return err
+}
+
+/*
+CreateUserWithIdentity function:
+
+1. Creates the user and the identity linked to it in one transaction.
+
+FYI: https://gorm.io/docs/transactions.html
+*/
+func CreateUserWithIdentity(user *User, identity *UserIdentity) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		// INSERT INTO "users" (...) VALUES (...) RETURNING "id"
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		// INSERT INTO "user_identities" (...) VALUES (...) RETURNING "id"
+		return tx.Create(identity).Error
+	})
+}
+
+/*
+AvailableUsername function:
+
+1. Returns the base username if it is not taken.
+
+2. Otherwise, appends a number to the base username until an unused one is found.
+
+It is used to create users that log in with an external identity provider.
+*/
+func AvailableUsername(base string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%d", base, i)
+		}
+		var count int64
+		// SELECT count(*) FROM "users" WHERE username=$1$
+		err := database.Database.Unscoped().Model(&User{}).Where("username=?", candidate).Count(&count).Error
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no username is available for %s", base)
+}
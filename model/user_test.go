@@ -0,0 +1,39 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeLikePattern(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"testuser01", "testuser01"},
+		{"_", `\_`},
+		{"100%", `100\%`},
+		{`a\b`, `a\\b`},
+		{`\%_`, `\\\%\_`},
+	}
+	for _, test := range tests {
+		if got := escapeLikePattern(test.value); got != test.want {
+			t.Errorf("escapeLikePattern(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestUserSummarySearchQuery(t *testing.T) {
+	useDryRunDatabase(t)
+
+	statement := userSummarySearchQuery("Test_User").Find(&[]UserSummary{}).Statement
+	sql := statement.SQL.String()
+	for _, condition := range []string{`lower(users.username) LIKE $1 ESCAPE '\'`, `lower(users.email) LIKE $2 ESCAPE '\'`} {
+		if !strings.Contains(sql, condition) {
+			t.Errorf("the query does not contain %q: %s", condition, sql)
+		}
+	}
+	if len(statement.Vars) != 2 || statement.Vars[0] != `%test\_user%` || statement.Vars[1] != `%test\_user%` {
+		t.Errorf("the patterns are %v, want %q", statement.Vars, `%test\_user%`)
+	}
+}
@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+/*
+EmailNotifier struct:
+
+1. Addr, Host
+
+2. Username, Password
+
+3. From
+
+Sends the notifications as plain text emails through an SMTP server, with STARTTLS if the server supports it.
+*/
+type EmailNotifier struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+/*
+Notify function:
+
+1. Parses the addresses, so that a header cannot be injected through them.
+
+2. Builds the message with its subject encoded, and sends it with PLAIN authentication if a username is set.
+
+FYI: https://pkg.go.dev/net/smtp#SendMail
+*/
+func (notifier *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	// Parses the addresses.
+	from, err := mail.ParseAddress(notifier.From)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+	to, err := mail.ParseAddress(notification.Email)
+	if err != nil {
+		return errors.New("the user has no valid email address")
+	}
+
+	// Builds the message, and sends it.
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", from.String())
+	fmt.Fprintf(&message, "To: %s\r\n", to.String())
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+	message.WriteString("\r\n")
+	var auth smtp.Auth
+	if notifier.Username != "" {
+		auth = smtp.PlainAuth("", notifier.Username, notifier.Password, notifier.Host)
+	}
+	return smtp.SendMail(notifier.Addr, auth, from.Address, []string{to.Address}, []byte(message.String()))
+}
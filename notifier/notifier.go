@@ -0,0 +1,117 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Channels a notification can be sent through.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+)
+
+/*
+Notification struct:
+
+1. UserID, Username
+
+2. Email
+
+3. WebhookURL
+
+4. Subject, Body
+
+5. ScheduledAt
+
+Email is only set for the email channel, and WebhookURL for the webhook channel.
+*/
+type Notification struct {
+	UserID      uint
+	Username    string
+	Email       string
+	WebhookURL  string
+	Subject     string
+	Body        string
+	ScheduledAt time.Time
+}
+
+/*
+Notifier interface:
+
+1. Notify
+
+A notifier sends notifications, e.g. writing reminders, through one channel.
+*/
+type Notifier interface {
+	// Notify sends the notification. It returns an error if the notification could not be delivered.
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Notifiers are the notifiers of the channels that are configured, by channel.
+var Notifiers = map[string]Notifier{}
+
+/*
+Connect function:
+
+1. Sets the notifiers of the log and the webhook channels, which need no configuration.
+Webhooks are signed with WEBHOOK_SECRET if it is set, and can be sent to private addresses only if WEBHOOK_ALLOW_PRIVATE is "true", e.g. in development.
+
+2. Sets the notifier of the email channel if SMTP_HOST is set.
+*/
+func Connect() error {
+	notifiers := map[string]Notifier{
+		ChannelLog:     LogNotifier{},
+		ChannelWebhook: NewWebhookNotifier(os.Getenv("WEBHOOK_SECRET"), os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"),
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			return fmt.Errorf("SMTP_FROM must be set with SMTP_HOST")
+		}
+		notifiers[ChannelEmail] = &EmailNotifier{
+			Addr:     host + ":" + port,
+			Host:     host,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	Notifiers = notifiers
+	return nil
+}
+
+/*
+Available function:
+
+1. Returns whether the channel is configured.
+*/
+func Available(channel string) bool {
+	_, ok := Notifiers[channel]
+	return ok
+}
+
+/*
+LogNotifier struct:
+
+Prints the notifications to the standard output, e.g. in development.
+*/
+type LogNotifier struct{}
+
+/*
+Notify function:
+
+1. Prints the notification.
+*/
+func (LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	fmt.Printf("Notification [userId: %d, username: %s, scheduledAt: %s]: %s: %s\n",
+		notification.UserID, notification.Username, notification.ScheduledAt.Format(time.RFC3339), notification.Subject, notification.Body)
+	return nil
+}
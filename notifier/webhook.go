@@ -0,0 +1,118 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// webhookTimeout is how long a webhook can take to respond.
+const webhookTimeout = 10 * time.Second
+
+/*
+WebhookNotifier struct:
+
+1. Secret
+
+2. Client
+
+POSTs the notifications as JSON to the URL of each notification.
+If Secret is set, X-Diary-Signature is "sha256=" and the hex-encoded HMAC-SHA256 of the timestamp(X-Diary-Timestamp), a "." and the body.
+*/
+type WebhookNotifier struct {
+	Secret string
+	Client *http.Client
+}
+
+/*
+NewWebhookNotifier function:
+
+1. Returns a webhook notifier that signs with the secret, and gives up after webhookTimeout.
+
+2. Unless allowPrivate is true, the webhooks cannot be sent to loopback, private or link-local addresses,
+so that users cannot make the server send requests to the internal network. The address is checked when connecting, after any redirect or DNS lookup.
+*/
+func NewWebhookNotifier(secret string, allowPrivate bool) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = rejectPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &WebhookNotifier{Secret: secret, Client: &http.Client{Timeout: webhookTimeout, Transport: transport}}
+}
+
+/*
+rejectPrivateAddress function:
+
+1. Returns an error if the address being connected to is not a public one.
+*/
+func rejectPrivateAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("the webhook cannot be sent to a non-public address: %s", host)
+	}
+	return nil
+}
+
+/*
+Notify function:
+
+1. Encodes the notification as JSON, and signs it if the secret is set.
+
+2. POSTs it to the URL of the notification. If the response is not 2xx, an error is returned.
+*/
+func (notifier *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	// Encodes the notification as JSON, and signs it.
+	body, err := json.Marshal(map[string]interface{}{
+		"type":         "reminder",
+		"user_id":      notification.UserID,
+		"username":     notification.Username,
+		"subject":      notification.Subject,
+		"body":         notification.Body,
+		"scheduled_at": notification.ScheduledAt,
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if notifier.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(notifier.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		request.Header.Set("X-Diary-Timestamp", timestamp)
+		request.Header.Set("X-Diary-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	// POSTs it to the URL of the notification.
+	response, err := notifier.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("the webhook responded with %s", response.Status)
+	}
+	return nil
+}
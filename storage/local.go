@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/*
+LocalStore struct:
+
+1. Dir
+
+The blobs are stored as files under the directory, at the path of their key.
+*/
+type LocalStore struct {
+	Dir string
+}
+
+/*
+NewLocalStore function:
+
+1. Creates the directory if it does not exist.
+*/
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+/*
+Put function:
+
+1. Writes the body to a temporary file in the directory of the key.
+
+2. Renames the temporary file to the key, so that a blob is never read half written.
+*/
+func (store *LocalStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// Writes the body to a temporary file in the directory of the key.
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("blob %q: wrote %d bytes, expected %d", key, written, size)
+	}
+
+	// Renames the temporary file to the key.
+	return os.Rename(file.Name(), path)
+}
+
+/*
+Open function:
+
+1. Opens the file of the key. *os.File can seek, so ranges of it can be served.
+*/
+func (store *LocalStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := store.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+/*
+Delete function:
+
+1. Removes the file of the key.
+*/
+func (store *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+/*
+path function:
+
+1. Executes validateKey function.
+
+2. Returns the path of the file of the key.
+*/
+func (store *LocalStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(store.Dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of the AWS Signature Version 4.
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3Service          = "s3"
+	// s3UnsignedPayload lets a body be streamed without hashing it first. The body is still protected by TLS.
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3SignedHeaders   = "host;x-amz-content-sha256;x-amz-date"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+// s3DefaultRegion is used if S3_REGION is not set. S3-compatible servers like MinIO accept it.
+const s3DefaultRegion = "us-east-1"
+
+// s3RequestTimeout bounds the requests that have no body to stream.
+const s3RequestTimeout = 30 * time.Second
+
+/*
+S3Config struct:
+
+1. Endpoint
+
+2. Region
+
+3. Bucket
+
+4. AccessKeyID
+
+5. SecretAccessKey
+
+6. PathStyle
+
+If Endpoint is empty, the endpoint of AWS S3 in the region is used.
+*/
+type S3Config struct {
+	Endpoint        *url.URL
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle puts the bucket in the path(http://host/bucket/key) instead of the host(http://bucket.host/key). MinIO needs it.
+	PathStyle bool
+}
+
+/*
+S3Store struct:
+
+1. config
+
+2. client
+
+The blobs are stored as objects of an S3-compatible bucket(AWS S3, MinIO, ...).
+The requests are signed with the AWS Signature Version 4.
+
+FYI: https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+*/
+type S3Store struct {
+	config S3Config
+	client *http.Client
+}
+
+/*
+NewS3Store function:
+
+1. Validates the configuration and sets its defaults.
+*/
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if config.Bucket == "" || config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+	if config.Region == "" {
+		config.Region = s3DefaultRegion
+	}
+	if config.Endpoint == nil || config.Endpoint.Host == "" {
+		config.Endpoint = &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", config.Region)}
+	}
+	if config.Endpoint.Scheme != "http" && config.Endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %q", config.Endpoint.String())
+	}
+	return &S3Store{config: config, client: &http.Client{}}, nil
+}
+
+/*
+Put function:
+
+1. Uploads the body with PUT Object.
+
+FYI: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObject.html
+*/
+func (store *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if size == 0 {
+		// A request with a body of unknown length is sent chunked, which PUT Object does not accept.
+		body = http.NoBody
+	}
+	request, err := store.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", contentType)
+	response, err := store.do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+/*
+Open function:
+
+1. Finds the size of the object with HEAD Object.
+
+2. Returns a reader that downloads the object from the current offset with ranged GET Object requests.
+Seeking does not send a request, so serving a range starts the download at the range instead of at the start of the object.
+
+FYI: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html
+*/
+func (store *S3Store) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	headContext, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	request, err := store.newRequest(headContext, http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := store.do(request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	return &s3Object{ctx: ctx, store: store, key: key, size: response.ContentLength}, nil
+}
+
+/*
+Delete function:
+
+1. Deletes the object with DELETE Object. S3 does not fail if the object does not exist.
+
+FYI: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObject.html
+*/
+func (store *S3Store) Delete(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	request, err := store.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	response, err := store.do(request)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+/*
+newRequest function:
+
+1. Executes validateKey function.
+
+2. Creates a request to the URL of the object, path-style or virtual-hosted-style.
+*/
+func (store *S3Store) newRequest(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	objectURL := *store.config.Endpoint
+	basePath := strings.TrimSuffix(objectURL.Path, "/")
+	if store.config.PathStyle {
+		objectURL.Path = basePath + "/" + store.config.Bucket + "/" + key
+	} else {
+		objectURL.Host = store.config.Bucket + "." + objectURL.Host
+		objectURL.Path = basePath + "/" + key
+	}
+	// The keys only contain characters that need no escaping, so the path is the same escaped or not.
+	objectURL.RawPath = ""
+	return http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+}
+
+/*
+do function:
+
+1. Executes sign function and sends the request.
+
+2. If the object does not exist, ErrBlobNotFound is returned. Other failures return the error code of S3.
+*/
+func (store *S3Store) do(request *http.Request) (*http.Response, error) {
+	store.sign(request, time.Now().UTC())
+	response, err := store.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrBlobNotFound
+	}
+	// The error body is XML, e.g. <Error><Code>AccessDenied</Code>...</Error>. A short excerpt is enough to debug it.
+	excerpt, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", request.Method, request.URL.Path, response.Status, strings.TrimSpace(string(excerpt)))
+}
+
+/*
+sign function:
+
+1. Builds the canonical request from the method, the path, the query and the signed headers.
+
+2. Signs it with a key derived from the secret access key, the date, the region and the service.
+
+3. Sets the Authorization header.
+*/
+func (store *S3Store) sign(request *http.Request, now time.Time) {
+	amzDate := now.Format(s3TimeFormat)
+	scope := strings.Join([]string{now.Format(s3DateFormat), store.config.Region, s3Service, "aws4_request"}, "/")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	// Builds the canonical request.
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.Query().Encode(),
+		"host:" + request.URL.Host + "\n" +
+			"x-amz-content-sha256:" + s3UnsignedPayload + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		s3SignedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3SigningAlgorithm, amzDate, scope, hex.EncodeToString(hashedRequest[:])}, "\n")
+
+	// Signs it with a derived key.
+	key := []byte("AWS4" + store.config.SecretAccessKey)
+	for _, part := range []string{now.Format(s3DateFormat), store.config.Region, s3Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	// Sets the Authorization header.
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, store.config.AccessKeyID, scope, s3SignedHeaders, signature))
+}
+
+/*
+hmacSHA256 function:
+
+1. Returns the HMAC-SHA256 of the data with the key.
+*/
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+/*
+s3Object struct:
+
+1. ctx
+
+2. store
+
+3. key
+
+4. size
+
+5. offset
+
+6. body
+
+body is the response of the ranged GET Object request from offset. It is nil until the next Read.
+*/
+type s3Object struct {
+	ctx    context.Context
+	store  *S3Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+/*
+Read function:
+
+1. If no download is open, downloads the object from the offset to its end(Range: bytes=offset-).
+
+2. Reads from the download.
+*/
+func (object *s3Object) Read(p []byte) (int, error) {
+	if object.offset >= object.size {
+		return 0, io.EOF
+	}
+	if object.body == nil {
+		request, err := object.store.newRequest(object.ctx, http.MethodGet, object.key, nil)
+		if err != nil {
+			return 0, err
+		}
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(object.offset, 10)+"-")
+		response, err := object.store.do(request)
+		if err != nil {
+			return 0, err
+		}
+		if response.StatusCode != http.StatusPartialContent && object.offset > 0 {
+			// The server ignored the range, so the object cannot be read from the offset.
+			response.Body.Close()
+			return 0, fmt.Errorf("s3 GET %s: range requests are not supported", object.key)
+		}
+		object.body = response.Body
+	}
+	n, err := object.body.Read(p)
+	object.offset += int64(n)
+	if err == io.EOF && object.offset < object.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+/*
+Seek function:
+
+1. Moves the offset. The open download is closed if the offset changes, and the next Read starts a new one.
+*/
+func (object *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += object.offset
+	case io.SeekEnd:
+		offset += object.size
+	}
+	if offset < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+	if offset != object.offset && object.body != nil {
+		object.body.Close()
+		object.body = nil
+	}
+	object.offset = offset
+	return offset, nil
+}
+
+/*
+Close function:
+
+1. Closes the open download.
+*/
+func (object *s3Object) Close() error {
+	if object.body == nil {
+		return nil
+	}
+	err := object.body.Close()
+	object.body = nil
+	return err
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a stand-in for an S3-compatible server with path-style URLs. It checks the Signature Version 4 of every request.
+type fakeS3 struct {
+	t         *testing.T
+	region    string
+	accessKey string
+	secretKey string
+	// expectRejects is set when the test expects requests with invalid signatures.
+	expectRejects bool
+	mu            sync.Mutex
+	objects       map[string][]byte
+}
+
+func (server *fakeS3) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if err := server.verifySignature(request); err != nil {
+		if !server.expectRejects {
+			server.t.Errorf("%s %s: %v", request.Method, request.URL.Path, err)
+		}
+		writer.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(writer, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+		return
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	key := request.URL.Path
+	switch request.Method {
+	case http.MethodPut:
+		if request.ContentLength < 0 {
+			writer.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		body, _ := io.ReadAll(request.Body)
+		server.objects[key] = body
+		return
+	case http.MethodDelete:
+		delete(server.objects, key)
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	object, ok := server.objects[key]
+	if !ok {
+		writer.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(writer, "<Error><Code>NoSuchKey</Code></Error>")
+		return
+	}
+	if request.Method == http.MethodHead {
+		writer.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		return
+	}
+	if rangeHeader := request.Header.Get("Range"); rangeHeader != "" {
+		start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+		writer.WriteHeader(http.StatusPartialContent)
+		writer.Write(object[start:])
+		return
+	}
+	writer.Write(object)
+}
+
+// verifySignature computes the signature of the request from its headers, as described by AWS, and compares it.
+// FYI: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (server *fakeS3) verifySignature(request *http.Request) error {
+	authorization := request.Header.Get("Authorization")
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(authorization, "AWS4-HMAC-SHA256 "), ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+	amzDate := request.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || time.Since(signedAt) > 15*time.Minute {
+		return fmt.Errorf("invalid X-Amz-Date: %q", amzDate)
+	}
+	scope := signedAt.Format("20060102") + "/" + server.region + "/s3/aws4_request"
+	if fields["Credential"] != server.accessKey+"/"+scope {
+		return fmt.Errorf("invalid credential: %q", fields["Credential"])
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	sort.Strings(signedHeaders)
+	canonicalHeaders := ""
+	for _, name := range signedHeaders {
+		value := request.Header.Get(name)
+		if name == "host" {
+			value = request.Host
+		}
+		canonicalHeaders += name + ":" + strings.TrimSpace(value) + "\n"
+	}
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		(&url.URL{Path: request.URL.Path}).EscapedPath(),
+		request.URL.Query().Encode(),
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		request.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])
+
+	key := []byte("AWS4" + server.secretKey)
+	for _, part := range []string{signedAt.Format("20060102"), server.region, "s3", "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	if want := hex.EncodeToString(mac.Sum(nil)); !hmac.Equal([]byte(fields["Signature"]), []byte(want)) {
+		return errors.New("the signature does not match")
+	}
+	return nil
+}
+
+func newTestS3Store(t *testing.T, secretKey string) (*S3Store, *fakeS3) {
+	t.Helper()
+	server := &fakeS3{t: t, region: "eu-west-1", accessKey: "AKIDEXAMPLE", secretKey: "secret", objects: map[string][]byte{}}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	endpoint, _ := url.Parse(httpServer.URL)
+	store, err := NewS3Store(S3Config{
+		Endpoint: endpoint, Region: "eu-west-1", Bucket: "diary", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: secretKey, PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, server
+}
+
+func TestS3Store(t *testing.T) {
+	store, server := newTestS3Store(t, "secret")
+	ctx := context.Background()
+	content := "0123456789abcdef"
+
+	if err := store.Put(ctx, "users/1/photo.jpg", strings.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(server.objects["/diary/users/1/photo.jpg"]); got != content {
+		t.Fatalf("stored %q, want %q", got, content)
+	}
+
+	object, err := store.Open(ctx, "users/1/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer object.Close()
+	// Seeking starts the download at the offset with a ranged GET.
+	if _, err := object.Seek(10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if rest, err := io.ReadAll(object); err != nil || string(rest) != "abcdef" {
+		t.Errorf("read %q, %v after seeking, want abcdef", rest, err)
+	}
+	if _, err := object.Seek(-16, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := io.ReadAll(object); err != nil || string(all) != content {
+		t.Errorf("read %q, %v, want the whole object", all, err)
+	}
+
+	if err := store.Delete(ctx, "users/1/photo.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, "users/1/photo.jpg"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Open() of a deleted object = %v, want ErrBlobNotFound", err)
+	}
+	// Deleting a missing object is not an error.
+	if err := store.Delete(ctx, "users/1/photo.jpg"); err != nil {
+		t.Errorf("Delete() of a missing object = %v", err)
+	}
+}
+
+func TestS3StoreEmptyObject(t *testing.T) {
+	store, server := newTestS3Store(t, "secret")
+	if err := store.Put(context.Background(), "empty", strings.NewReader(""), 0, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if object, ok := server.objects["/diary/empty"]; !ok || len(object) != 0 {
+		t.Errorf("stored %q, %v, want an empty object", object, ok)
+	}
+}
+
+func TestS3StoreRejectsInvalidKeys(t *testing.T) {
+	store, _ := newTestS3Store(t, "secret")
+	for _, key := range []string{"../etc/passwd", "/absolute", "a//b", "", "a b"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
+
+func TestS3StoreWrongSecret(t *testing.T) {
+	store, server := newTestS3Store(t, "wrong")
+	server.expectRejects = true
+	err := store.Put(context.Background(), "a", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put() with the wrong secret = %v, want SignatureDoesNotMatch", err)
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Storage drivers, set by STORAGE_DRIVER.
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// defaultLocalDir is where the local driver stores the blobs if STORAGE_LOCAL_DIR is not set.
+const defaultLocalDir = "data/blobs"
+
+// ErrBlobNotFound is returned when a blob does not exist.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// keyPattern matches a key: segments of letters, numbers, ".", "_" and "-" separated by "/".
+// A segment cannot start with ".", so that a key cannot escape the local directory.
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+/*
+BlobStore interface:
+
+1. Put
+
+2. Open
+
+3. Delete
+
+A blob store keeps the contents of files, e.g. attachments, by their key. The metadata of the files is stored in the database.
+*/
+type BlobStore interface {
+	// Put stores the body(size bytes) at the key, replacing any blob already stored there.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Open returns a reader of the blob that can seek, so that ranges of it can be served.
+	// If the blob does not exist, ErrBlobNotFound is returned.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete deletes the blob. Deleting a blob that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var Blobs BlobStore
+
+/*
+Connect function:
+
+1. Retrieves the environment variables of the storage driver(STORAGE_DRIVER).
+
+2. Sets Blobs to the local or the S3-compatible blob store.
+*/
+func Connect() error {
+	store, err := newBlobStore(os.Getenv("STORAGE_DRIVER"))
+	if err != nil {
+		return err
+	}
+	Blobs = store
+	return nil
+}
+
+/*
+newBlobStore function:
+
+1. Returns the blob store of the driver, configured by the environment variables. The local driver is the default.
+*/
+func newBlobStore(driver string) (BlobStore, error) {
+	switch driver {
+	case "", DriverLocal:
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = defaultLocalDir
+		}
+		return NewLocalStore(dir)
+	case DriverS3:
+		endpoint, err := url.Parse(os.Getenv("S3_ENDPOINT"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3_ENDPOINT: %w", err)
+		}
+		return NewS3Store(S3Config{
+			Endpoint:        endpoint,
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			// Path-style URLs(http://host/bucket/key) are used unless S3_PATH_STYLE is "false".
+			PathStyle: !strings.EqualFold(os.Getenv("S3_PATH_STYLE"), "false"),
+		})
+	}
+	return nil, fmt.Errorf("unknown STORAGE_DRIVER: %q (use %q or %q)", driver, DriverLocal, DriverS3)
+}
+
+/*
+validateKey function:
+
+1. Rejects the keys that do not match keyPattern.
+*/
+func validateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid blob key: %q", key)
+	}
+	return nil
+}